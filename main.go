@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/bitrise-io/go-steputils/v2/ruby"
 	"github.com/bitrise-io/go-steputils/v2/stepconf"
@@ -18,6 +19,7 @@ import (
 	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
 	"github.com/bitrise-steplib/steps-xcode-archive/step"
 	"github.com/bitrise-steplib/steps-xcode-archive/step/buildcache"
+	"github.com/bitrise-steplib/steps-xcode-archive/step/watchdog"
 )
 
 func main() {
@@ -33,16 +35,33 @@ func run() int {
 		return 1
 	}
 
-	archiver, err := createXcodebuildArchiver(config.Logger, config.LogFormatter)
+	watchdogOpts := watchdog.Opts{
+		SilenceTimeout: time.Duration(config.SilenceTimeout) * time.Second,
+		TotalTimeout:   time.Duration(config.Timeout) * time.Second,
+	}
+	archiver, err := createXcodebuildArchiver(config.Logger, config.LogFormatter, watchdogOpts, config.StepOutputExporter)
 	if err != nil {
 		logger.Errorf("%s", errorutil.FormattedError(fmt.Errorf("Failed to process Step inputs: %w", err)))
 		return 1
 	}
 
+	runOpts := createRunOptions(config)
+	if config.DryRun {
+		plan, err := archiver.Plan(runOpts)
+		if err != nil {
+			logger.Errorf("%s", errorutil.FormattedError(fmt.Errorf("Failed to plan the Step run: %w", err)))
+			return 1
+		}
+		if err := archiver.ExportPlan(plan, config.OutputDir); err != nil {
+			logger.Errorf("%s", errorutil.FormattedError(fmt.Errorf("Failed to export Step outputs: %w", err)))
+			return 1
+		}
+		return 0
+	}
+
 	archiver.EnsureDependencies()
 
 	exitCode := 0
-	runOpts := createRunOptions(config)
 	result, err := archiver.Run(runOpts)
 	if err != nil {
 		logger.Errorf("%s", errorutil.FormattedError(fmt.Errorf("Failed to execute Step main logic: %w", err)))
@@ -70,7 +89,7 @@ func createConfigParser(logger log.Logger) step.XcodebuildArchiveConfigParser {
 	return step.NewXcodeArchiveConfigParser(inputParser, xcodeVersionReader, fileManager, cmdFactory, projectFactory, logger)
 }
 
-func createXcodebuildArchiver(logger log.Logger, logFormatter string) (step.XcodebuildArchiver, error) {
+func createXcodebuildArchiver(logger log.Logger, logFormatter string, watchdogOpts watchdog.Opts, outputExporter step.OutputExporter) (step.XcodebuildArchiver, error) {
 	envRepository := env.NewRepository()
 	pathProvider := pathutil.NewPathProvider()
 	pathChecker := pathutil.NewPathChecker()
@@ -87,6 +106,8 @@ func createXcodebuildArchiver(logger log.Logger, logFormatter string) (step.Xcod
 		logger.Infof("Bitrise Build Cache: React Native cache active — wrapping xcodebuild with %s", det.CLIPath)
 		runnerCmdFactory = buildcache.NewWrappingCommandFactory(cmdFactory, det.CLIPath)
 	}
+	// The watchdog wraps last, so it sees the xcodebuild invocations before any rewriting.
+	runnerCmdFactory = watchdog.NewCommandFactory(runnerCmdFactory, watchdogOpts, logger)
 
 	xcodeCommandRunner := xcodecommand.Runner(nil)
 	switch logFormatter {
@@ -107,7 +128,7 @@ func createXcodebuildArchiver(logger log.Logger, logFormatter string) (step.Xcod
 		panic(fmt.Sprintf("Unknown log formatter: %s", logFormatter))
 	}
 
-	return step.NewXcodebuildArchiverWithRunnerFactory(xcodeCommandRunner, logFormatter, xcodeVersionReader, pathProvider, pathChecker, pathModifier, fileManager, cmdFactory, runnerCmdFactory, logger), nil
+	return step.NewXcodebuildArchiverWithRunnerFactory(xcodeCommandRunner, logFormatter, xcodeVersionReader, pathProvider, pathChecker, pathModifier, fileManager, cmdFactory, runnerCmdFactory, outputExporter, logger), nil
 }
 
 func createRunOptions(config step.Config) step.RunOpts {
@@ -120,19 +141,39 @@ func createRunOptions(config step.Config) step.RunOpts {
 		XcodeMajorVersion:   config.XcodeMajorVersion,
 		ArtifactName:        config.ArtifactName,
 
-		CodesignManager: config.CodesignManager,
+		CodeSigningAuthSource:  config.CodeSigningAuthSource,
+		CodesignManager:        config.CodesignManager,
+		ExportCodesignManagers: config.ExportCodesignManagers,
+
+		Archive:      config.Archive,
+		MacosArchive: config.MacosArchive,
+		SkipExport:   config.SkipExport,
 
 		PerformCleanAction:          config.PerformCleanAction,
 		XcconfigContent:             config.XcconfigContent,
 		XcodebuildAdditionalOptions: config.XcodebuildAdditionalOptions,
+		DerivedDataPath:             config.DerivedDataPath,
+		AppVersion:                  config.AppVersion,
+		ArchiveRetryRules:           config.ArchiveRetryRules,
+		BuildTimingSummary:          config.BuildTimingSummary,
 
 		CustomExportOptionsPlistContent: config.ExportOptionsPlistContent,
-		ExportMethod:                    config.ExportMethod,
+		ExportOptionsMode:               config.ExportOptionsMode,
+		ExportMethods:                   config.ExportMethods,
 		TestFlightInternalTestingOnly:   config.TestFlightInternalTestingOnly,
 		ICloudContainerEnvironment:      config.ICloudContainerEnvironment,
 		ExportDevelopmentTeam:           config.ExportDevelopmentTeam,
 		UploadBitcode:                   config.UploadBitcode,
 		CompileBitcode:                  config.CompileBitcode,
+		OTA:                             config.OTA,
+		ProfileOverrides:                config.ProfileOverrides,
+		ExportSigningCertificate:        config.ExportSigningCertificate,
+		Thinning:                        config.Thinning,
+		ThinningSizeBudgets:             config.ThinningSizeBudgets,
+		ExportDestination:               config.ExportDestination,
+		UploadAPIKey:                    config.UploadAPIKey,
+		AppStoreConnectAPIBaseURL:       config.AppStoreConnectAPIBaseURL,
+		VerifyIPA:                       config.VerifyIPA,
 	}
 }
 
@@ -142,13 +183,29 @@ func createExportOptions(config step.Config, result step.RunResult) step.ExportO
 		ArtifactName:   result.ArtifactName,
 		ExportAllDsyms: config.ExportAllDsyms,
 
-		Archive: result.Archive,
+		Scheme:        config.Scheme,
+		Configuration: config.Configuration,
+		XcodeVersion:  config.XcodeVersion,
+
+		Archive:      result.Archive,
+		MacosArchive: result.MacosArchive,
 
-		ExportOptionsPath: result.ExportOptionsPath,
-		IPAExportDir:      result.IPAExportDir,
+		IPAExports: result.IPAExports,
 
+		XcresultPath:               result.XcresultPath,
+		FiredRetryRules:            result.FiredRetryRules,
 		XcodebuildArchiveLog:       result.XcodebuildArchiveLog,
 		XcodebuildExportArchiveLog: result.XcodebuildExportArchiveLog,
 		IDEDistrubutionLogsDir:     result.IDEDistrubutionLogsDir,
+		FailureSummary:             result.FailureSummary,
+
+		BuildTimingReport: config.BuildTimingSummary,
+		StageTimings:      result.StageTimings,
+
+		DerivedDataPath:     config.DerivedDataPath,
+		DerivedDataCacheKey: config.DerivedDataCacheKey,
+
+		AppVersion: config.AppVersion,
+		OTA:        config.OTA,
 	}
 }
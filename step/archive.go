@@ -3,24 +3,42 @@ package step
 import (
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/bitrise-io/go-utils/v2/log"
-	cache "github.com/bitrise-io/go-xcode/v2/xcodecache"
 	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
 	"github.com/bitrise-io/go-xcode/xcodebuild"
 )
 
-func runArchiveCommandWithRetry(xcodeCommandRunner xcodecommand.Runner, logFormatter string, archiveCmd *xcodebuild.CommandBuilder, swiftPackagesPath string, logger log.Logger) (string, error) {
-	output, err := runArchiveCommand(xcodeCommandRunner, logFormatter, archiveCmd, logger)
-	if err != nil && swiftPackagesPath != "" && strings.Contains(output, cache.SwiftPackagesStateInvalid) {
-		logger.Warnf("Archive failed, swift packages cache is in an invalid state, error: %s", err)
-		if err := os.RemoveAll(swiftPackagesPath); err != nil {
-			return output, fmt.Errorf("failed to remove invalid Swift package caches, error: %s", err)
+// runArchiveCommandWithRetry runs the archive command, and retries it if the failure matches one of the retry rules.
+// The remediation of the matching rule is performed before each retry. Returns the names of the fired rules.
+func runArchiveCommandWithRetry(xcodeCommandRunner xcodecommand.Runner, logFormatter string, archiveCmd *xcodebuild.CommandBuilder, rules []RetryRule, remediate func(RetryRule) error, xcresultPath string, logger log.Logger) (string, []string, error) {
+	retries := map[string]int{}
+	var firedRules []string
+	for {
+		output, err := runArchiveCommand(xcodeCommandRunner, logFormatter, archiveCmd, logger)
+		if err == nil {
+			return output, firedRules, nil
+		}
+
+		rule, ok := matchRetryRule(rules, output, retries)
+		if !ok {
+			return output, firedRules, err
+		}
+		retries[rule.Name]++
+		firedRules = append(firedRules, rule.Name)
+
+		logger.Warnf("Archive failed, retry rule %s matched (retry %d/%d), error: %s", rule.Name, retries[rule.Name], rule.MaxRetries, err)
+		if err := remediate(rule); err != nil {
+			return output, firedRules, fmt.Errorf("failed to perform the %s action of retry rule %s, error: %s", rule.Action, rule.Name, err)
+		}
+
+		// xcodebuild fails if the result bundle already exists
+		if xcresultPath != "" {
+			if err := os.RemoveAll(xcresultPath); err != nil {
+				return output, firedRules, fmt.Errorf("failed to remove the result bundle of the failed archive, error: %s", err)
+			}
 		}
-		return runArchiveCommand(xcodeCommandRunner, logFormatter, archiveCmd, logger)
 	}
-	return output, err
 }
 
 func runArchiveCommand(xcodeCommandRunner xcodecommand.Runner, logFormatter string, archiveCmd *xcodebuild.CommandBuilder, logger log.Logger) (string, error) {
@@ -0,0 +1,63 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+)
+
+// openArchive parses the archive at the given path, either the iOS-family or the macOS archive is returned.
+func openArchive(archivePath string, pathChecker pathutil.PathChecker, logger log.Logger) (*xcarchive.IosArchive, *xcarchive.MacosArchive, error) {
+	if exist, err := pathChecker.IsDirExists(archivePath); err != nil {
+		return nil, nil, fmt.Errorf("failed to check if archive exist, error: %s", err)
+	} else if !exist {
+		return nil, nil, fmt.Errorf("archive does not exist at: %s", archivePath)
+	}
+
+	isMacosArchive, err := xcarchive.NewArchiveReader(pathChecker, logger).IsMacOS(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check if archive is a macOS archive: %w", err)
+	}
+	if isMacosArchive {
+		macosArchive, err := xcarchive.NewMacosArchive(archivePath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse macOS archive, error: %s", err)
+		}
+		return nil, &macosArchive, nil
+	}
+
+	archive, err := xcarchive.NewIosArchive(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse archive, error: %s", err)
+	}
+	return &archive, nil, nil
+}
+
+// artifactNameFromArchive returns the name of the archived application, without the .app extension.
+func artifactNameFromArchive(archive *xcarchive.IosArchive, macosArchive *xcarchive.MacosArchive) string {
+	var appPath string
+	if archive != nil {
+		appPath = archive.Application.Path
+	} else if macosArchive != nil {
+		appPath = macosArchive.Application.Path
+	}
+
+	appName := filepath.Base(appPath)
+	return strings.TrimSuffix(appName, filepath.Ext(appName))
+}
+
+func printIosArchiveInfo(archive xcarchive.IosArchive, logger log.Logger) {
+	mainApplication := archive.Application
+
+	logger.Println()
+	logger.Infof("Archive info:")
+	logger.Printf("team: %s (%s)", mainApplication.ProvisioningProfile.TeamName, mainApplication.ProvisioningProfile.TeamID)
+	logger.Printf("profile: %s (%s)", mainApplication.ProvisioningProfile.Name, mainApplication.ProvisioningProfile.UUID)
+	logger.Printf("export: %s", mainApplication.ProvisioningProfile.ExportType)
+	logger.Printf("xcode managed profile: %v", profileutil.IsXcodeManaged(mainApplication.ProvisioningProfile.Name))
+}
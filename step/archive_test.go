@@ -0,0 +1,69 @@
+package step
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
+	"github.com/bitrise-io/go-xcode/xcodebuild"
+	"github.com/hashicorp/go-version"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeXcodeCommandRunner creates the result bundle like xcodebuild does, and returns the next scripted result.
+type fakeXcodeCommandRunner struct {
+	outputs []string
+	errs    []error
+
+	runs                int
+	resultBundleExisted []bool
+}
+
+func (r *fakeXcodeCommandRunner) CheckInstall() (*version.Version, error) {
+	return nil, nil
+}
+
+func (r *fakeXcodeCommandRunner) Run(_ string, xcodebuildOpts []string, _ []string) (xcodecommand.Output, error) {
+	if i := slices.Index(xcodebuildOpts, "-resultBundlePath"); i != -1 {
+		resultBundlePath := xcodebuildOpts[i+1]
+		_, err := os.Stat(resultBundlePath)
+		r.resultBundleExisted = append(r.resultBundleExisted, err == nil)
+		if err := os.MkdirAll(resultBundlePath, 0755); err != nil {
+			return xcodecommand.Output{}, err
+		}
+	}
+
+	output, err := r.outputs[r.runs], r.errs[r.runs]
+	r.runs++
+	return xcodecommand.Output{RawOut: []byte(output)}, err
+}
+
+func Test_runArchiveCommandWithRetry(t *testing.T) {
+	xcresultPath := filepath.Join(t.TempDir(), "App.xcresult")
+	archiveCmd := xcodebuild.NewCommandBuilder("App.xcodeproj", "archive")
+	archiveCmd.SetResultBundlePath(xcresultPath)
+
+	runner := &fakeXcodeCommandRunner{
+		outputs: []string{"error: flaky failure\n** ARCHIVE FAILED **", "** ARCHIVE SUCCEEDED **"},
+		errs:    []error{errors.New("exit status 65"), nil},
+	}
+	rules := []RetryRule{{Name: "flaky", Pattern: regexp.MustCompile("flaky failure"), Action: RetryActionClean, MaxRetries: 1}}
+	var remediated []string
+	remediate := func(rule RetryRule) error {
+		remediated = append(remediated, rule.Name)
+		return nil
+	}
+
+	output, firedRules, err := runArchiveCommandWithRetry(runner, XcodebuildTool, archiveCmd, rules, remediate, xcresultPath, log.NewLogger())
+	require.NoError(t, err)
+	require.Equal(t, "** ARCHIVE SUCCEEDED **", output)
+	require.Equal(t, []string{"flaky"}, firedRules)
+	require.Equal(t, []string{"flaky"}, remediated)
+	// The result bundle of the failed archive is removed before the retry, as xcodebuild fails if it already exists
+	require.Equal(t, []bool{false, false}, runner.resultBundleExisted)
+}
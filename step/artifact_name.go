@@ -0,0 +1,100 @@
+package step
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+)
+
+// Placeholders of the artifact name template, resolved after the archive action
+const (
+	artifactNameProductPlaceholder       = "{product}"
+	artifactNameVersionPlaceholder       = "{version}"
+	artifactNameBuildPlaceholder         = "{build}"
+	artifactNameMethodPlaceholder        = "{method}"
+	artifactNameConfigurationPlaceholder = "{configuration}"
+)
+
+var (
+	artifactNamePlaceholders = []string{
+		artifactNameProductPlaceholder,
+		artifactNameVersionPlaceholder,
+		artifactNameBuildPlaceholder,
+		artifactNameMethodPlaceholder,
+		artifactNameConfigurationPlaceholder,
+	}
+
+	artifactNamePlaceholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+	// Characters not allowed in file names on macOS or Windows, and control characters
+	unsafeFilenameCharPattern = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f\x7f]`)
+)
+
+// isArtifactNameTemplate returns true if the artifact name contains placeholders.
+func isArtifactNameTemplate(artifactName string) bool {
+	return artifactNamePlaceholderPattern.MatchString(artifactName)
+}
+
+// validateArtifactNameTemplate checks that the artifact name only contains known placeholders.
+func validateArtifactNameTemplate(artifactName string) error {
+	for _, placeholder := range artifactNamePlaceholderPattern.FindAllString(artifactName, -1) {
+		if !slices.Contains(artifactNamePlaceholders, placeholder) {
+			return fmt.Errorf("unknown placeholder %s, available placeholders: %s", placeholder, strings.Join(artifactNamePlaceholders, ", "))
+		}
+	}
+	return nil
+}
+
+// artifactNameTemplate resolves the artifact name template with the archived app's details.
+type artifactNameTemplate struct {
+	Template string
+	// Product is the product name (or the archived application's name in export-only mode),
+	// the artifact name itself if it is not a template.
+	Product       string
+	Version       string
+	Build         string
+	Configuration string
+}
+
+func newArtifactNameTemplate(template, product, configuration string, archive *xcarchive.IosArchive, macosArchive *xcarchive.MacosArchive) artifactNameTemplate {
+	t := artifactNameTemplate{Template: template, Product: product, Configuration: configuration}
+	if bundles := archivedInfoPlists(archive, macosArchive); len(bundles) > 0 {
+		t.Version, _ = bundles[0].InfoPlist.GetString(bundleShortVersionKey)
+		t.Build, _ = bundles[0].InfoPlist.GetString(bundleVersionKey)
+	}
+	return t
+}
+
+// name resolves the template for the given distribution method, the unsafe file name characters are replaced by underscores.
+func (t artifactNameTemplate) name(exportMethod string) string {
+	if !isArtifactNameTemplate(t.Template) {
+		return t.Product
+	}
+
+	name := strings.NewReplacer(
+		artifactNameProductPlaceholder, t.Product,
+		artifactNameVersionPlaceholder, t.Version,
+		artifactNameBuildPlaceholder, t.Build,
+		artifactNameMethodPlaceholder, exportMethod,
+		artifactNameConfigurationPlaceholder, t.Configuration,
+	).Replace(t.Template)
+
+	name = unsafeFilenameCharPattern.ReplaceAllString(name, "_")
+	// A leading dot would hide the artifacts
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+	if name == "" {
+		return t.Product
+	}
+	return name
+}
+
+// exportName resolves the template for a distribution method's products.
+// If the template doesn't contain the method, the additional distribution methods are suffixed with it, like the fixed artifact names.
+func (t artifactNameTemplate) exportName(exportMethod string, isMainExport bool) string {
+	if isArtifactNameTemplate(t.Template) && strings.Contains(t.Template, artifactNameMethodPlaceholder) {
+		return t.name(exportMethod)
+	}
+	return exportArtifactName(t.name(exportMethod), exportMethod, isMainExport)
+}
@@ -0,0 +1,93 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateArtifactNameTemplate(t *testing.T) {
+	require.NoError(t, validateArtifactNameTemplate("Sample"))
+	require.NoError(t, validateArtifactNameTemplate("{product}-{version}({build})-{method}-{configuration}"))
+	require.EqualError(t, validateArtifactNameTemplate("{product}-{commit}"), "unknown placeholder {commit}, available placeholders: {product}, {version}, {build}, {method}, {configuration}")
+}
+
+func Test_artifactNameTemplate(t *testing.T) {
+	archive := &xcarchive.IosArchive{
+		Application: xcarchive.IosApplication{
+			IosBaseApplication: xcarchive.IosBaseApplication{
+				InfoPlist: plistutil.PlistData{
+					"CFBundleShortVersionString": "1.2.0",
+					"CFBundleVersion":            "42",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		template     string
+		exportMethod string
+		isMainExport bool
+		want         string
+	}{
+		{
+			name:         "fixed name",
+			template:     "Sample",
+			exportMethod: "app-store",
+			isMainExport: true,
+			want:         "Sample",
+		},
+		{
+			name:         "fixed name, additional method",
+			template:     "Sample",
+			exportMethod: "ad-hoc",
+			want:         "Sample-ad-hoc",
+		},
+		{
+			name:         "all placeholders",
+			template:     "{product}-{version}({build})-{method}-{configuration}",
+			exportMethod: "app-store",
+			isMainExport: true,
+			want:         "Sample-1.2.0(42)-app-store-Release",
+		},
+		{
+			name:         "additional method with method placeholder",
+			template:     "{product}-{method}",
+			exportMethod: "ad-hoc",
+			want:         "Sample-ad-hoc",
+		},
+		{
+			name:         "additional method without method placeholder",
+			template:     "{product}-{version}",
+			exportMethod: "ad-hoc",
+			want:         "Sample-1.2.0-ad-hoc",
+		},
+		{
+			name:         "unsafe characters",
+			template:     ".{product}/{version}:{build}",
+			exportMethod: "app-store",
+			isMainExport: true,
+			want:         "Sample_1.2.0_42",
+		},
+		{
+			name:         "empty result",
+			template:     " {method} ",
+			exportMethod: "",
+			isMainExport: true,
+			want:         "Sample",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := "Sample"
+			if !isArtifactNameTemplate(tt.template) {
+				product = tt.template
+			}
+			names := newArtifactNameTemplate(tt.template, product, "Release", archive, nil)
+			require.Equal(t, tt.want, names.exportName(tt.exportMethod, tt.isMainExport))
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package step
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cache "github.com/bitrise-io/go-xcode/v2/xcodecache"
+	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
+)
+
+const derivedDataPathOption = "-derivedDataPath"
+
+// swiftPackagesDir returns the Swift packages cache dir, which is inside the DerivedData dir.
+// If no custom DerivedData path is set, xcodebuild's default, per-project DerivedData dir is used.
+func swiftPackagesDir(projectPath, derivedDataPath string) (string, error) {
+	if derivedDataPath != "" {
+		return filepath.Join(derivedDataPath, "SourcePackages"), nil
+	}
+	return cache.NewSwiftPackageCache().SwiftPackagesPath(projectPath)
+}
+
+// dependencyLockfiles returns the possible locations of the lockfiles (Swift packages, CocoaPods) of the project.
+func dependencyLockfiles(projectPath string) []string {
+	projectDir := filepath.Dir(projectPath)
+
+	packageResolvedPath := filepath.Join(projectPath, "xcshareddata", "swiftpm", "Package.resolved")
+	if filepath.Ext(projectPath) == ".xcodeproj" {
+		packageResolvedPath = filepath.Join(projectPath, "project.xcworkspace", "xcshareddata", "swiftpm", "Package.resolved")
+	}
+
+	return []string{
+		packageResolvedPath,
+		filepath.Join(projectDir, "Package.resolved"),
+		filepath.Join(projectDir, "Podfile.lock"),
+	}
+}
+
+// derivedDataCacheKey returns a cache key for the DerivedData dir, which changes if any of the
+// dependency lockfiles, the Xcode version, the scheme or the configuration changes.
+func derivedDataCacheKey(projectPath, scheme, configuration string, xcodeVersion xcodeversion.Version) (string, error) {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "xcode:%s (%s)\n", xcodeVersion.Version, xcodeVersion.BuildVersion)
+	_, _ = fmt.Fprintf(hash, "scheme:%s\n", scheme)
+	_, _ = fmt.Fprintf(hash, "configuration:%s\n", configuration)
+
+	for _, lockfile := range dependencyLockfiles(projectPath) {
+		content, err := os.ReadFile(lockfile)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", fmt.Errorf("failed to read lockfile (%s): %w", lockfile, err)
+		}
+
+		relPath, err := filepath.Rel(filepath.Dir(projectPath), lockfile)
+		if err != nil {
+			relPath = filepath.Base(lockfile)
+		}
+		lockfileHash := sha256.Sum256(content)
+		_, _ = fmt.Fprintf(hash, "%s:%s\n", relPath, hex.EncodeToString(lockfileHash[:]))
+	}
+
+	return "xcode-derived-data-" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// withDerivedDataPath returns the xcodebuild options extended with the custom DerivedData path, if it is set.
+func withDerivedDataPath(options []string, derivedDataPath string) []string {
+	if derivedDataPath == "" {
+		return options
+	}
+	return append(append([]string{}, options...), derivedDataPathOption, derivedDataPath)
+}
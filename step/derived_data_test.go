@@ -0,0 +1,52 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_derivedDataCacheKey(t *testing.T) {
+	projectDir := t.TempDir()
+	projectPath := filepath.Join(projectDir, "App.xcodeproj")
+	packageResolvedDir := filepath.Join(projectPath, "project.xcworkspace", "xcshareddata", "swiftpm")
+	require.NoError(t, os.MkdirAll(packageResolvedDir, 0700))
+	xcode := xcodeversion.Version{Version: "Xcode 15.4", BuildVersion: "15F31d"}
+
+	key, err := derivedDataCacheKey(projectPath, "App", "Release", xcode)
+	require.NoError(t, err)
+	require.Regexp(t, `^xcode-derived-data-[0-9a-f]{64}$`, key)
+
+	sameKey, err := derivedDataCacheKey(projectPath, "App", "Release", xcode)
+	require.NoError(t, err)
+	require.Equal(t, key, sameKey, "the key should be stable")
+
+	otherConfigurationKey, err := derivedDataCacheKey(projectPath, "App", "Debug", xcode)
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherConfigurationKey)
+
+	otherXcodeKey, err := derivedDataCacheKey(projectPath, "App", "Release", xcodeversion.Version{Version: "Xcode 16.0", BuildVersion: "16A242d"})
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherXcodeKey)
+
+	require.NoError(t, os.WriteFile(filepath.Join(packageResolvedDir, "Package.resolved"), []byte(`{"pins": []}`), 0600))
+	packageResolvedKey, err := derivedDataCacheKey(projectPath, "App", "Release", xcode)
+	require.NoError(t, err)
+	require.NotEqual(t, key, packageResolvedKey)
+
+	require.NoError(t, os.WriteFile(filepath.Join(projectDir, "Podfile.lock"), []byte("PODFILE CHECKSUM: abc"), 0600))
+	podfileLockKey, err := derivedDataCacheKey(projectPath, "App", "Release", xcode)
+	require.NoError(t, err)
+	require.NotEqual(t, packageResolvedKey, podfileLockKey)
+}
+
+func Test_withDerivedDataPath(t *testing.T) {
+	options := []string{"-skipPackagePluginValidation"}
+
+	require.Equal(t, options, withDerivedDataPath(options, ""))
+	require.Equal(t, []string{"-skipPackagePluginValidation", "-derivedDataPath", "/tmp/DerivedData"}, withDerivedDataPath(options, "/tmp/DerivedData"))
+	require.Equal(t, []string{"-skipPackagePluginValidation"}, options, "the original options should not be modified")
+}
@@ -0,0 +1,179 @@
+package step
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	v1fileutil "github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"howett.net/plist"
+)
+
+const (
+	// ExportOptionsModeReplace uses the custom export options as they are.
+	ExportOptionsModeReplace = "replace"
+	// ExportOptionsModeMerge merges the custom export options over the generated ones.
+	ExportOptionsModeMerge = "merge"
+)
+
+// exportOptionChange is a key set by the custom export options in merge mode.
+type exportOptionChange struct {
+	KeyPath    string // nested keys are quoted, as they may contain dots (bundle IDs): provisioningProfiles["com.example.app"]
+	Overridden bool   // false if the key was added
+}
+
+// writeExportOptions writes the export options used by the export action:
+// the custom export options in replace mode, the generated ones if no custom export options are provided,
+// or the custom export options merged over the generated ones in merge mode.
+func (s XcodebuildArchiver) writeExportOptions(exportOptionsPath, customContent, mode string, generate func() (exportoptions.ExportOptions, error)) error {
+	if customContent != "" && mode != ExportOptionsModeMerge {
+		s.logger.Printf("Custom export options content provided, using it:")
+		s.logger.Printf(customContent)
+
+		if err := v1fileutil.WriteStringToFile(exportOptionsPath, customContent); err != nil {
+			return fmt.Errorf("failed to write export options to file, error: %s", err)
+		}
+		return nil
+	}
+
+	if customContent == "" {
+		s.logger.Printf("No custom export options content provided, generating export options...")
+	} else {
+		s.logger.Printf("Custom export options content provided, generating export options to merge it over...")
+	}
+
+	exportOptions, err := generate()
+	if err != nil {
+		return err
+	}
+
+	s.logger.Println()
+	s.logger.Printf("generated export options content:")
+	s.logger.Println()
+	exportOptionsContent, err := exportOptions.String()
+	if err != nil {
+		return err
+	}
+	s.logger.Printf("%s", exportOptionsContent)
+
+	if customContent == "" {
+		return exportOptions.WriteToFile(exportOptionsPath)
+	}
+
+	merged, changes, err := mergeExportOptions([]byte(exportOptionsContent), []byte(customContent))
+	if err != nil {
+		return fmt.Errorf("failed to merge custom export options: %w", err)
+	}
+
+	mergedContent, err := plist.MarshalIndent(merged, plist.XMLFormat, "\t")
+	if err != nil {
+		return fmt.Errorf("failed to marshal merged export options: %w", err)
+	}
+
+	s.logger.Println()
+	s.logger.Printf("effective export options content:")
+	s.logger.Println()
+	s.logger.Printf("%s", mergedContent)
+	s.logger.Println()
+	s.logger.Printf("Keys set by the custom export options:")
+	for _, change := range changes {
+		if change.Overridden {
+			s.logger.Warnf("- %s (overrides the generated value)", change.KeyPath)
+		} else {
+			s.logger.Donef("- %s (added)", change.KeyPath)
+		}
+	}
+
+	return exportoptions.WritePlistToFile(merged, exportOptionsPath)
+}
+
+// mergeExportOptions deep-merges the custom export options over the generated ones:
+// dictionaries are merged key by key, any other value (including arrays) of the custom export options replaces the generated one.
+// A key with different value types in the two plists (for example a dictionary and a string) is an error.
+func mergeExportOptions(generatedContent, customContent []byte) (map[string]interface{}, []exportOptionChange, error) {
+	var generated map[string]interface{}
+	if _, err := plist.Unmarshal(generatedContent, &generated); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse generated export options: %w", err)
+	}
+
+	var custom map[string]interface{}
+	if _, err := plist.Unmarshal(customContent, &custom); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse custom export options: %w", err)
+	}
+
+	if generated == nil {
+		generated = map[string]interface{}{}
+	}
+
+	var changes []exportOptionChange
+	if err := mergePlistDict(generated, custom, "", &changes); err != nil {
+		return nil, nil, err
+	}
+
+	return generated, changes, nil
+}
+
+func mergePlistDict(base, override map[string]interface{}, parentKeyPath string, changes *[]exportOptionChange) error {
+	keys := make([]string, 0, len(override))
+	for key := range override {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := key
+		if parentKeyPath != "" {
+			keyPath = fmt.Sprintf("%s[%s]", parentKeyPath, strconv.Quote(key))
+		}
+		overrideValue := override[key]
+
+		baseValue, ok := base[key]
+		if !ok {
+			base[key] = overrideValue
+			*changes = append(*changes, exportOptionChange{KeyPath: keyPath})
+			continue
+		}
+
+		baseType, overrideType := plistValueType(baseValue), plistValueType(overrideValue)
+		if baseType != overrideType {
+			return fmt.Errorf("type conflict at key %s: generated value is %s, custom value is %s", keyPath, baseType, overrideType)
+		}
+
+		if baseDict, ok := baseValue.(map[string]interface{}); ok {
+			if err := mergePlistDict(baseDict, overrideValue.(map[string]interface{}), keyPath, changes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		base[key] = overrideValue
+		*changes = append(*changes, exportOptionChange{KeyPath: keyPath, Overridden: true})
+	}
+
+	return nil
+}
+
+func plistValueType(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "dictionary"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case float32, float64:
+		return "real"
+	case time.Time:
+		return "date"
+	case []byte:
+		return "data"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,90 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const generatedExportOptions = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>method</key>
+	<string>ad-hoc</string>
+	<key>provisioningProfiles</key>
+	<dict>
+		<key>io.bitrise.app</key>
+		<string>Ad Hoc Profile</string>
+	</dict>
+	<key>signingStyle</key>
+	<string>manual</string>
+	<key>uploadSymbols</key>
+	<true/>
+</dict>
+</plist>`
+
+func Test_mergeExportOptions(t *testing.T) {
+	custom := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>thinning</key>
+	<string>&lt;thin-for-all-variants&gt;</string>
+	<key>provisioningProfiles</key>
+	<dict>
+		<key>io.bitrise.app.extension</key>
+		<string>Extension Profile</string>
+	</dict>
+	<key>uploadSymbols</key>
+	<false/>
+</dict>
+</plist>`
+
+	merged, changes, err := mergeExportOptions([]byte(generatedExportOptions), []byte(custom))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"method": "ad-hoc",
+		"provisioningProfiles": map[string]interface{}{
+			"io.bitrise.app":           "Ad Hoc Profile",
+			"io.bitrise.app.extension": "Extension Profile",
+		},
+		"signingStyle":  "manual",
+		"thinning":      "<thin-for-all-variants>",
+		"uploadSymbols": false,
+	}, merged)
+	require.Equal(t, []exportOptionChange{
+		{KeyPath: `provisioningProfiles["io.bitrise.app.extension"]`},
+		{KeyPath: "thinning"},
+		{KeyPath: "uploadSymbols", Overridden: true},
+	}, changes)
+}
+
+func Test_mergeExportOptions_TypeConflict(t *testing.T) {
+	custom := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>provisioningProfiles</key>
+	<string>Ad Hoc Profile</string>
+</dict>
+</plist>`
+
+	_, _, err := mergeExportOptions([]byte(generatedExportOptions), []byte(custom))
+	require.EqualError(t, err, "type conflict at key provisioningProfiles: generated value is dictionary, custom value is string")
+
+	custom = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>provisioningProfiles</key>
+	<dict>
+		<key>io.bitrise.app</key>
+		<true/>
+	</dict>
+</dict>
+</plist>`
+
+	_, _, err = mergeExportOptions([]byte(generatedExportOptions), []byte(custom))
+	require.EqualError(t, err, `type conflict at key provisioningProfiles["io.bitrise.app"]: generated value is string, custom value is boolean`)
+}
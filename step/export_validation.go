@@ -0,0 +1,231 @@
+package step
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+	"howett.net/plist"
+)
+
+const (
+	getTaskAllowEntitlementKey               = "get-task-allow"
+	iCloudServicesEntitlementKey             = "com.apple.developer.icloud-services"
+	iCloudContainerEnvironmentEntitlementKey = "com.apple.developer.icloud-container-environment"
+)
+
+// signingCertificateSelectors are the automatic certificate selectors accepted by xcodebuild in the signingCertificate export option,
+// mapped to the common name prefixes of the matching certificates.
+var signingCertificateSelectors = map[string][]string{
+	"iOS Distribution":    {"iPhone Distribution", "Apple Distribution"},
+	"iOS Development":     {"iPhone Developer", "Apple Development"},
+	"iPhone Distribution": {"iPhone Distribution"},
+	"iPhone Developer":    {"iPhone Developer"},
+	"Apple Distribution":  {"Apple Distribution"},
+	"Apple Development":   {"Apple Development"},
+}
+
+// exportOptionsMismatch is an incompatibility between the export options and the archive.
+type exportOptionsMismatch struct {
+	BundleID    string
+	ProfileName string
+	Message     string
+}
+
+// String ...
+func (m exportOptionsMismatch) String() string {
+	if m.ProfileName == "" {
+		return fmt.Sprintf("%s: %s", m.BundleID, m.Message)
+	}
+	return fmt.Sprintf("%s (profile: %s): %s", m.BundleID, m.ProfileName, m.Message)
+}
+
+// preflightExportOptions validates the effective export options against the archive before running the export command,
+// and returns an error listing every mismatch found.
+func (s XcodebuildArchiver) preflightExportOptions(exportOptionsPath string, archive xcarchive.IosArchive) error {
+	s.logger.Println()
+	s.logger.Infof("Validating export options against the archive...")
+
+	content, err := os.ReadFile(exportOptionsPath)
+	if err != nil {
+		return fmt.Errorf("failed to read export options: %w", err)
+	}
+	var exportOptions map[string]interface{}
+	if _, err := plist.Unmarshal(content, &exportOptions); err != nil {
+		return fmt.Errorf("failed to parse export options: %w", err)
+	}
+
+	installedProfiles, err := profileutil.InstalledProvisioningProfileInfos(profileutil.ProfileTypeIos)
+	if err != nil {
+		s.logger.Warnf("Skipping export options validation, failed to list installed provisioning profiles: %s", err)
+		return nil
+	}
+
+	bundleIDProfileMap := archive.BundleIDProfileInfoMap()
+	profiles := installedProfiles
+	for _, profile := range bundleIDProfileMap {
+		profiles = append(profiles, profile)
+	}
+
+	mismatches := validateExportOptions(exportOptions, bundleIDProfileMap, archive.BundleIDEntitlementsMap(), profiles)
+	if len(mismatches) == 0 {
+		s.logger.Donef("Export options match the archive")
+		return nil
+	}
+
+	var lines []string
+	for _, mismatch := range mismatches {
+		s.logger.Errorf("- %s", mismatch)
+		lines = append(lines, "- "+mismatch.String())
+	}
+	return fmt.Errorf("export options don't match the archive:\n%s", strings.Join(lines, "\n"))
+}
+
+// validateExportOptions checks the effective export options against the archived bundles (application, extensions, watch app, app clip)
+// and returns every mismatch found. The provisioning profiles referenced by the export options are looked up in the given profiles.
+// Provisioning profile related checks are only done for manual signing, with automatic signing (xcodebuild's default) xcodebuild selects the profiles.
+func validateExportOptions(exportOptions map[string]interface{}, bundleIDProfileMap map[string]profileutil.ProvisioningProfileInfoModel, bundleIDEntitlementsMap map[string]plistutil.PlistData, profiles []profileutil.ProvisioningProfileInfoModel) []exportOptionsMismatch {
+	var mismatches []exportOptionsMismatch
+
+	exportMethod := exportoptions.MethodDefault
+	if method, ok := exportOptions[exportoptions.MethodKey].(string); ok && method != "" {
+		exportMethod = exportoptions.Method(method)
+	}
+	signingStyle, _ := exportOptions[exportoptions.SigningStyleKey].(string)
+	signingCertificate, _ := exportOptions[exportoptions.SigningCertificateKey].(string)
+	iCloudContainerEnvironment, _ := exportOptions[exportoptions.ICloudContainerEnvironmentKey].(string)
+	profileMapping, _ := exportOptions[exportoptions.ProvisioningProfilesKey].(map[string]interface{})
+
+	bundleIDs := make([]string, 0, len(bundleIDProfileMap))
+	for bundleID := range bundleIDProfileMap {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	sort.Strings(bundleIDs)
+
+	for _, bundleID := range bundleIDs {
+		usesCloudKit := entitlementsUseCloudKit(bundleIDEntitlementsMap[bundleID])
+		// xcodebuild defaults to the Development environment, which is only valid for development exports
+		if usesCloudKit && iCloudContainerEnvironment == "" && (exportMethod.IsAdHoc() || exportMethod.IsEnterprise()) {
+			mismatches = append(mismatches, exportOptionsMismatch{
+				BundleID: bundleID,
+				Message:  fmt.Sprintf("the bundle uses CloudKit, but %s is not set for the %s distribution method", exportoptions.ICloudContainerEnvironmentKey, exportMethod),
+			})
+		}
+
+		if signingStyle != string(exportoptions.SigningStyleManual) {
+			continue
+		}
+
+		profileRef, _ := profileMapping[bundleID].(string)
+		if profileRef == "" {
+			mismatches = append(mismatches, exportOptionsMismatch{
+				BundleID: bundleID,
+				Message:  fmt.Sprintf("no provisioning profile is set in %s", exportoptions.ProvisioningProfilesKey),
+			})
+			continue
+		}
+
+		profile, found := findProfile(profiles, profileRef, bundleID)
+		if !found {
+			mismatches = append(mismatches, exportOptionsMismatch{
+				BundleID:    bundleID,
+				ProfileName: profileRef,
+				Message:     "no installed provisioning profile found with this name or UUID for the bundle ID",
+			})
+			continue
+		}
+
+		newMismatch := func(format string, args ...interface{}) exportOptionsMismatch {
+			return exportOptionsMismatch{BundleID: bundleID, ProfileName: profile.Name, Message: fmt.Sprintf(format, args...)}
+		}
+
+		if !isSameExportMethod(profile.ExportType, exportMethod) {
+			mismatches = append(mismatches, newMismatch("the profile type is %s, but the distribution method is %s", profile.ExportType, exportMethod))
+		}
+
+		if signingCertificate != "" && !profileIncludesCertificate(profile, signingCertificate) {
+			mismatches = append(mismatches, newMismatch("the signing certificate (%s) is not included in the profile's developer certificates", signingCertificate))
+		}
+
+		if getTaskAllow, ok := profile.Entitlements.GetBool(getTaskAllowEntitlementKey); ok && getTaskAllow != exportMethod.IsDevelopment() {
+			mismatches = append(mismatches, newMismatch("the profile's %s entitlement (%t) is not compatible with the %s distribution method", getTaskAllowEntitlementKey, getTaskAllow, exportMethod))
+		}
+
+		if usesCloudKit && iCloudContainerEnvironment != "" {
+			environments, ok := profile.Entitlements.GetStringArray(iCloudContainerEnvironmentEntitlementKey)
+			if ok && !slices.Contains(environments, iCloudContainerEnvironment) {
+				mismatches = append(mismatches, newMismatch("the iCloud container environment (%s) is not allowed by the profile (allowed: %s)", iCloudContainerEnvironment, strings.Join(environments, ", ")))
+			}
+		}
+	}
+
+	return mismatches
+}
+
+func findProfile(profiles []profileutil.ProvisioningProfileInfoModel, profileRef, bundleID string) (profileutil.ProvisioningProfileInfoModel, bool) {
+	for _, profile := range profiles {
+		if (profile.Name == profileRef || profile.UUID == profileRef) && profileMatchesBundleID(profile.BundleID, bundleID) {
+			return profile, true
+		}
+	}
+	return profileutil.ProvisioningProfileInfoModel{}, false
+}
+
+// profileMatchesBundleID supports explicit and wildcard (for example io.bitrise.*) profile bundle IDs.
+func profileMatchesBundleID(profileBundleID, bundleID string) bool {
+	if strings.HasSuffix(profileBundleID, "*") {
+		return strings.HasPrefix(bundleID, strings.TrimSuffix(profileBundleID, "*"))
+	}
+	return profileBundleID == bundleID
+}
+
+func isSameExportMethod(profileMethod, exportMethod exportoptions.Method) bool {
+	switch {
+	case exportMethod.IsAppStore():
+		return profileMethod.IsAppStore()
+	case exportMethod.IsAdHoc():
+		return profileMethod.IsAdHoc()
+	case exportMethod.IsDevelopment():
+		return profileMethod.IsDevelopment()
+	default:
+		return profileMethod == exportMethod
+	}
+}
+
+// profileIncludesCertificate checks if the signing certificate (a certificate selector, common name or SHA-1 fingerprint)
+// matches any of the profile's developer certificates.
+func profileIncludesCertificate(profile profileutil.ProvisioningProfileInfoModel, signingCertificate string) bool {
+	for _, certificate := range profile.DeveloperCertificates {
+		if certificateMatches(certificate, signingCertificate) {
+			return true
+		}
+	}
+	return false
+}
+
+func certificateMatches(certificate certificateutil.CertificateInfoModel, signingCertificate string) bool {
+	if strings.EqualFold(certificate.SHA1Fingerprint, signingCertificate) || certificate.CommonName == signingCertificate {
+		return true
+	}
+	for _, prefix := range signingCertificateSelectors[signingCertificate] {
+		if strings.HasPrefix(certificate.CommonName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func entitlementsUseCloudKit(entitlements plistutil.PlistData) bool {
+	if entitlements == nil {
+		return false
+	}
+	services, ok := entitlements.GetStringArray(iCloudServicesEntitlementKey)
+	return ok && (slices.Contains(services, "CloudKit") || slices.Contains(services, "CloudDocuments"))
+}
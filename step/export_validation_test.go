@@ -0,0 +1,124 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/certificateutil"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	v1plistutil "github.com/bitrise-io/go-xcode/plistutil"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_validateExportOptions(t *testing.T) {
+	distributionCert := certificateutil.CertificateInfoModel{CommonName: "Apple Distribution: Bitrise (TEAM123)", SHA1Fingerprint: "ABCDEF"}
+	developmentCert := certificateutil.CertificateInfoModel{CommonName: "Apple Development: Bitrise (TEAM123)", SHA1Fingerprint: "123456"}
+
+	adHocAppProfile := profileutil.ProvisioningProfileInfoModel{
+		Name:                  "App Ad Hoc",
+		UUID:                  "app-ad-hoc-uuid",
+		BundleID:              "io.bitrise.app",
+		ExportType:            exportoptions.MethodAdHoc,
+		DeveloperCertificates: []certificateutil.CertificateInfoModel{distributionCert},
+		Entitlements: v1plistutil.PlistData{
+			"get-task-allow": false,
+			"com.apple.developer.icloud-container-environment": []interface{}{"Production"},
+		},
+	}
+	developmentExtensionProfile := profileutil.ProvisioningProfileInfoModel{
+		Name:                  "Wildcard Development",
+		UUID:                  "wildcard-development-uuid",
+		BundleID:              "io.bitrise.*",
+		ExportType:            exportoptions.MethodDevelopment,
+		DeveloperCertificates: []certificateutil.CertificateInfoModel{developmentCert},
+		Entitlements:          v1plistutil.PlistData{"get-task-allow": true},
+	}
+	profiles := []profileutil.ProvisioningProfileInfoModel{adHocAppProfile, developmentExtensionProfile}
+
+	archiveProfiles := map[string]profileutil.ProvisioningProfileInfoModel{
+		"io.bitrise.app":           developmentExtensionProfile,
+		"io.bitrise.app.extension": developmentExtensionProfile,
+		"io.bitrise.app.clip":      developmentExtensionProfile,
+	}
+	archiveEntitlements := map[string]plistutil.PlistData{
+		"io.bitrise.app": {"com.apple.developer.icloud-services": []interface{}{"CloudKit"}},
+	}
+
+	tests := []struct {
+		name          string
+		exportOptions map[string]interface{}
+		want          []exportOptionsMismatch
+	}{
+		{
+			name: "matching export options",
+			exportOptions: map[string]interface{}{
+				"method":                     "development",
+				"signingStyle":               "manual",
+				"signingCertificate":         "Apple Development",
+				"iCloudContainerEnvironment": "Development",
+				"provisioningProfiles": map[string]interface{}{
+					"io.bitrise.app":           "Wildcard Development",
+					"io.bitrise.app.extension": "wildcard-development-uuid",
+					"io.bitrise.app.clip":      "Wildcard Development",
+				},
+			},
+		},
+		{
+			name: "iCloud container environment defaults to Development for development exports",
+			exportOptions: map[string]interface{}{
+				"method": "development",
+			},
+		},
+		{
+			name: "automatic signing skips the profile checks",
+			exportOptions: map[string]interface{}{
+				"method":                     "release-testing",
+				"iCloudContainerEnvironment": "Production",
+			},
+		},
+		{
+			name: "all mismatches are reported",
+			exportOptions: map[string]interface{}{
+				"method":             "ad-hoc",
+				"signingStyle":       "manual",
+				"signingCertificate": "Apple Distribution",
+				"provisioningProfiles": map[string]interface{}{
+					"io.bitrise.app":           "App Ad Hoc",
+					"io.bitrise.app.extension": "Wildcard Development",
+					"io.bitrise.app.clip":      "Missing Profile",
+				},
+			},
+			want: []exportOptionsMismatch{
+				{BundleID: "io.bitrise.app", Message: "the bundle uses CloudKit, but iCloudContainerEnvironment is not set for the ad-hoc distribution method"},
+				{BundleID: "io.bitrise.app.clip", ProfileName: "Missing Profile", Message: "no installed provisioning profile found with this name or UUID for the bundle ID"},
+				{BundleID: "io.bitrise.app.extension", ProfileName: "Wildcard Development", Message: "the profile type is development, but the distribution method is ad-hoc"},
+				{BundleID: "io.bitrise.app.extension", ProfileName: "Wildcard Development", Message: "the signing certificate (Apple Distribution) is not included in the profile's developer certificates"},
+				{BundleID: "io.bitrise.app.extension", ProfileName: "Wildcard Development", Message: "the profile's get-task-allow entitlement (true) is not compatible with the ad-hoc distribution method"},
+			},
+		},
+		{
+			name: "missing profile entry and iCloud environment not allowed by the profile",
+			exportOptions: map[string]interface{}{
+				"method":                     "ad-hoc",
+				"signingStyle":               "manual",
+				"iCloudContainerEnvironment": "Development",
+				"provisioningProfiles": map[string]interface{}{
+					"io.bitrise.app":           "App Ad Hoc",
+					"io.bitrise.app.extension": "App Ad Hoc",
+				},
+			},
+			want: []exportOptionsMismatch{
+				{BundleID: "io.bitrise.app", ProfileName: "App Ad Hoc", Message: "the iCloud container environment (Development) is not allowed by the profile (allowed: Production)"},
+				{BundleID: "io.bitrise.app.clip", Message: "no provisioning profile is set in provisioningProfiles"},
+				{BundleID: "io.bitrise.app.extension", ProfileName: "App Ad Hoc", Message: "no installed provisioning profile found with this name or UUID for the bundle ID"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := validateExportOptions(tt.exportOptions, archiveProfiles, archiveEntitlements, profiles)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
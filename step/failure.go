@@ -0,0 +1,228 @@
+package step
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	v1fileutil "github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/v2/errorfinder"
+	cache "github.com/bitrise-io/go-xcode/v2/xcodecache"
+	"github.com/bitrise-steplib/steps-xcode-archive/step/watchdog"
+)
+
+// FailureStage is the xcodebuild action that failed.
+type FailureStage string
+
+const (
+	failureStageArchive FailureStage = "archive"
+	failureStageExport  FailureStage = "export"
+)
+
+// FailureCategory is the likely reason of an archive or export failure.
+type FailureCategory string
+
+const (
+	FailureCategoryCompile                      FailureCategory = "compile_error"
+	FailureCategoryLinker                       FailureCategory = "linker_error"
+	FailureCategoryCodeSigning                  FailureCategory = "code_signing_error"
+	FailureCategorySPMResolution                FailureCategory = "spm_resolution_error"
+	FailureCategoryMissingSchemeOrConfiguration FailureCategory = "missing_scheme_or_configuration"
+	FailureCategoryExport                       FailureCategory = "export_error"
+	FailureCategoryHung                         FailureCategory = "xcodebuild_hung"
+	FailureCategoryUnknown                      FailureCategory = "unknown"
+)
+
+// ErrXcodebuildHung is wrapped by the error of an archive or export action, which was terminated by the watchdog.
+var ErrXcodebuildHung = errors.New("xcodebuild hung")
+
+// FailureSummary is the machine-readable description of an archive or export failure.
+type FailureSummary struct {
+	Stage           FailureStage    `json:"stage"`
+	Category        FailureCategory `json:"category"`
+	FirstError      string          `json:"first_error"`
+	File            string          `json:"file,omitempty"`
+	Line            int             `json:"line,omitempty"`
+	RemediationHint string          `json:"remediation_hint"`
+	Errors          []string        `json:"errors"`
+}
+
+const (
+	exportFailureHint  = "Check the export options (distribution method, team, provisioning profiles) and the attached xcdistributionlogs."
+	compileFailureHint = "Fix the compile error in the source file, and make sure the project builds locally with the same Xcode version and configuration."
+)
+
+type failureRule struct {
+	category FailureCategory
+	patterns []string
+	hint     string
+}
+
+// failureRules are evaluated in order against each error, the earliest error matching a rule decides the category.
+// Patterns are parts of the xcodebuild (and IDEDistribution) error messages, matched case-insensitively.
+var failureRules = []failureRule{
+	{
+		category: FailureCategoryMissingSchemeOrConfiguration,
+		patterns: []string{
+			"does not contain a scheme named",
+			"is not currently configured for the",
+			"does not contain a configuration named",
+			"Could not find a configuration named",
+		},
+		hint: "Make sure the scheme is shared (Xcode: Product > Scheme > Manage Schemes > Shared) and committed, and the Build Configuration input matches an existing configuration.",
+	},
+	{
+		category: FailureCategorySPMResolution,
+		patterns: []string{
+			cache.SwiftPackagesStateInvalid,
+			"Could not resolve package dependencies",
+			"Failed to resolve dependencies",
+			"Package.resolved file is corrupted or malformed",
+			"Failed to clone repository",
+		},
+		hint: "Check that every Swift package repository is reachable from the build machine and that Package.resolved is up to date and committed.",
+	},
+	{
+		category: FailureCategoryCodeSigning,
+		patterns: []string{
+			"No profiles for '",
+			"No profile for team '",
+			"requires a provisioning profile",
+			"doesn't include signing certificate",
+			"doesn't match the entitlements",
+			"requires a development team",
+			"No signing certificate \"",
+			"Code signing is required for product type",
+			"Command CodeSign failed",
+			"errSecInternalComponent",
+			"exportArchive: No Accounts",
+		},
+		hint: "Make sure the provisioning profiles and certificates for every target match the bundle IDs, capabilities and distribution method, or enable automatic code signing.",
+	},
+	{
+		category: FailureCategoryLinker,
+		patterns: []string{
+			"Undefined symbol",
+			"Undefined symbols for architecture",
+			"linker command failed",
+			"symbol(s) not found",
+			"framework not found",
+			"library not found",
+		},
+		hint: "Check that every linked framework and library is built for the archive's architectures and is part of the scheme's build.",
+	},
+	{
+		category: FailureCategoryExport,
+		patterns: []string{
+			"error: exportArchive:",
+			"[MT] IDEDistribution",
+			"Error Domain=IDEFoundationErrorDomain",
+		},
+		hint: exportFailureHint,
+	},
+}
+
+var compileErrorPattern = regexp.MustCompile(`^(?P<file>/[^:]+):(?P<line>\d+):(?:\d+:)? (?:fatal )?error: `)
+
+// classifyFailure sorts the failure of an xcodebuild action into a category,
+// based on the errors found in the xcodebuild log and in the IDEDistribution logs (export only).
+func classifyFailure(stage FailureStage, xcodebuildLog, ideDistributionLogsDir string) FailureSummary {
+	errorLines := errorfinder.FindXcodebuildErrors(xcodebuildLog)
+	if ideDistributionLogsDir != "" {
+		criticalDistLogFilePth := filepath.Join(ideDistributionLogsDir, "IDEDistribution.critical.log")
+		if criticalDistLog, err := v1fileutil.ReadStringFromFile(criticalDistLogFilePth); err == nil {
+			for _, line := range strings.Split(criticalDistLog, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					errorLines = append(errorLines, line)
+				}
+			}
+		}
+	}
+
+	summary := FailureSummary{
+		Stage:    stage,
+		Category: FailureCategoryUnknown,
+		Errors:   errorLines,
+	}
+	if len(errorLines) > 0 {
+		summary.FirstError = errorLines[0]
+	}
+
+	if category, hint, errorLine, ok := matchFailureRules(errorLines); ok {
+		summary.Category = category
+		summary.RemediationHint = hint
+		summary.FirstError = errorLine
+	} else if stage == failureStageExport {
+		summary.Category = FailureCategoryExport
+		summary.RemediationHint = exportFailureHint
+	} else {
+		summary.RemediationHint = fmt.Sprintf("Check the full xcodebuild log (%s) for the reason of the failure.", xcodebuildArchiveLogFilename)
+	}
+
+	if match := compileErrorPattern.FindStringSubmatch(summary.FirstError); match != nil {
+		summary.File = match[1]
+		summary.Line, _ = strconv.Atoi(match[2])
+	}
+
+	return summary
+}
+
+// summarizeFailure classifies the failure of the archive or export action.
+// If the watchdog terminated xcodebuild, the returned error wraps ErrXcodebuildHung, otherwise it is actionErr.
+// The summary is nil if there is nothing to classify (the action failed before running xcodebuild).
+func summarizeFailure(stage FailureStage, actionErr error, xcodebuildLog, ideDistributionLogsDir string, logger log.Logger) (*FailureSummary, error) {
+	var watchdogErr *watchdog.Error
+	if errors.As(actionErr, &watchdogErr) {
+		summary := FailureSummary{
+			Stage:           stage,
+			Category:        FailureCategoryHung,
+			FirstError:      watchdogErr.Error(),
+			RemediationHint: "xcodebuild was terminated by the watchdog. Check the partial xcodebuild log for the last step before the hang, or increase the xcodebuild timeout inputs.",
+			Errors:          []string{watchdogErr.Error()},
+		}
+		printFailureSummary(summary, logger)
+		return &summary, fmt.Errorf("%w in the %s phase: %w", ErrXcodebuildHung, stage, actionErr)
+	}
+
+	if xcodebuildLog == "" {
+		return nil, actionErr
+	}
+
+	summary := classifyFailure(stage, xcodebuildLog, ideDistributionLogsDir)
+	printFailureSummary(summary, logger)
+	return &summary, actionErr
+}
+
+// matchFailureRules returns the category, the hint and the earliest error, which matches a failure rule or is a compile error.
+func matchFailureRules(errorLines []string) (FailureCategory, string, string, bool) {
+	for _, errorLine := range errorLines {
+		lowerErrorLine := strings.ToLower(errorLine)
+		for _, rule := range failureRules {
+			for _, pattern := range rule.patterns {
+				if strings.Contains(lowerErrorLine, strings.ToLower(pattern)) {
+					return rule.category, rule.hint, errorLine, true
+				}
+			}
+		}
+		if compileErrorPattern.MatchString(errorLine) {
+			return FailureCategoryCompile, compileFailureHint, errorLine, true
+		}
+	}
+	return "", "", "", false
+}
+
+func printFailureSummary(summary FailureSummary, logger log.Logger) {
+	logger.Println()
+	logger.Errorf("%s failed, category: %s", summary.Stage, summary.Category)
+	if summary.FirstError != "" {
+		logger.Printf("first error: %s", summary.FirstError)
+	}
+	if summary.File != "" {
+		logger.Printf("location: %s:%d", summary.File, summary.Line)
+	}
+	logger.Warnf("%s", summary.RemediationHint)
+}
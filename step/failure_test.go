@@ -0,0 +1,141 @@
+package step
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-steplib/steps-xcode-archive/step/watchdog"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_classifyFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		stage        FailureStage
+		log          string
+		wantCategory FailureCategory
+		wantFirst    string
+		wantFile     string
+		wantLine     int
+	}{
+		{
+			name:  "compile error",
+			stage: failureStageArchive,
+			log: `CompileSwift normal arm64 /Users/vagrant/git/App/ViewController.swift
+/Users/vagrant/git/App/ViewController.swift:12:9: error: cannot find 'foo' in scope
+** ARCHIVE FAILED **`,
+			wantCategory: FailureCategoryCompile,
+			wantFirst:    "/Users/vagrant/git/App/ViewController.swift:12:9: error: cannot find 'foo' in scope",
+			wantFile:     "/Users/vagrant/git/App/ViewController.swift",
+			wantLine:     12,
+		},
+		{
+			name:  "compile error before an incidental code signing error",
+			stage: failureStageArchive,
+			log: `/Users/vagrant/git/App/ViewController.swift:12:9: error: cannot find 'foo' in scope
+/Users/vagrant/git/App.xcodeproj: error: No profiles for 'io.bitrise.app.widget' were found: Xcode couldn't find any iOS App Development provisioning profiles matching 'io.bitrise.app.widget'. (in target 'Widget' from project 'App')
+** ARCHIVE FAILED **`,
+			wantCategory: FailureCategoryCompile,
+			wantFirst:    "/Users/vagrant/git/App/ViewController.swift:12:9: error: cannot find 'foo' in scope",
+			wantFile:     "/Users/vagrant/git/App/ViewController.swift",
+			wantLine:     12,
+		},
+		{
+			name:         "compile error mentioning code signing",
+			stage:        failureStageArchive,
+			log:          `/Users/vagrant/git/App/SigningInfo.swift:7:5: error: value of type 'Bundle' has no member 'provisioning profile'`,
+			wantCategory: FailureCategoryCompile,
+			wantFirst:    "/Users/vagrant/git/App/SigningInfo.swift:7:5: error: value of type 'Bundle' has no member 'provisioning profile'",
+			wantFile:     "/Users/vagrant/git/App/SigningInfo.swift",
+			wantLine:     7,
+		},
+		{
+			name:  "linker error",
+			stage: failureStageArchive,
+			log: `Ld /Users/vagrant/Library/Developer/Xcode/DerivedData/App/Build/App normal
+error: Undefined symbol: _OBJC_CLASS_$_Foo
+clang: error: linker command failed with exit code 1 (use -v to see invocation)`,
+			wantCategory: FailureCategoryLinker,
+			wantFirst:    "error: Undefined symbol: _OBJC_CLASS_$_Foo",
+		},
+		{
+			name:         "code signing error",
+			stage:        failureStageArchive,
+			log:          `/Users/vagrant/git/App.xcodeproj: error: No profiles for 'io.bitrise.app' were found: Xcode couldn't find any iOS App Development provisioning profiles matching 'io.bitrise.app'. (in target 'App' from project 'App')`,
+			wantCategory: FailureCategoryCodeSigning,
+			wantFirst:    `/Users/vagrant/git/App.xcodeproj: error: No profiles for 'io.bitrise.app' were found: Xcode couldn't find any iOS App Development provisioning profiles matching 'io.bitrise.app'. (in target 'App' from project 'App')`,
+		},
+		{
+			name: "spm resolution error",
+			log: `xcodebuild: error: Could not resolve package dependencies:
+  Failed to clone repository https://github.com/bitrise-io/private.git`,
+			stage:        failureStageArchive,
+			wantCategory: FailureCategorySPMResolution,
+			wantFirst:    "xcodebuild: error: Could not resolve package dependencies:",
+		},
+		{
+			name:         "missing scheme",
+			stage:        failureStageArchive,
+			log:          `xcodebuild: error: The project named "App" does not contain a scheme named "Missing". The "-list" option can be used to find the names of the schemes in the project.`,
+			wantCategory: FailureCategoryMissingSchemeOrConfiguration,
+			wantFirst:    `xcodebuild: error: The project named "App" does not contain a scheme named "Missing". The "-list" option can be used to find the names of the schemes in the project.`,
+		},
+		{
+			name:         "unknown export error falls back to export category",
+			stage:        failureStageExport,
+			log:          `** EXPORT FAILED **`,
+			wantCategory: FailureCategoryExport,
+		},
+		{
+			name:         "unknown archive error",
+			stage:        failureStageArchive,
+			log:          `** ARCHIVE FAILED **`,
+			wantCategory: FailureCategoryUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyFailure(tt.stage, tt.log, "")
+			require.Equal(t, tt.stage, got.Stage)
+			require.Equal(t, tt.wantCategory, got.Category)
+			require.Equal(t, tt.wantFirst, got.FirstError)
+			require.Equal(t, tt.wantFile, got.File)
+			require.Equal(t, tt.wantLine, got.Line)
+			require.NotEmpty(t, got.RemediationHint)
+		})
+	}
+}
+
+func Test_classifyFailure_IDEDistributionLogs(t *testing.T) {
+	logsDir := t.TempDir()
+	criticalLog := "2024-01-01 10:00:00 +0000 [MT] IDEDistribution: Provisioning profile \"App Store\" doesn't include signing certificate \"Apple Distribution\".\n"
+	require.NoError(t, os.WriteFile(filepath.Join(logsDir, "IDEDistribution.critical.log"), []byte(criticalLog), 0600))
+
+	got := classifyFailure(failureStageExport, "** EXPORT FAILED **", logsDir)
+	require.Equal(t, FailureCategoryCodeSigning, got.Category)
+	require.Equal(t, "2024-01-01 10:00:00 +0000 [MT] IDEDistribution: Provisioning profile \"App Store\" doesn't include signing certificate \"Apple Distribution\".", got.FirstError)
+}
+
+func Test_summarizeFailure_Watchdog(t *testing.T) {
+	watchdogErr := &watchdog.Error{Command: "xcodebuild archive", Reason: "no output", Timeout: 15 * time.Minute}
+	actionErr := fmt.Errorf("failed to archive the project: %w", watchdogErr)
+
+	summary, err := summarizeFailure(failureStageArchive, actionErr, "partial log", "", log.NewLogger())
+	require.ErrorIs(t, err, ErrXcodebuildHung)
+	require.ErrorAs(t, err, &watchdogErr)
+	require.Equal(t, FailureCategoryHung, summary.Category)
+	require.Equal(t, failureStageArchive, summary.Stage)
+}
+
+func Test_summarizeFailure_NoLog(t *testing.T) {
+	actionErr := errors.New("failed to read project platform")
+
+	summary, err := summarizeFailure(failureStageArchive, actionErr, "", "", log.NewLogger())
+	require.Nil(t, summary)
+	require.Equal(t, actionErr, err)
+}
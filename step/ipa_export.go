@@ -1,9 +1,18 @@
 package step
 
 import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
 	"github.com/bitrise-io/go-utils/v2/log"
 	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
 	"github.com/bitrise-io/go-xcode/xcodebuild"
+	"howett.net/plist"
 )
 
 func runIPAExportCommand(xcodeCommandRunner xcodecommand.Runner, logFormatter string, exportCmd *xcodebuild.ExportCommandModel, logger log.Logger) (string, error) {
@@ -16,3 +25,103 @@ func runIPAExportCommand(xcodeCommandRunner xcodecommand.Runner, logFormatter st
 
 	return string(output.RawOut), err
 }
+
+// ExportedIPA is an .ipa exported into the output dir, together with the bundle ID of its app.
+type ExportedIPA struct {
+	BundleID string
+	Path     string
+}
+
+// identifyExportedIPAs identifies the archived app's .ipa among the .ipa files of the export dir by its bundle ID.
+// The other .ipa files (for example of an App Clip) are returned sorted by their names, which is their app's bundle ID,
+// or the product name (the .ipa file's name) if the bundle ID can not be read.
+func identifyExportedIPAs(ipaFiles []string, mainBundleID string) (ExportedIPA, []ExportedIPA, error) {
+	var (
+		mains  []ExportedIPA
+		others []ExportedIPA
+		names  = map[string]string{}
+	)
+	for _, pth := range ipaFiles {
+		bundleID, err := readIPABundleID(pth)
+		if err == nil && bundleID == mainBundleID {
+			mains = append(mains, ExportedIPA{BundleID: bundleID, Path: pth})
+			continue
+		}
+
+		name := bundleID
+		if err != nil {
+			name = strings.TrimSuffix(filepath.Base(pth), ".ipa")
+		}
+		if other, ok := names[name]; ok {
+			return ExportedIPA{}, nil, fmt.Errorf("multiple .ipa files with the same name (%s) found: %s, %s", name, other, pth)
+		}
+		names[name] = pth
+		others = append(others, ExportedIPA{BundleID: bundleID, Path: pth})
+	}
+
+	if len(mains) != 1 {
+		var ipaList []string
+		for _, pth := range ipaFiles {
+			bundleID, err := readIPABundleID(pth)
+			if err != nil {
+				bundleID = fmt.Sprintf("unknown bundle ID: %s", err)
+			}
+			ipaList = append(ipaList, fmt.Sprintf("- %s (%s)", pth, bundleID))
+		}
+		return ExportedIPA{}, nil, fmt.Errorf("failed to identify the .ipa of the archived app (%s), %d of the exported .ipa files match:\n%s", mainBundleID, len(mains), strings.Join(ipaList, "\n"))
+	}
+
+	sort.Slice(others, func(i, j int) bool {
+		return exportedIPAName(others[i]) < exportedIPAName(others[j])
+	})
+
+	return mains[0], others, nil
+}
+
+// exportedIPAName is the name of an additional .ipa in the output dir: its app's bundle ID, or the product name if it is unknown.
+func exportedIPAName(ipa ExportedIPA) string {
+	if ipa.BundleID != "" {
+		return ipa.BundleID
+	}
+	return strings.TrimSuffix(filepath.Base(ipa.Path), ".ipa")
+}
+
+// readIPABundleID reads the bundle ID from the Info.plist of the .ipa's app (Payload/<name>.app/Info.plist).
+func readIPABundleID(ipaPath string) (string, error) {
+	reader, err := zip.OpenReader(ipaPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", ipaPath, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	for _, file := range reader.File {
+		if dir, name := path.Split(file.Name); name != "Info.plist" || path.Dir(path.Dir(dir)) != "Payload" || path.Ext(path.Dir(dir)) != ".app" {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		content, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return "", err
+		}
+
+		var infoPlist struct {
+			BundleID string `plist:"CFBundleIdentifier"`
+		}
+		if _, err := plist.Unmarshal(content, &infoPlist); err != nil {
+			return "", fmt.Errorf("failed to parse %s: %w", file.Name, err)
+		}
+		if infoPlist.BundleID == "" {
+			return "", fmt.Errorf("no bundle ID in %s", file.Name)
+		}
+		return infoPlist.BundleID, nil
+	}
+
+	return "", fmt.Errorf("no app found in %s", ipaPath)
+}
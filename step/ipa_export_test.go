@@ -0,0 +1,43 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_identifyExportedIPAs(t *testing.T) {
+	appIPA := writeIPA(t, map[string][]byte{"Payload/Sample.app/Info.plist": infoPlist(t, "io.bitrise.app", "1.0", "1")})
+	clipIPA := writeIPA(t, map[string][]byte{
+		"Payload/Clip.app/Info.plist":                   infoPlist(t, "io.bitrise.app.clip", "1.0", "1"),
+		"Payload/Clip.app/PlugIns/Ext.appex/Info.plist": infoPlist(t, "io.bitrise.app.clip.ext", "1.0", "1"),
+	})
+	unknownIPA := filepath.Join(t.TempDir(), "Unknown.ipa")
+	require.NoError(t, os.WriteFile(unknownIPA, []byte("not a zip"), 0644))
+
+	main, others, err := identifyExportedIPAs([]string{unknownIPA, clipIPA, appIPA}, "io.bitrise.app")
+	require.NoError(t, err)
+	require.Equal(t, ExportedIPA{BundleID: "io.bitrise.app", Path: appIPA}, main)
+	require.Equal(t, []ExportedIPA{
+		{Path: unknownIPA},
+		{BundleID: "io.bitrise.app.clip", Path: clipIPA},
+	}, others)
+	require.Equal(t, "Unknown", exportedIPAName(others[0]))
+
+	// A single .ipa is also identified by its bundle ID
+	main, others, err = identifyExportedIPAs([]string{appIPA}, "io.bitrise.app")
+	require.NoError(t, err)
+	require.Equal(t, ExportedIPA{BundleID: "io.bitrise.app", Path: appIPA}, main)
+	require.Empty(t, others)
+
+	_, _, err = identifyExportedIPAs([]string{clipIPA}, "io.bitrise.app")
+	require.ErrorContains(t, err, "failed to identify the .ipa of the archived app (io.bitrise.app), 0 of the exported .ipa files match")
+
+	_, _, err = identifyExportedIPAs([]string{unknownIPA, clipIPA}, "io.bitrise.app")
+	require.ErrorContains(t, err, "failed to identify the .ipa of the archived app (io.bitrise.app), 0 of the exported .ipa files match")
+
+	_, _, err = identifyExportedIPAs([]string{appIPA, appIPA}, "io.bitrise.app")
+	require.ErrorContains(t, err, "2 of the exported .ipa files match")
+}
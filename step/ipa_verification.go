@@ -0,0 +1,413 @@
+package step
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"howett.net/plist"
+)
+
+const (
+	ipaVerificationOff    = "off"
+	ipaVerificationReport = "report"
+	ipaVerificationFail   = "fail"
+
+	ipaFindingError   = "error"
+	ipaFindingWarning = "warning"
+
+	ipaCheckLayout              = "layout"
+	ipaCheckInfoPlist           = "info_plist"
+	ipaCheckProvisioningProfile = "provisioning_profile"
+	ipaCheckCodeSignature       = "code_signature"
+	ipaCheckSwiftSupport        = "swift_support"
+	ipaCheckArchitecture        = "architecture"
+
+	// loadCmdBuildVersion is LC_BUILD_VERSION, which is not parsed by debug/macho.
+	loadCmdBuildVersion macho.LoadCmd = 0x32
+)
+
+// simulatorPlatforms are the LC_BUILD_VERSION platforms of the simulators (iOS, tvOS, watchOS and visionOS).
+var simulatorPlatforms = map[uint32]string{
+	7:  "iOS Simulator",
+	8:  "tvOS Simulator",
+	9:  "watchOS Simulator",
+	12: "visionOS Simulator",
+}
+
+// ipaTopLevelEntries are the entries xcodebuild may write next to the Payload dir.
+var ipaTopLevelEntries = []string{"Payload", "SwiftSupport", "Symbols", "BCSymbolMaps", "WatchKitSupport2", "MessagesApplicationExtensionSupport", "META-INF", "iTunesMetadata.plist"}
+
+// signedBundleExtensions are the nested bundles which need a code signature.
+var signedBundleExtensions = []string{".app", ".appex", ".framework", ".xpc"}
+
+// IPAVerificationFinding is an issue found in an exported .ipa.
+type IPAVerificationFinding struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+}
+
+// String ...
+func (f IPAVerificationFinding) String() string {
+	if f.Path == "" {
+		return fmt.Sprintf("[%s] %s", f.Check, f.Message)
+	}
+	return fmt.Sprintf("[%s] %s: %s", f.Check, f.Path, f.Message)
+}
+
+// IPAVerificationReport is the result of verifying the contents of an exported .ipa.
+type IPAVerificationReport struct {
+	IPAPath      string                   `json:"ipa_path"`
+	ExportMethod string                   `json:"export_method"`
+	Findings     []IPAVerificationFinding `json:"findings"`
+}
+
+// Errors returns the findings with error severity.
+func (r IPAVerificationReport) Errors() []IPAVerificationFinding {
+	var errs []IPAVerificationFinding
+	for _, finding := range r.Findings {
+		if finding.Severity == ipaFindingError {
+			errs = append(errs, finding)
+		}
+	}
+	return errs
+}
+
+// ipaExpectations are the values the exported .ipa is verified against.
+type ipaExpectations struct {
+	ExportMethod exportoptions.Method
+	BundleID     string
+	Version      string // CFBundleShortVersionString
+	BuildNumber  string // CFBundleVersion
+	TeamID       string // not checked if empty
+}
+
+type ipaVerifier struct {
+	expected ipaExpectations
+	files    map[string]*zip.File
+	findings []IPAVerificationFinding
+}
+
+func (v *ipaVerifier) addFinding(severity, check, pth, format string, args ...interface{}) {
+	v.findings = append(v.findings, IPAVerificationFinding{Severity: severity, Check: check, Path: pth, Message: fmt.Sprintf(format, args...)})
+}
+
+// verifyIPA opens the .ipa and checks its layout, the app's Info.plist, the embedded provisioning profiles,
+// the code signature of every nested bundle, the Swift support dir of App Store exports and the architectures of every Mach-O binary.
+func verifyIPA(ipaPath string, expected ipaExpectations) (IPAVerificationReport, error) {
+	reader, err := zip.OpenReader(ipaPath)
+	if err != nil {
+		return IPAVerificationReport{}, fmt.Errorf("failed to open %s: %w", ipaPath, err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	v := ipaVerifier{expected: expected, files: map[string]*zip.File{}}
+	for _, file := range reader.File {
+		if !file.FileInfo().IsDir() {
+			v.files[file.Name] = file
+		}
+	}
+
+	appPath := v.checkLayout()
+	if appPath != "" {
+		v.checkInfoPlist(appPath)
+		v.checkBundles(appPath)
+		if err := v.checkBinaries(appPath); err != nil {
+			return IPAVerificationReport{}, err
+		}
+	}
+
+	return IPAVerificationReport{IPAPath: ipaPath, ExportMethod: string(expected.ExportMethod), Findings: append([]IPAVerificationFinding{}, v.findings...)}, nil
+}
+
+// sortedPaths returns the file paths of the .ipa in a stable order.
+func (v *ipaVerifier) sortedPaths() []string {
+	var paths []string
+	for pth := range v.files {
+		paths = append(paths, pth)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// checkLayout checks that the .ipa contains a single Payload/<name>.app bundle, and returns its path.
+func (v *ipaVerifier) checkLayout() string {
+	apps := map[string]bool{}
+	unknown := map[string]bool{}
+	for _, pth := range v.sortedPaths() {
+		components := strings.Split(pth, "/")
+		if !slices.Contains(ipaTopLevelEntries, components[0]) {
+			unknown[components[0]] = true
+			continue
+		}
+		if components[0] != "Payload" {
+			continue
+		}
+		if len(components) < 3 || path.Ext(components[1]) != ".app" {
+			v.addFinding(ipaFindingError, ipaCheckLayout, pth, "unexpected file in the Payload dir, it should only contain the .app bundle")
+			continue
+		}
+		apps[path.Join(components[0], components[1])] = true
+	}
+
+	for _, entry := range sortedKeys(unknown) {
+		v.addFinding(ipaFindingWarning, ipaCheckLayout, entry, "unexpected top level entry")
+	}
+
+	switch len(apps) {
+	case 0:
+		v.addFinding(ipaFindingError, ipaCheckLayout, "Payload", "no .app bundle found")
+		return ""
+	case 1:
+		return sortedKeys(apps)[0]
+	default:
+		v.addFinding(ipaFindingError, ipaCheckLayout, "Payload", "multiple .app bundles found: %s", strings.Join(sortedKeys(apps), ", "))
+		return ""
+	}
+}
+
+// checkInfoPlist checks the bundle ID and the versions of the app.
+func (v *ipaVerifier) checkInfoPlist(appPath string) {
+	infoPlistPath := path.Join(appPath, "Info.plist")
+	var infoPlist map[string]interface{}
+	if err := v.readPlist(infoPlistPath, &infoPlist); err != nil {
+		v.addFinding(ipaFindingError, ipaCheckInfoPlist, infoPlistPath, "%s", err)
+		return
+	}
+
+	for _, check := range []struct{ key, expected string }{
+		{"CFBundleIdentifier", v.expected.BundleID},
+		{bundleShortVersionKey, v.expected.Version},
+		{bundleVersionKey, v.expected.BuildNumber},
+	} {
+		if check.expected == "" {
+			continue
+		}
+		value, _ := infoPlist[check.key].(string)
+		if value != check.expected {
+			v.addFinding(ipaFindingError, ipaCheckInfoPlist, infoPlistPath, "%s is %q, expected %q", check.key, value, check.expected)
+		}
+	}
+}
+
+// checkBundles checks the code signature of every nested bundle, and the embedded provisioning profile of every app and app extension.
+func (v *ipaVerifier) checkBundles(appPath string) {
+	bundles := map[string]bool{appPath: true}
+	for pth := range v.files {
+		if !strings.HasPrefix(pth, appPath+"/") {
+			continue
+		}
+		components := strings.Split(pth, "/")
+		for i := range components[:len(components)-1] {
+			if slices.Contains(signedBundleExtensions, path.Ext(components[i])) {
+				bundles[path.Join(components[:i+1]...)] = true
+			}
+		}
+	}
+
+	for _, bundle := range sortedKeys(bundles) {
+		if _, ok := v.files[path.Join(bundle, "_CodeSignature", "CodeResources")]; !ok {
+			v.addFinding(ipaFindingError, ipaCheckCodeSignature, bundle, "missing _CodeSignature")
+		}
+
+		if ext := path.Ext(bundle); ext == ".app" || ext == ".appex" {
+			v.checkProvisioningProfile(bundle)
+		}
+	}
+}
+
+// checkProvisioningProfile checks that the bundle's embedded profile matches the distribution method, the team and the bundle ID.
+func (v *ipaVerifier) checkProvisioningProfile(bundle string) {
+	profilePath := path.Join(bundle, "embedded.mobileprovision")
+	content, err := v.readFile(profilePath)
+	if err != nil {
+		v.addFinding(ipaFindingError, ipaCheckProvisioningProfile, profilePath, "%s", err)
+		return
+	}
+
+	pkcs7, err := profileutil.ProvisioningProfileFromContent(content)
+	if err != nil {
+		v.addFinding(ipaFindingError, ipaCheckProvisioningProfile, profilePath, "failed to parse the provisioning profile: %s", err)
+		return
+	}
+	profile, err := profileutil.NewProvisioningProfileInfo(*pkcs7)
+	if err != nil {
+		v.addFinding(ipaFindingError, ipaCheckProvisioningProfile, profilePath, "failed to read the provisioning profile: %s", err)
+		return
+	}
+
+	if !isSameExportMethod(profile.ExportType, v.expected.ExportMethod) {
+		v.addFinding(ipaFindingError, ipaCheckProvisioningProfile, profilePath, "profile %s is for %s distribution, expected %s", profile.Name, profile.ExportType, v.expected.ExportMethod)
+	}
+	if v.expected.TeamID != "" && profile.TeamID != v.expected.TeamID {
+		v.addFinding(ipaFindingError, ipaCheckProvisioningProfile, profilePath, "profile %s belongs to team %s, expected %s", profile.Name, profile.TeamID, v.expected.TeamID)
+	}
+
+	var infoPlist map[string]interface{}
+	if err := v.readPlist(path.Join(bundle, "Info.plist"), &infoPlist); err == nil {
+		if bundleID, _ := infoPlist["CFBundleIdentifier"].(string); bundleID != "" && !profileMatchesBundleID(profile.BundleID, bundleID) {
+			v.addFinding(ipaFindingError, ipaCheckProvisioningProfile, profilePath, "profile %s is for bundle ID %s, the bundle's ID is %s", profile.Name, profile.BundleID, bundleID)
+		}
+	}
+}
+
+// checkBinaries checks that no Mach-O slice targets a simulator, and that App Store exports embedding the Swift runtime contain SwiftSupport.
+func (v *ipaVerifier) checkBinaries(appPath string) error {
+	embedsSwiftRuntime := false
+	for _, pth := range v.sortedPaths() {
+		if !strings.HasPrefix(pth, appPath+"/") {
+			continue
+		}
+		if name := path.Base(pth); strings.HasPrefix(name, "libswift") && strings.HasSuffix(name, ".dylib") {
+			embedsSwiftRuntime = true
+		}
+
+		machOSlices, err := v.readMachOSlices(pth)
+		if err != nil {
+			return err
+		}
+		for _, slice := range machOSlices {
+			if target := simulatorTarget(slice); target != "" {
+				v.addFinding(ipaFindingError, ipaCheckArchitecture, pth, "the %s slice targets the %s", slice.Cpu, target)
+			}
+			if importsSwiftRuntime(slice) {
+				embedsSwiftRuntime = true
+			}
+		}
+	}
+
+	if v.expected.ExportMethod.IsAppStore() && embedsSwiftRuntime && !v.hasDir("SwiftSupport") {
+		v.addFinding(ipaFindingError, ipaCheckSwiftSupport, "SwiftSupport", "the app embeds the Swift runtime, but the SwiftSupport dir is missing")
+	}
+	return nil
+}
+
+// readMachOSlices returns the slices of the file if it is a (thin or universal) Mach-O binary, otherwise nil.
+func (v *ipaVerifier) readMachOSlices(pth string) ([]*macho.File, error) {
+	file := v.files[pth]
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", pth, err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(rc, magic); err != nil {
+		return nil, nil
+	}
+	switch binary.BigEndian.Uint32(magic) {
+	case macho.Magic32, macho.Magic64, macho.MagicFat, 0xcefaedfe, 0xcffaedfe:
+	default:
+		return nil, nil
+	}
+
+	rest, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", pth, err)
+	}
+	content := bytes.NewReader(append(magic, rest...))
+
+	if fat, err := macho.NewFatFile(content); err == nil {
+		var machOSlices []*macho.File
+		for _, arch := range fat.Arches {
+			machOSlices = append(machOSlices, arch.File)
+		}
+		return machOSlices, nil
+	}
+	thin, err := macho.NewFile(content)
+	if err != nil {
+		v.addFinding(ipaFindingWarning, ipaCheckArchitecture, pth, "failed to parse the Mach-O binary: %s", err)
+		return nil, nil
+	}
+	return []*macho.File{thin}, nil
+}
+
+// simulatorTarget returns the simulator targeted by the slice, or an empty string for device slices.
+func simulatorTarget(slice *macho.File) string {
+	if slice.Cpu == macho.Cpu386 || slice.Cpu == macho.CpuAmd64 {
+		return "Simulator (" + slice.Cpu.String() + " is a simulator architecture)"
+	}
+	for _, load := range slice.Loads {
+		raw := load.Raw()
+		if len(raw) < 12 || macho.LoadCmd(slice.ByteOrder.Uint32(raw[0:4])) != loadCmdBuildVersion {
+			continue
+		}
+		if platform, ok := simulatorPlatforms[slice.ByteOrder.Uint32(raw[8:12])]; ok {
+			return platform
+		}
+	}
+	return ""
+}
+
+// importsSwiftRuntime reports whether the slice loads the Swift runtime from the app bundle (instead of the OS).
+func importsSwiftRuntime(slice *macho.File) bool {
+	libs, err := slice.ImportedLibraries()
+	if err != nil {
+		return false
+	}
+	for _, lib := range libs {
+		if strings.HasPrefix(lib, "@rpath/libswift") {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *ipaVerifier) hasDir(dir string) bool {
+	for pth := range v.files {
+		if strings.HasPrefix(pth, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *ipaVerifier) readFile(pth string) ([]byte, error) {
+	file, ok := v.files[pth]
+	if !ok {
+		return nil, fmt.Errorf("missing %s", path.Base(pth))
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+	return io.ReadAll(rc)
+}
+
+func (v *ipaVerifier) readPlist(pth string, value interface{}) error {
+	content, err := v.readFile(pth)
+	if err != nil {
+		return err
+	}
+	if _, err := plist.Unmarshal(content, value); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path.Base(pth), err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
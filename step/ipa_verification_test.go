@@ -0,0 +1,228 @@
+package step
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/macho"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+	"github.com/fullsailor/pkcs7"
+	"github.com/stretchr/testify/require"
+	"howett.net/plist"
+)
+
+func Test_verifyIPA(t *testing.T) {
+	profile := signedProvisioningProfile(t, "TEAM123", "io.bitrise.app")
+	extensionProfile := signedProvisioningProfile(t, "TEAM123", "io.bitrise.app.widget")
+
+	ipaPath := writeIPA(t, map[string][]byte{
+		"Payload/Sample.app/Info.plist":                                             infoPlist(t, "io.bitrise.app", "1.2.0", "42"),
+		"Payload/Sample.app/Sample":                                                 machOBinary(macho.CpuArm64, 2),
+		"Payload/Sample.app/embedded.mobileprovision":                               profile,
+		"Payload/Sample.app/_CodeSignature/CodeResources":                           {},
+		"Payload/Sample.app/PlugIns/Widget.appex/Info.plist":                        infoPlist(t, "io.bitrise.app.widget", "1.2.0", "42"),
+		"Payload/Sample.app/PlugIns/Widget.appex/embedded.mobileprovision":          extensionProfile,
+		"Payload/Sample.app/PlugIns/Widget.appex/_CodeSignature/CodeResources":      {},
+		"Payload/Sample.app/Frameworks/Core.framework/Core":                         machOBinary(macho.CpuArm64, 2),
+		"Payload/Sample.app/Frameworks/Core.framework/_CodeSignature/CodeResources": {},
+	})
+
+	expected := ipaExpectations{ExportMethod: exportoptions.MethodAppStore, BundleID: "io.bitrise.app", Version: "1.2.0", BuildNumber: "42", TeamID: "TEAM123"}
+	report, err := verifyIPA(ipaPath, expected)
+	require.NoError(t, err)
+	require.Empty(t, report.Findings)
+
+	expected.TeamID = "OTHER"
+	expected.BuildNumber = "43"
+	report, err = verifyIPA(ipaPath, expected)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		`[info_plist] Payload/Sample.app/Info.plist: CFBundleVersion is "42", expected "43"`,
+		"[provisioning_profile] Payload/Sample.app/embedded.mobileprovision: profile Sample Profile belongs to team TEAM123, expected OTHER",
+		"[provisioning_profile] Payload/Sample.app/PlugIns/Widget.appex/embedded.mobileprovision: profile Sample Profile belongs to team TEAM123, expected OTHER",
+	}, findingStrings(report.Errors()))
+}
+
+func Test_verifyIPA_Invalid(t *testing.T) {
+	ipaPath := writeIPA(t, map[string][]byte{
+		"Payload/Sample.app/Info.plist":                     infoPlist(t, "io.bitrise.app", "1.2.0", "42"),
+		"Payload/Sample.app/Sample":                         machOBinary(macho.CpuArm64, 7),
+		"Payload/Sample.app/embedded.mobileprovision":       signedProvisioningProfile(t, "TEAM123", "io.bitrise.app"),
+		"Payload/Sample.app/_CodeSignature/CodeResources":   {},
+		"Payload/Sample.app/Frameworks/Core.framework/Core": machOBinary(macho.CpuAmd64, 2),
+		"Payload/Sample.app/Frameworks/libswiftCore.dylib":  machOBinary(macho.CpuArm64, 2),
+		"Payload/README.txt":                                {},
+		"Extra/notes.txt":                                   {},
+	})
+
+	report, err := verifyIPA(ipaPath, ipaExpectations{ExportMethod: exportoptions.MethodAdHoc, BundleID: "io.bitrise.app"})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"[layout] Payload/README.txt: unexpected file in the Payload dir, it should only contain the .app bundle",
+		"[layout] Extra: unexpected top level entry",
+		"[provisioning_profile] Payload/Sample.app/embedded.mobileprovision: profile Sample Profile is for app-store distribution, expected ad-hoc",
+		"[code_signature] Payload/Sample.app/Frameworks/Core.framework: missing _CodeSignature",
+		"[architecture] Payload/Sample.app/Frameworks/Core.framework/Core: the CpuAmd64 slice targets the Simulator (CpuAmd64 is a simulator architecture)",
+		"[architecture] Payload/Sample.app/Sample: the CpuArm64 slice targets the iOS Simulator",
+	}, findingStrings(report.Findings))
+
+	report, err = verifyIPA(ipaPath, ipaExpectations{ExportMethod: exportoptions.MethodAppStore, BundleID: "io.bitrise.app"})
+	require.NoError(t, err)
+	require.Contains(t, findingStrings(report.Errors()), "[swift_support] SwiftSupport: the app embeds the Swift runtime, but the SwiftSupport dir is missing")
+}
+
+func TestXcodebuildArchiver_verifyExportedIPA(t *testing.T) {
+	exportDir := t.TempDir()
+	// The App Clip's .ipa is found first in the export dir
+	clipIPA := filepath.Join(exportDir, "App Clip.ipa")
+	require.NoError(t, os.Rename(writeIPA(t, map[string][]byte{
+		"Payload/Clip.app/Info.plist": infoPlist(t, "io.bitrise.app.clip", "1.0.0", "1"),
+	}), clipIPA))
+	appIPA := filepath.Join(exportDir, "Sample.ipa")
+	require.NoError(t, os.Rename(writeIPA(t, map[string][]byte{
+		"Payload/Sample.app/Info.plist":                   infoPlist(t, "io.bitrise.app", "1.2.0", "42"),
+		"Payload/Sample.app/Sample":                       machOBinary(macho.CpuArm64, 2),
+		"Payload/Sample.app/embedded.mobileprovision":     signedProvisioningProfile(t, "TEAM123", "io.bitrise.app"),
+		"Payload/Sample.app/_CodeSignature/CodeResources": {},
+	}), appIPA))
+
+	archive := xcarchive.IosArchive{
+		Application: xcarchive.IosApplication{
+			IosBaseApplication: xcarchive.IosBaseApplication{
+				InfoPlist: plistutil.PlistData{
+					"CFBundleIdentifier":         "io.bitrise.app",
+					"CFBundleShortVersionString": "1.2.0",
+					"CFBundleVersion":            "42",
+				},
+			},
+		},
+	}
+
+	archiver := XcodebuildArchiver{logger: log.NewLogger()}
+	report, err := archiver.verifyExportedIPA(IPAExport{ExportMethod: "app-store", IPAExportDir: exportDir}, archive, "TEAM123")
+	require.NoError(t, err)
+	require.Equal(t, appIPA, report.IPAPath)
+	require.Empty(t, report.Findings)
+}
+
+func TestXcodebuildArchiver_checkExportedIPA_UnreadableIPA(t *testing.T) {
+	exportDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(exportDir, "Sample.ipa"), []byte("not a zip archive"), 0600))
+	archive := xcarchive.IosArchive{
+		Application: xcarchive.IosApplication{
+			IosBaseApplication: xcarchive.IosBaseApplication{
+				InfoPlist: plistutil.PlistData{"CFBundleIdentifier": "io.bitrise.app"},
+			},
+		},
+	}
+	archiver := XcodebuildArchiver{logger: log.NewLogger()}
+
+	// The IPA is not verified, which fails the check in fail mode
+	ipaExport := IPAExport{ExportMethod: "ad-hoc", IPAExportDir: exportDir}
+	err := archiver.checkExportedIPA(&ipaExport, archive, "TEAM123", ipaVerificationFail)
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), "IPA verification failed (ad-hoc): "), err.Error())
+	require.Nil(t, ipaExport.Verification)
+
+	// and is only logged in report mode
+	ipaExport = IPAExport{ExportMethod: "ad-hoc", IPAExportDir: exportDir}
+	require.NoError(t, archiver.checkExportedIPA(&ipaExport, archive, "TEAM123", ipaVerificationReport))
+	require.Nil(t, ipaExport.Verification)
+}
+
+func findingStrings(findings []IPAVerificationFinding) []string {
+	var strs []string
+	for _, finding := range findings {
+		strs = append(strs, finding.String())
+	}
+	return strs
+}
+
+func writeIPA(t *testing.T, files map[string][]byte) string {
+	ipaPath := filepath.Join(t.TempDir(), "Sample.ipa")
+	file, err := os.Create(ipaPath)
+	require.NoError(t, err)
+
+	writer := zip.NewWriter(file)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	require.NoError(t, file.Close())
+	return ipaPath
+}
+
+func infoPlist(t *testing.T, bundleID, version, buildNumber string) []byte {
+	content, err := plist.Marshal(map[string]string{
+		"CFBundleIdentifier":         bundleID,
+		"CFBundleShortVersionString": version,
+		"CFBundleVersion":            buildNumber,
+	}, plist.XMLFormat)
+	require.NoError(t, err)
+	return content
+}
+
+// signedProvisioningProfile returns an App Store provisioning profile, signed with a self-signed certificate.
+func signedProvisioningProfile(t *testing.T, teamID, bundleID string) []byte {
+	content, err := plist.Marshal(map[string]interface{}{
+		"Name":           "Sample Profile",
+		"UUID":           "6b3c0f0e-1f2a-4a6e-9f1d-0c5d3e0e2b7a",
+		"TeamIdentifier": []string{teamID},
+		"Platform":       []string{"iOS"},
+		"Entitlements": map[string]interface{}{
+			"application-identifier":              teamID + "." + bundleID,
+			"com.apple.developer.team-identifier": teamID,
+			"get-task-allow":                      false,
+		},
+	}, plist.XMLFormat)
+	require.NoError(t, err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Apple Distribution: Bitrise (" + teamID + ")"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	signedData, err := pkcs7.NewSignedData(content)
+	require.NoError(t, err)
+	require.NoError(t, signedData.AddSigner(certificate, key, pkcs7.SignerInfoConfig{}))
+	signed, err := signedData.Finish()
+	require.NoError(t, err)
+	return signed
+}
+
+// machOBinary returns a 64-bit Mach-O header with a single LC_BUILD_VERSION load command for the platform.
+func machOBinary(cpu macho.Cpu, platform uint32) []byte {
+	const buildVersionSize = 24
+	var content []byte
+	for _, value := range []uint32{
+		macho.Magic64, uint32(cpu), 0, uint32(macho.TypeExec), 1, buildVersionSize, 0, 0, // header
+		uint32(loadCmdBuildVersion), buildVersionSize, platform, 0x000f0000, 0x00110000, 0, // build_version_command
+	} {
+		content = binary.LittleEndian.AppendUint32(content, value)
+	}
+	return content
+}
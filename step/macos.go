@@ -0,0 +1,217 @@
+package step
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	v1pathutil "github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
+	"github.com/bitrise-io/go-xcode/xcodebuild"
+)
+
+// errMacosAutomaticCodeSigning is returned for macOS projects, as automatic code signing only manages iOS, tvOS, watchOS and visionOS assets.
+var errMacosAutomaticCodeSigning = errors.New("automatic code signing is not supported for macOS, install the certificates and profiles before this Step and set Automatic code signing method to off")
+
+type xcodeMacosExportOpts struct {
+	XcodeAuthOptions *xcodebuild.AuthenticationParams
+
+	Archive                         xcarchive.MacosArchive
+	CustomExportOptionsPlistContent string
+	ExportOptionsMode               string
+	ExportMethod                    string
+	ExportDevelopmentTeam           string
+}
+
+func (s XcodebuildArchiver) xcodeMacosExport(opts xcodeMacosExportOpts) (xcodeIPAExportResult, error) {
+	out := xcodeIPAExportResult{}
+
+	s.logger.Println()
+	s.logger.Infof("Collecting export options...")
+
+	tmpDir, err := v1pathutil.NormalizedOSTempDirPath("xcodeMacosExport")
+	if err != nil {
+		return out, fmt.Errorf("failed to create temp dir, error: %s", err)
+	}
+
+	exportOptionsPath := filepath.Join(tmpDir, "export_options.plist")
+
+	generate := func() (exportoptions.ExportOptions, error) {
+		return s.generateMacosArchiveExportOptions(opts)
+	}
+
+	if err := s.writeExportOptions(exportOptionsPath, opts.CustomExportOptionsPlistContent, opts.ExportOptionsMode, generate); err != nil {
+		return out, err
+	}
+
+	exportDir := filepath.Join(tmpDir, "exported")
+
+	s.logger.Println()
+	s.logger.Infof("Exporting macOS app from the archive...")
+	exportOut, err := s.exportArchive(opts.Archive.Path, exportOptionsPath, exportDir, opts.XcodeAuthOptions)
+	if err != nil {
+		return exportOut, fmt.Errorf("failed to export macOS app: %w", err)
+	}
+
+	return exportOut, nil
+}
+
+// generateMacosArchiveExportOptions generates the export options of the archive, based on its embedded provisioning profiles.
+func (s XcodebuildArchiver) generateMacosArchiveExportOptions(opts xcodeMacosExportOpts) (exportoptions.ExportOptions, error) {
+	var archiveExportMethod exportoptions.Method
+	if opts.Archive.Application.ProvisioningProfile != nil {
+		archiveExportMethod = opts.Archive.Application.ProvisioningProfile.ExportType
+	}
+
+	exportMethod, err := determineExportMethod(opts.ExportMethod, archiveExportMethod, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	signingStyle := exportoptions.SigningStyleManual
+	if opts.XcodeAuthOptions != nil {
+		signingStyle = exportoptions.SigningStyleAutomatic
+	}
+
+	xcodeVersion, err := s.xcodeVersionReader.GetVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Xcode version: %w", err)
+	}
+
+	exportOptions, err := generateMacosExportOptions(opts.Archive.BundleIDProfileInfoMap(), exportMethod, signingStyle, opts.ExportDevelopmentTeam, xcodeVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate xcode export options: %s", err)
+	}
+	return exportOptions, nil
+}
+
+// generateMacosExportOptions creates the export options for a macOS archive.
+// The exportoptionsgenerator package only supports iOS-family archives, so the profiles embedded into the archive
+// are used for manual signing, and xcodebuild picks the signing certificates with its automatic selectors.
+func generateMacosExportOptions(bundleIDProfileMap map[string]profileutil.ProvisioningProfileInfoModel, exportMethod exportoptions.Method, signingStyle exportoptions.SigningStyle, teamID string, xcodeVersion xcodeversion.Version) (exportoptions.ExportOptions, error) {
+	if !exportMethod.IsAppStore() && !exportMethod.IsDevelopment() && exportMethod != exportoptions.MethodDeveloperID {
+		return nil, fmt.Errorf("distribution method %s is not available for macOS apps, use one of: app-store, developer-id, development", exportMethod)
+	}
+
+	exportMethod = exportMethodForXcode(exportMethod, xcodeVersion)
+
+	profileMapping := map[string]string{}
+	for bundleID, profile := range bundleIDProfileMap {
+		profileMapping[bundleID] = profile.Name
+		if teamID == "" {
+			teamID = profile.TeamID
+		}
+	}
+
+	if exportMethod.IsAppStore() {
+		options := exportoptions.NewAppStoreConnectOptions(exportMethod)
+		options.TeamID = teamID
+		if signingStyle == exportoptions.SigningStyleManual {
+			options.SigningStyle = signingStyle
+			options.BundleIDProvisioningProfileMapping = profileMapping
+		}
+		return options, nil
+	}
+
+	options := exportoptions.NewNonAppStoreOptions(exportMethod)
+	options.TeamID = teamID
+	if signingStyle == exportoptions.SigningStyleManual {
+		options.SigningStyle = signingStyle
+		options.BundleIDProvisioningProfileMapping = profileMapping
+	}
+	return options, nil
+}
+
+func printMacosArchiveInfo(archive xcarchive.MacosArchive, logger log.Logger) {
+	logger.Println()
+	logger.Infof("Archive info:")
+	logger.Printf("platform: macOS")
+	logger.Printf("signing identity: %s", archive.SigningIdentity())
+
+	profile := archive.Application.ProvisioningProfile
+	if profile == nil {
+		logger.Printf("profile: none embedded")
+		return
+	}
+
+	logger.Printf("team: %s (%s)", profile.TeamName, profile.TeamID)
+	logger.Printf("profile: %s (%s)", profile.Name, profile.UUID)
+	logger.Printf("export: %s", profile.ExportType)
+	logger.Printf("xcode managed profile: %v", profileutil.IsXcodeManaged(profile.Name))
+}
+
+// exportMacosProducts exports the .app (zipped) and .pkg files produced by exporting a macOS archive.
+// The unsuffixed outputs (BITRISE_APP_PATH, BITRISE_PKG_PATH) are only set for the main distribution method.
+func (s XcodebuildArchiver) exportMacosProducts(exportDir, outputDir, artifactName, exportMethod string, isMainExport bool, manifest *ArtifactManifest) error {
+	appPaths, err := filepath.Glob(filepath.Join(exportDir, "*.app"))
+	if err != nil {
+		return fmt.Errorf("failed to search for .app in the export dir, error: %s", err)
+	}
+	pkgPaths, err := filepath.Glob(filepath.Join(exportDir, "*.pkg"))
+	if err != nil {
+		return fmt.Errorf("failed to search for .pkg in the export dir, error: %s", err)
+	}
+
+	if len(appPaths) == 0 && len(pkgPaths) == 0 {
+		s.logger.Printf("File list in the export dir:")
+		if entries, err := os.ReadDir(exportDir); err == nil {
+			for _, entry := range entries {
+				s.logger.Printf("- %s", filepath.Join(exportDir, entry.Name()))
+			}
+		}
+		return fmt.Errorf("No .app or .pkg file found at export dir: %s", exportDir)
+	}
+
+	envKeys := func(envKey string) []string {
+		keys := []string{exportMethodEnvKey(envKey, exportMethod)}
+		if isMainExport {
+			keys = append([]string{envKey}, keys...)
+		}
+		return keys
+	}
+
+	if len(appPaths) > 0 {
+		appZipPath := filepath.Join(outputDir, artifactName+".app.zip")
+		if err := cleanup(appZipPath); err != nil {
+			return err
+		}
+
+		for i, envKey := range envKeys(bitriseAppPthEnvKey) {
+			if i == 0 {
+				if err := ExportOutputDirAsZip(s.outputExporter, appPaths[0], appZipPath, envKey, ZipLevelDefault, s.logger); err != nil {
+					return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+				}
+			} else if err := s.outputExporter.ExportOutput(envKey, appZipPath); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+			s.logger.Donef("The exported app zip path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, appZipPath)
+		}
+		manifest.addArtifact(artifactKindAppZip, appZipPath, exportMethod)
+	}
+
+	if len(pkgPaths) > 0 {
+		pkgPath := filepath.Join(outputDir, artifactName+".pkg")
+		if err := cleanup(pkgPath); err != nil {
+			return err
+		}
+
+		for i, envKey := range envKeys(bitrisePKGPthEnvKey) {
+			if i == 0 {
+				if err := ExportOutputFile(s.outputExporter, pkgPaths[0], pkgPath, envKey); err != nil {
+					return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+				}
+			} else if err := s.outputExporter.ExportOutput(envKey, pkgPath); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+			s.logger.Donef("The pkg path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, pkgPath)
+		}
+		manifest.addArtifact(artifactKindPKG, pkgPath, exportMethod)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_generateMacosExportOptions(t *testing.T) {
+	profiles := map[string]profileutil.ProvisioningProfileInfoModel{
+		"io.bitrise.macos": {Name: "Mac App Store Profile", TeamID: "TEAM123"},
+	}
+	xcode15_2 := xcodeversion.Version{Major: 15, Minor: 2}
+	xcode16 := xcodeversion.Version{Major: 16, Minor: 0}
+
+	tests := []struct {
+		name         string
+		method       exportoptions.Method
+		signingStyle exportoptions.SigningStyle
+		teamID       string
+		xcodeVersion xcodeversion.Version
+		want         map[string]interface{}
+		wantErr      bool
+	}{
+		{
+			name:         "developer-id, manual signing",
+			method:       exportoptions.MethodDeveloperID,
+			signingStyle: exportoptions.SigningStyleManual,
+			xcodeVersion: xcode16,
+			want: map[string]interface{}{
+				exportoptions.MethodKey:               exportoptions.MethodDeveloperID,
+				exportoptions.TeamIDKey:               "TEAM123",
+				exportoptions.SigningStyleKey:         exportoptions.SigningStyleManual,
+				exportoptions.ProvisioningProfilesKey: map[string]string{"io.bitrise.macos": "Mac App Store Profile"},
+			},
+		},
+		{
+			name:         "app-store, automatic signing, new method name",
+			method:       exportoptions.MethodAppStore,
+			signingStyle: exportoptions.SigningStyleAutomatic,
+			teamID:       "OVERRIDE",
+			xcodeVersion: xcode16,
+			want: map[string]interface{}{
+				exportoptions.MethodKey: exportoptions.MethodAppStoreConnect,
+				exportoptions.TeamIDKey: "OVERRIDE",
+			},
+		},
+		{
+			name:         "development, legacy method name",
+			method:       exportoptions.MethodDevelopment,
+			signingStyle: exportoptions.SigningStyleAutomatic,
+			xcodeVersion: xcode15_2,
+			want: map[string]interface{}{
+				exportoptions.MethodKey: exportoptions.MethodDevelopment,
+				exportoptions.TeamIDKey: "TEAM123",
+			},
+		},
+		{
+			name:         "ad-hoc is not available",
+			method:       exportoptions.MethodAdHoc,
+			signingStyle: exportoptions.SigningStyleManual,
+			xcodeVersion: xcode16,
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateMacosExportOptions(profiles, tt.method, tt.signingStyle, tt.teamID, tt.xcodeVersion)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.Hash())
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package step
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+)
+
+const artifactManifestFilename = "xcode-archive-manifest.json"
+
+// Artifact kinds of the manifest
+const (
+	artifactKindXCArchiveZip          = "xcarchive_zip"
+	artifactKindDSYMZip               = "dsym_zip"
+	artifactKindExportOptions         = "export_options"
+	artifactKindIPA                   = "ipa"
+	artifactKindIPAVariant            = "ipa_variant"
+	artifactKindAppZip                = "app_zip"
+	artifactKindPKG                   = "pkg"
+	artifactKindThinningReport        = "app_thinning_size_report"
+	artifactKindThinningReportJSON    = "app_thinning_size_report_json"
+	artifactKindOTAManifest           = "ota_manifest"
+	artifactKindOTAInstallPage        = "ota_install_page"
+	artifactKindIPAVerificationReport = "ipa_verification_report"
+	artifactKindXcresultZip           = "xcresult_zip"
+	artifactKindIDEDistributionLogs   = "xcdistributionlogs_zip"
+	artifactKindArchiveLog            = "xcodebuild_archive_log"
+	artifactKindExportArchiveLog      = "xcodebuild_export_archive_log"
+	artifactKindTimingReport          = "timing_report"
+	artifactKindTimingReportMarkdown  = "timing_report_markdown"
+	artifactKindFailureSummary        = "failure_summary"
+)
+
+// ArtifactManifest describes every artifact the step exported into the output dir, and the archive they were made of.
+type ArtifactManifest struct {
+	Archive   *ArchiveManifest   `json:"archive,omitempty"`
+	Artifacts []ManifestArtifact `json:"artifacts"`
+}
+
+// ManifestArtifact is a file exported into the output dir.
+type ManifestArtifact struct {
+	Kind         string `json:"kind"`
+	Path         string `json:"path"`
+	ExportMethod string `json:"export_method,omitempty"` // set for the products of a distribution method
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+}
+
+// ArchiveManifest describes the archive and how it was built and exported.
+type ArchiveManifest struct {
+	Path              string           `json:"path"`
+	Scheme            string           `json:"scheme,omitempty"`
+	Configuration     string           `json:"configuration,omitempty"`
+	XcodeVersion      string           `json:"xcode_version,omitempty"`
+	XcodeBuildVersion string           `json:"xcode_build_version,omitempty"`
+	TeamID            string           `json:"team_id,omitempty"`
+	ExportMethods     []string         `json:"export_methods,omitempty"`
+	Bundles           []ManifestBundle `json:"bundles"`
+}
+
+// ManifestBundle is the application or an app extension, watch app or app clip of the archive.
+type ManifestBundle struct {
+	BundleID            string           `json:"bundle_id"`
+	Version             string           `json:"version,omitempty"`
+	BuildNumber         string           `json:"build_number,omitempty"`
+	ProvisioningProfile *ManifestProfile `json:"provisioning_profile,omitempty"`
+}
+
+// ManifestProfile is the provisioning profile embedded into a bundle of the archive.
+type ManifestProfile struct {
+	Name           string    `json:"name"`
+	UUID           string    `json:"uuid"`
+	ExpirationDate time.Time `json:"expiration_date"`
+}
+
+// addArtifact records an exported file, its size and checksum are calculated by addDigests.
+func (m *ArtifactManifest) addArtifact(kind, path, exportMethod string) {
+	m.Artifacts = append(m.Artifacts, ManifestArtifact{Kind: kind, Path: path, ExportMethod: exportMethod})
+}
+
+// addDigests calculates the size and the SHA-256 checksum of every artifact.
+func (m *ArtifactManifest) addDigests() error {
+	for i, artifact := range m.Artifacts {
+		size, checksum, err := fileDigest(artifact.Path)
+		if err != nil {
+			return fmt.Errorf("failed to calculate the checksum of %s: %w", artifact.Path, err)
+		}
+		m.Artifacts[i].Size = size
+		m.Artifacts[i].SHA256 = checksum
+	}
+	return nil
+}
+
+func fileDigest(pth string) (int64, string, error) {
+	file, err := os.Open(pth)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// newArchiveManifest describes the iOS or macOS archive, nil is returned if there is no archive.
+func newArchiveManifest(opts ExportOpts) *ArchiveManifest {
+	var (
+		archivePath string
+		teamID      string
+		bundles     []ManifestBundle
+	)
+	if opts.Archive != nil {
+		archivePath = opts.Archive.Path
+		teamID, _ = opts.Archive.TeamID()
+		bundles = iosManifestBundles(opts.Archive.Application)
+	} else if opts.MacosArchive != nil {
+		archivePath = opts.MacosArchive.Path
+		for _, profile := range opts.MacosArchive.BundleIDProfileInfoMap() {
+			teamID = profile.TeamID
+			break
+		}
+		bundles = macosManifestBundles(opts.MacosArchive.Application)
+	} else {
+		return nil
+	}
+
+	manifest := &ArchiveManifest{
+		Path:              archivePath,
+		Scheme:            opts.Scheme,
+		Configuration:     opts.Configuration,
+		XcodeVersion:      opts.XcodeVersion.Version,
+		XcodeBuildVersion: opts.XcodeVersion.BuildVersion,
+		TeamID:            teamID,
+		Bundles:           bundles,
+	}
+	for _, ipaExport := range opts.IPAExports {
+		manifest.ExportMethods = append(manifest.ExportMethods, ipaExport.ExportMethod)
+	}
+
+	return manifest
+}
+
+// iosManifestBundles lists the application, its extensions, watch app (and its extensions) and app clip.
+func iosManifestBundles(app xcarchive.IosApplication) []ManifestBundle {
+	bundles := []ManifestBundle{newManifestBundle(app.InfoPlist, &app.ProvisioningProfile)}
+	for _, extension := range app.Extensions {
+		bundles = append(bundles, newManifestBundle(extension.InfoPlist, &extension.ProvisioningProfile))
+	}
+	if watchApp := app.WatchApplication; watchApp != nil {
+		bundles = append(bundles, newManifestBundle(watchApp.InfoPlist, &watchApp.ProvisioningProfile))
+		for _, extension := range watchApp.Extensions {
+			bundles = append(bundles, newManifestBundle(extension.InfoPlist, &extension.ProvisioningProfile))
+		}
+	}
+	if clipApp := app.ClipApplication; clipApp != nil {
+		bundles = append(bundles, newManifestBundle(clipApp.InfoPlist, &clipApp.ProvisioningProfile))
+	}
+	return bundles
+}
+
+// macosManifestBundles lists the application and its extensions, macOS apps are not necessarily signed with a profile.
+func macosManifestBundles(app xcarchive.MacosApplication) []ManifestBundle {
+	bundles := []ManifestBundle{newManifestBundle(app.InfoPlist, app.ProvisioningProfile)}
+	for _, extension := range app.Extensions {
+		bundles = append(bundles, newManifestBundle(extension.InfoPlist, extension.ProvisioningProfile))
+	}
+	return bundles
+}
+
+func newManifestBundle(infoPlist plistutil.PlistData, profile *profileutil.ProvisioningProfileInfoModel) ManifestBundle {
+	bundleID, _ := infoPlist.GetString("CFBundleIdentifier")
+	version, _ := infoPlist.GetString(bundleShortVersionKey)
+	buildNumber, _ := infoPlist.GetString(bundleVersionKey)
+
+	bundle := ManifestBundle{BundleID: bundleID, Version: version, BuildNumber: buildNumber}
+	if profile != nil && profile.UUID != "" {
+		bundle.ProvisioningProfile = &ManifestProfile{
+			Name:           profile.Name,
+			UUID:           profile.UUID,
+			ExpirationDate: profile.ExpirationDate,
+		}
+	}
+	return bundle
+}
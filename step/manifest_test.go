@@ -0,0 +1,82 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newArchiveManifest(t *testing.T) {
+	expiry := time.Date(2027, time.March, 1, 0, 0, 0, 0, time.UTC)
+	bundle := func(bundleID, profileName string) xcarchive.IosBaseApplication {
+		return xcarchive.IosBaseApplication{
+			InfoPlist: plistutil.PlistData{
+				"CFBundleIdentifier":         bundleID,
+				"CFBundleShortVersionString": "1.2.0",
+				"CFBundleVersion":            "42",
+			},
+			ProvisioningProfile: profileutil.ProvisioningProfileInfoModel{
+				UUID:           profileName + "-uuid",
+				Name:           profileName,
+				TeamID:         "TEAM123",
+				ExpirationDate: expiry,
+			},
+		}
+	}
+
+	opts := ExportOpts{
+		Scheme:        "Sample",
+		Configuration: "Release",
+		XcodeVersion:  xcodeversion.Version{Version: "Xcode 15.4", BuildVersion: "15F31d"},
+		Archive: &xcarchive.IosArchive{
+			Path: "/tmp/Sample.xcarchive",
+			Application: xcarchive.IosApplication{
+				IosBaseApplication: bundle("io.bitrise.app", "App Store Profile"),
+				Extensions:         []xcarchive.IosExtension{{IosBaseApplication: bundle("io.bitrise.app.widget", "Widget Profile")}},
+			},
+		},
+		IPAExports: []IPAExport{{ExportMethod: "app-store"}, {ExportMethod: "ad-hoc"}},
+	}
+
+	require.Equal(t, &ArchiveManifest{
+		Path:              "/tmp/Sample.xcarchive",
+		Scheme:            "Sample",
+		Configuration:     "Release",
+		XcodeVersion:      "Xcode 15.4",
+		XcodeBuildVersion: "15F31d",
+		TeamID:            "TEAM123",
+		ExportMethods:     []string{"app-store", "ad-hoc"},
+		Bundles: []ManifestBundle{
+			{BundleID: "io.bitrise.app", Version: "1.2.0", BuildNumber: "42", ProvisioningProfile: &ManifestProfile{Name: "App Store Profile", UUID: "App Store Profile-uuid", ExpirationDate: expiry}},
+			{BundleID: "io.bitrise.app.widget", Version: "1.2.0", BuildNumber: "42", ProvisioningProfile: &ManifestProfile{Name: "Widget Profile", UUID: "Widget Profile-uuid", ExpirationDate: expiry}},
+		},
+	}, newArchiveManifest(opts))
+
+	require.Nil(t, newArchiveManifest(ExportOpts{}))
+}
+
+func Test_ArtifactManifest_addDigests(t *testing.T) {
+	ipaPath := filepath.Join(t.TempDir(), "Sample.ipa")
+	require.NoError(t, os.WriteFile(ipaPath, []byte("ipa"), 0644))
+
+	var manifest ArtifactManifest
+	manifest.addArtifact(artifactKindIPA, ipaPath, "app-store")
+	require.NoError(t, manifest.addDigests())
+	require.Equal(t, []ManifestArtifact{{
+		Kind:         artifactKindIPA,
+		Path:         ipaPath,
+		ExportMethod: "app-store",
+		Size:         3,
+		SHA256:       "78324857e8d9bfa749dc301271df54a6572de9f4c3df8a9507cfa7b7d2b25f8e",
+	}}, manifest.Artifacts)
+
+	manifest.addArtifact(artifactKindDSYMZip, filepath.Join(t.TempDir(), "missing.zip"), "")
+	require.Error(t, manifest.addDigests())
+}
@@ -0,0 +1,170 @@
+package step
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"howett.net/plist"
+)
+
+const (
+	artifactNamePlaceholder = "{artifact_name}"
+
+	otaManifestFilename = "manifest.plist"
+)
+
+// OTAConfig configures the over-the-air installation of ad-hoc and enterprise IPAs.
+// The URLs may contain the {artifact_name} placeholder.
+type OTAConfig struct {
+	AppURL               string
+	DisplayImageURL      string
+	FullSizeImageURL     string
+	AssetPackManifestURL string
+	// ManifestURL is the URL the exported manifest is published at, used by the install page.
+	ManifestURL string
+}
+
+// IsEmpty ...
+func (c OTAConfig) IsEmpty() bool {
+	return c.AppURL == "" && c.DisplayImageURL == "" && c.FullSizeImageURL == "" && c.AssetPackManifestURL == "" && c.ManifestURL == ""
+}
+
+func (c OTAConfig) validate() error {
+	if c.IsEmpty() {
+		return nil
+	}
+	if c.AppURL == "" || c.DisplayImageURL == "" || c.FullSizeImageURL == "" {
+		return fmt.Errorf("app URL, display image URL and full size image URL are all required for the over-the-air installation manifest")
+	}
+	return nil
+}
+
+// expand replaces the {artifact_name} placeholder in the URLs.
+func (c OTAConfig) expand(artifactName string) OTAConfig {
+	escapedName := url.PathEscape(artifactName)
+	replace := func(value string) string {
+		return strings.ReplaceAll(value, artifactNamePlaceholder, escapedName)
+	}
+	return OTAConfig{
+		AppURL:               replace(c.AppURL),
+		DisplayImageURL:      replace(c.DisplayImageURL),
+		FullSizeImageURL:     replace(c.FullSizeImageURL),
+		AssetPackManifestURL: replace(c.AssetPackManifestURL),
+		ManifestURL:          replace(c.ManifestURL),
+	}
+}
+
+func (c OTAConfig) manifest() exportoptions.Manifest {
+	return exportoptions.Manifest{
+		AppURL:               c.AppURL,
+		DisplayImageURL:      c.DisplayImageURL,
+		FullSizeImageURL:     c.FullSizeImageURL,
+		AssetPackManifestURL: c.AssetPackManifestURL,
+	}
+}
+
+// addOTAManifest adds the manifest to the ad-hoc and enterprise export options, other export options are returned unchanged.
+func addOTAManifest(exportOpts exportoptions.ExportOptions, manifest exportoptions.Manifest) (exportoptions.ExportOptions, bool) {
+	options, ok := exportOpts.(exportoptions.NonAppStoreOptionsModel)
+	if !ok || !(options.Method.IsAdHoc() || options.Method.IsEnterprise()) {
+		return exportOpts, false
+	}
+	options.Manifest = manifest
+	return options, true
+}
+
+// otaManifest is the part of the manifest.plist written by xcodebuild, that is used by the install page.
+type otaManifest struct {
+	Items []struct {
+		Assets []struct {
+			Kind string `plist:"kind"`
+			URL  string `plist:"url"`
+		} `plist:"assets"`
+		Metadata struct {
+			BundleIdentifier string `plist:"bundle-identifier"`
+			BundleVersion    string `plist:"bundle-version"`
+			Title            string `plist:"title"`
+		} `plist:"metadata"`
+	} `plist:"items"`
+}
+
+func readOTAManifest(pth string) (otaManifest, error) {
+	content, err := os.ReadFile(pth)
+	if err != nil {
+		return otaManifest{}, err
+	}
+	var manifest otaManifest
+	if _, err := plist.Unmarshal(content, &manifest); err != nil {
+		return otaManifest{}, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if len(manifest.Items) == 0 {
+		return otaManifest{}, fmt.Errorf("no items found in the manifest")
+	}
+	return manifest, nil
+}
+
+var installPageTemplate = template.Must(template.New("install").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Install {{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Helvetica Neue", sans-serif; margin: 0; padding: 48px 24px; text-align: center; color: #1c1c1e; background: #f2f2f7; }
+main { max-width: 420px; margin: 0 auto; padding: 32px 24px; background: #fff; border-radius: 16px; }
+img { width: 96px; height: 96px; border-radius: 22px; }
+h1 { font-size: 24px; margin: 16px 0 4px; }
+p { color: #6e6e73; margin: 4px 0; }
+a.install { display: inline-block; margin-top: 24px; padding: 14px 32px; border-radius: 12px; background: #0a84ff; color: #fff; font-weight: 600; text-decoration: none; }
+</style>
+</head>
+<body>
+<main>
+{{if .ImageURL}}<img src="{{.ImageURL}}" alt="">{{end}}
+<h1>{{.Title}}</h1>
+<p>{{.BundleIdentifier}}</p>
+<p>Version {{.BundleVersion}}</p>
+<a class="install" href="{{.InstallURL}}">Install</a>
+<p><small>Open this page on the iOS device to install the app.</small></p>
+</main>
+</body>
+</html>
+`))
+
+// renderInstallPage renders a self-contained HTML page with an itms-services link to the manifest published at manifestURL.
+func renderInstallPage(manifest otaManifest, manifestURL string) (string, error) {
+	item := manifest.Items[0]
+
+	var imageURL string
+	for _, asset := range item.Assets {
+		if asset.Kind == "display-image" {
+			imageURL = asset.URL
+		}
+	}
+
+	data := struct {
+		Title            string
+		BundleIdentifier string
+		BundleVersion    string
+		ImageURL         string
+		InstallURL       template.URL
+	}{
+		Title:            item.Metadata.Title,
+		BundleIdentifier: item.Metadata.BundleIdentifier,
+		BundleVersion:    item.Metadata.BundleVersion,
+		ImageURL:         imageURL,
+		// html/template would reject the itms-services scheme, the manifest URL itself is query escaped.
+		InstallURL: template.URL("itms-services://?action=download-manifest&url=" + url.QueryEscape(manifestURL)),
+	}
+
+	var b bytes.Buffer
+	if err := installPageTemplate.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,95 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOTAConfig_expand(t *testing.T) {
+	config := OTAConfig{
+		AppURL:           "https://example.com/builds/{artifact_name}.ipa",
+		DisplayImageURL:  "https://example.com/icon-57.png",
+		FullSizeImageURL: "https://example.com/icon-512.png",
+		ManifestURL:      "https://example.com/builds/{artifact_name}.manifest.plist",
+	}
+	require.NoError(t, config.validate())
+
+	expanded := config.expand("My App-enterprise")
+	require.Equal(t, "https://example.com/builds/My%20App-enterprise.ipa", expanded.AppURL)
+	require.Equal(t, "https://example.com/icon-57.png", expanded.DisplayImageURL)
+	require.Equal(t, "https://example.com/builds/My%20App-enterprise.manifest.plist", expanded.ManifestURL)
+
+	require.EqualError(t, OTAConfig{AppURL: config.AppURL}.validate(), "app URL, display image URL and full size image URL are all required for the over-the-air installation manifest")
+	require.NoError(t, OTAConfig{}.validate())
+}
+
+func Test_addOTAManifest(t *testing.T) {
+	manifest := exportoptions.Manifest{AppURL: "https://example.com/App.ipa", DisplayImageURL: "https://example.com/57.png", FullSizeImageURL: "https://example.com/512.png"}
+
+	for _, method := range []exportoptions.Method{exportoptions.MethodAdHoc, exportoptions.MethodReleaseTesting, exportoptions.MethodEnterprise} {
+		options, added := addOTAManifest(exportoptions.NewNonAppStoreOptions(method), manifest)
+		require.True(t, added, method)
+		require.Equal(t, manifest, options.(exportoptions.NonAppStoreOptionsModel).Manifest)
+	}
+
+	_, added := addOTAManifest(exportoptions.NewNonAppStoreOptions(exportoptions.MethodDevelopment), manifest)
+	require.False(t, added)
+	_, added = addOTAManifest(exportoptions.NewAppStoreOptions(), manifest)
+	require.False(t, added)
+}
+
+func Test_renderInstallPage(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), otaManifestFilename)
+	require.NoError(t, os.WriteFile(manifestPath, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>items</key>
+	<array>
+		<dict>
+			<key>assets</key>
+			<array>
+				<dict>
+					<key>kind</key>
+					<string>software-package</string>
+					<key>url</key>
+					<string>https://example.com/App.ipa</string>
+				</dict>
+				<dict>
+					<key>kind</key>
+					<string>display-image</string>
+					<key>url</key>
+					<string>https://example.com/57.png</string>
+				</dict>
+			</array>
+			<key>metadata</key>
+			<dict>
+				<key>bundle-identifier</key>
+				<string>io.bitrise.app</string>
+				<key>bundle-version</key>
+				<string>1.2.0</string>
+				<key>kind</key>
+				<string>software</string>
+				<key>title</key>
+				<string>Sample &amp; App</string>
+			</dict>
+		</dict>
+	</array>
+</dict>
+</plist>`), 0600))
+
+	manifest, err := readOTAManifest(manifestPath)
+	require.NoError(t, err)
+
+	page, err := renderInstallPage(manifest, "https://example.com/builds/App.manifest.plist?token=a&b=c")
+	require.NoError(t, err)
+	require.Contains(t, page, `<title>Install Sample &amp; App</title>`)
+	require.Contains(t, page, `<img src="https://example.com/57.png" alt="">`)
+	require.Contains(t, page, `<p>io.bitrise.app</p>`)
+	require.Contains(t, page, `<p>Version 1.2.0</p>`)
+	require.Contains(t, page, `href="itms-services://?action=download-manifest&amp;url=https%3A%2F%2Fexample.com%2Fbuilds%2FApp.manifest.plist%3Ftoken%3Da%26b%3Dc"`)
+}
@@ -0,0 +1,184 @@
+package step
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+)
+
+// Output exporters, selected by the output_exporter input
+const (
+	outputExporterAuto   = "auto"
+	outputExporterEnvman = "envman"
+	outputExporterDotenv = "dotenv"
+	outputExporterGithub = "github"
+	outputExporterJSON   = "json"
+)
+
+// OutputExporter makes a Step output available for the subsequent steps of the workflow.
+type OutputExporter interface {
+	ExportOutput(key, value string) error
+	// Describe returns where the outputs are exported to, for logging.
+	Describe() string
+}
+
+// detectOutputExporter selects envman when the Step runs in the Bitrise CLI (envman's env store is set),
+// the GitHub Actions output file when it runs in GitHub Actions, and envman otherwise.
+func detectOutputExporter(envmanEnvstorePath, githubOutputPath string) string {
+	if envmanEnvstorePath == "" && githubOutputPath != "" {
+		return outputExporterGithub
+	}
+	return outputExporterEnvman
+}
+
+// newOutputExporter creates the output exporter, the dotenv and JSON files require a path,
+// the GitHub Actions output file defaults to $GITHUB_OUTPUT.
+func newOutputExporter(exporter, path, githubOutputPath string, cmdFactory command.Factory) (OutputExporter, error) {
+	switch exporter {
+	case outputExporterEnvman:
+		return NewEnvmanOutputExporter(cmdFactory), nil
+	case outputExporterDotenv:
+		if path == "" {
+			return nil, fmt.Errorf("the %s exporter requires an output file path", exporter)
+		}
+		return NewDotenvOutputExporter(path), nil
+	case outputExporterGithub:
+		if path == "" {
+			path = githubOutputPath
+		}
+		if path == "" {
+			return nil, fmt.Errorf("the %s exporter requires an output file path, GITHUB_OUTPUT is not set", exporter)
+		}
+		return NewGithubOutputExporter(path), nil
+	case outputExporterJSON:
+		if path == "" {
+			return nil, fmt.Errorf("the %s exporter requires an output file path", exporter)
+		}
+		return NewJSONOutputExporter(path), nil
+	default:
+		return nil, fmt.Errorf("unknown output exporter: %s", exporter)
+	}
+}
+
+type envmanOutputExporter struct {
+	cmdFactory command.Factory
+}
+
+// NewEnvmanOutputExporter exports the outputs with `envman add`, into the Bitrise CLI's env store.
+func NewEnvmanOutputExporter(cmdFactory command.Factory) OutputExporter {
+	return envmanOutputExporter{cmdFactory: cmdFactory}
+}
+
+// ExportOutput ...
+func (e envmanOutputExporter) ExportOutput(key, value string) error {
+	cmd := e.cmdFactory.Create("envman", []string{"add", "--key", key}, &command.Opts{Stdin: strings.NewReader(value)})
+	return cmd.Run()
+}
+
+// Describe ...
+func (e envmanOutputExporter) Describe() string {
+	return "the Environment Variables (envman)"
+}
+
+type dotenvOutputExporter struct {
+	path string
+}
+
+// NewDotenvOutputExporter appends the outputs to a dotenv file, as double-quoted `KEY="value"` lines.
+// `$` is escaped too, as dotenv loaders expand variables in double-quoted values.
+func NewDotenvOutputExporter(path string) OutputExporter {
+	return dotenvOutputExporter{path: path}
+}
+
+// ExportOutput ...
+func (e dotenvOutputExporter) ExportOutput(key, value string) error {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`).Replace(value)
+	return appendToFile(e.path, fmt.Sprintf("%s=\"%s\"\n", key, escaped))
+}
+
+// Describe ...
+func (e dotenvOutputExporter) Describe() string {
+	return "the dotenv file " + e.path
+}
+
+type githubOutputExporter struct {
+	path string
+}
+
+// NewGithubOutputExporter appends the outputs to a GitHub Actions output file (`$GITHUB_OUTPUT`).
+func NewGithubOutputExporter(path string) OutputExporter {
+	return githubOutputExporter{path: path}
+}
+
+// ExportOutput writes single-line values as `key=value`, and multi-line values with a random heredoc delimiter.
+func (e githubOutputExporter) ExportOutput(key, value string) error {
+	if !strings.ContainsAny(value, "\r\n") {
+		return appendToFile(e.path, fmt.Sprintf("%s=%s\n", key, value))
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(random)
+	return appendToFile(e.path, fmt.Sprintf("%s<<%s\n%s\n%s\n", key, delimiter, value, delimiter))
+}
+
+// Describe ...
+func (e githubOutputExporter) Describe() string {
+	return "the GitHub Actions output file " + e.path
+}
+
+type jsonOutputExporter struct {
+	path string
+}
+
+// NewJSONOutputExporter writes the outputs into a JSON object, the outputs already in the file are kept.
+func NewJSONOutputExporter(path string) OutputExporter {
+	return jsonOutputExporter{path: path}
+}
+
+// ExportOutput ...
+func (e jsonOutputExporter) ExportOutput(key, value string) error {
+	outputs := map[string]string{}
+	content, err := os.ReadFile(e.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(content) > 0 {
+		if err := json.Unmarshal(content, &outputs); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", e.path, err)
+		}
+	}
+	outputs[key] = value
+
+	content, err = json.MarshalIndent(outputs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(e.path, content, 0644)
+}
+
+// Describe ...
+func (e jsonOutputExporter) Describe() string {
+	return "the JSON file " + e.path
+}
+
+func appendToFile(path, content string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
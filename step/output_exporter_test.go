@@ -0,0 +1,84 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_detectOutputExporter(t *testing.T) {
+	require.Equal(t, outputExporterEnvman, detectOutputExporter("", ""))
+	require.Equal(t, outputExporterEnvman, detectOutputExporter("/tmp/envstore.yml", "/tmp/github_output"))
+	require.Equal(t, outputExporterGithub, detectOutputExporter("", "/tmp/github_output"))
+}
+
+func Test_newOutputExporter(t *testing.T) {
+	exporter, err := newOutputExporter(outputExporterGithub, "", "/tmp/github_output", nil)
+	require.NoError(t, err)
+	require.Equal(t, githubOutputExporter{path: "/tmp/github_output"}, exporter)
+
+	_, err = newOutputExporter(outputExporterGithub, "", "", nil)
+	require.EqualError(t, err, "the github exporter requires an output file path, GITHUB_OUTPUT is not set")
+
+	_, err = newOutputExporter(outputExporterDotenv, "", "/tmp/github_output", nil)
+	require.EqualError(t, err, "the dotenv exporter requires an output file path")
+
+	_, err = newOutputExporter(outputExporterJSON, "", "", nil)
+	require.EqualError(t, err, "the json exporter requires an output file path")
+}
+
+func Test_dotenvOutputExporter(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "outputs.env")
+	exporter := NewDotenvOutputExporter(pth)
+
+	require.NoError(t, exporter.ExportOutput(bitriseIPAPthEnvKey, "/deploy/Sample.ipa"))
+	require.NoError(t, exporter.ExportOutput(bitriseIPAPthsEnvKey, "/deploy/\"Sample\".ipa\n\\deploy"))
+	require.NoError(t, exporter.ExportOutput(bitriseDSYMPthEnvKey, "/deploy/$HOME/${BUILD}.dSYM.zip"))
+
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	require.Equal(t, `BITRISE_IPA_PATH="/deploy/Sample.ipa"
+BITRISE_IPA_PATHS="/deploy/\"Sample\".ipa\n\\deploy"
+BITRISE_DSYM_PATH="/deploy/\$HOME/\${BUILD}.dSYM.zip"
+`, string(content))
+	require.Equal(t, "the dotenv file "+pth, exporter.Describe())
+}
+
+func Test_githubOutputExporter(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "github_output")
+	exporter := NewGithubOutputExporter(pth)
+
+	require.NoError(t, exporter.ExportOutput(bitriseIPAPthEnvKey, "/deploy/Sample.ipa"))
+	require.NoError(t, exporter.ExportOutput(bitriseIPAPthsEnvKey, "/deploy/Sample.ipa\n/deploy/Sample-ad-hoc.ipa"))
+
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	require.Regexp(t, regexp.MustCompile(`^BITRISE_IPA_PATH=/deploy/Sample.ipa
+BITRISE_IPA_PATHS<<(ghadelimiter_[0-9a-f]{32})
+/deploy/Sample.ipa
+/deploy/Sample-ad-hoc.ipa
+ghadelimiter_[0-9a-f]{32}
+$`), string(content))
+}
+
+func Test_jsonOutputExporter(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "outputs.json")
+	exporter := NewJSONOutputExporter(pth)
+
+	require.NoError(t, exporter.ExportOutput(bitriseIPAPthEnvKey, "/deploy/old.ipa"))
+	require.NoError(t, exporter.ExportOutput(bitriseDSYMPthEnvKey, "/deploy/Sample.dSYM.zip"))
+	require.NoError(t, exporter.ExportOutput(bitriseIPAPthEnvKey, "/deploy/Sample.ipa"))
+
+	content, err := os.ReadFile(pth)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+  "BITRISE_DSYM_PATH": "/deploy/Sample.dSYM.zip",
+  "BITRISE_IPA_PATH": "/deploy/Sample.ipa"
+}`, string(content))
+
+	require.NoError(t, os.WriteFile(pth, []byte("not json"), 0644))
+	require.ErrorContains(t, exporter.ExportOutput(bitriseIPAPthEnvKey, "/deploy/Sample.ipa"), "failed to parse")
+}
@@ -0,0 +1,333 @@
+package step
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	v1command "github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"howett.net/plist"
+)
+
+const (
+	planFilename         = "xcode-archive-plan.json"
+	bitrisePlanPthEnvKey = "BITRISE_XCODE_ARCHIVE_PLAN_PATH"
+
+	// planTempDir stands for the temporary directories created by a real run.
+	planTempDir = "<temp dir>"
+
+	planModeArchiveAndExport = "archive-and-export"
+	planModeArchiveOnly      = "archive-only"
+	planModeExportOnly       = "export-only"
+)
+
+// PlanCommand is an xcodebuild command the Step would run.
+type PlanCommand struct {
+	Description string   `json:"description"`
+	Args        []string `json:"args"`
+}
+
+// PlanExportOptions describes the export options the Step would use for a distribution method.
+type PlanExportOptions struct {
+	ExportMethod string `json:"export_method"`
+	Source       string `json:"source"`
+	Content      string `json:"content,omitempty"`
+}
+
+// Plan describes what the Step would do, without running xcodebuild.
+type Plan struct {
+	Mode              string              `json:"mode"`
+	ProjectPath       string              `json:"project_path,omitempty"`
+	Scheme            string              `json:"scheme,omitempty"`
+	Configuration     string              `json:"configuration,omitempty"`
+	ArchivePath       string              `json:"archive_path,omitempty"`
+	Platform          string              `json:"platform"`
+	XcodeMajorVersion int                 `json:"xcode_major_version"`
+	ArtifactName      string              `json:"artifact_name"`
+	CodeSigning       string              `json:"code_signing"`
+	ExportMethods     []string            `json:"export_methods,omitempty"`
+	XcconfigPath      string              `json:"xcconfig_path,omitempty"`
+	XcconfigContent   string              `json:"xcconfig_content,omitempty"`
+	Commands          []PlanCommand       `json:"commands"`
+	ExportOptions     []PlanExportOptions `json:"export_options,omitempty"`
+}
+
+// Plan resolves the platform, artifact name, code signing strategy, xcodebuild commands and export options of a run,
+// without preparing code signing assets or running xcodebuild. Temporary paths are replaced with a placeholder.
+func (s XcodebuildArchiver) Plan(opts RunOpts) (Plan, error) {
+	s.logger.Println()
+	s.logger.Infof("Dry run, planning the Step run without running xcodebuild")
+
+	isExportOnly := opts.Archive != nil || opts.MacosArchive != nil
+	opts.XcodebuildAdditionalOptions = withDerivedDataPath(opts.XcodebuildAdditionalOptions, opts.DerivedDataPath)
+
+	plan := Plan{
+		Mode:              planModeArchiveAndExport,
+		Platform:          string(opts.DestinationPlatform),
+		XcodeMajorVersion: opts.XcodeMajorVersion,
+		CodeSigning:       planCodeSigning(opts),
+	}
+	if opts.SkipExport {
+		plan.Mode = planModeArchiveOnly
+	} else {
+		plan.ExportMethods = opts.ExportMethods
+	}
+
+	artifactName, err := s.resolveArtifactName(opts, isExportOnly)
+	if err != nil {
+		return Plan{}, err
+	}
+	// The artifact name template can only be resolved in export-only mode, otherwise it depends on the archive created by the run
+	artifactNames := newArtifactNameTemplate(opts.ArtifactName, artifactName, opts.Configuration, opts.Archive, opts.MacosArchive)
+	plan.ArtifactName = artifactName
+	if isArtifactNameTemplate(opts.ArtifactName) {
+		plan.ArtifactName = opts.ArtifactName
+		if isExportOnly && len(opts.ExportMethods) > 0 {
+			plan.ArtifactName = artifactNames.name(opts.ExportMethods[0])
+		}
+	}
+	opts.ArtifactName = artifactName
+
+	archivePath := filepath.Join(planTempDir, opts.ArtifactName+".xcarchive")
+	if isExportOnly {
+		plan.Mode = planModeExportOnly
+		if opts.MacosArchive != nil {
+			archivePath = opts.MacosArchive.Path
+			plan.Platform = string(osX)
+		} else {
+			archivePath = opts.Archive.Path
+		}
+		plan.ArchivePath = archivePath
+	} else {
+		plan.ProjectPath = opts.ProjectPath
+		plan.Scheme = opts.Scheme
+		plan.Configuration = opts.Configuration
+
+		if opts.XcodeMajorVersion >= 11 {
+			plan.Commands = append(plan.Commands, PlanCommand{
+				Description: "resolve packages",
+				Args:        resolvePackagesCommandArgs(opts.ProjectPath, opts.Scheme, opts.Configuration, opts.XcodebuildAdditionalOptions),
+			})
+		}
+
+		platform := opts.DestinationPlatform
+		if platform == detectPlatform {
+			if platform, err = BuildableTargetPlatform(s.logger, opts.ProjectManager); err != nil {
+				return Plan{}, fmt.Errorf("failed to read project platform: %s: %s", opts.ProjectPath, err)
+			}
+		}
+		plan.Platform = string(platform)
+
+		if opts.XcconfigContent != "" {
+			if strings.HasSuffix(opts.XcconfigContent, ".xcconfig") {
+				plan.XcconfigPath = opts.XcconfigContent
+			} else {
+				plan.XcconfigPath = filepath.Join(planTempDir, "temp.xcconfig")
+				plan.XcconfigContent = opts.XcconfigContent
+			}
+		}
+
+		archiveCmd, _, _ := s.newArchiveCommand(xcodeArchiveOpts{
+			ProjectPath:         opts.ProjectPath,
+			Scheme:              opts.Scheme,
+			DestinationPlatform: platform,
+			Configuration:       opts.Configuration,
+			XcodeMajorVersion:   opts.XcodeMajorVersion,
+			ArtifactName:        opts.ArtifactName,
+			PerformCleanAction:  opts.PerformCleanAction,
+			AdditionalOptions:   opts.XcodebuildAdditionalOptions,
+			BuildTimingSummary:  opts.BuildTimingSummary,
+		}, planTempDir, plan.XcconfigPath)
+		plan.Commands = append(plan.Commands, PlanCommand{
+			Description: "archive",
+			Args:        append([]string{"xcodebuild"}, archiveCmd.CommandArgs()...),
+		})
+	}
+
+	if !opts.SkipExport {
+		for i, exportMethod := range opts.ExportMethods {
+			exportCmd := newExportCommand(archivePath, filepath.Join(planTempDir, "export_options.plist"), filepath.Join(planTempDir, "exported"), nil)
+			plan.Commands = append(plan.Commands, PlanCommand{
+				Description: fmt.Sprintf("export (%s)", exportMethod),
+				Args:        append([]string{"xcodebuild"}, exportCmd.CommandArgs()...),
+			})
+
+			exportOptions, err := s.planExportOptions(opts, exportMethod, artifactNames.exportName(exportMethod, i == 0), isExportOnly)
+			if err != nil {
+				return Plan{}, err
+			}
+			plan.ExportOptions = append(plan.ExportOptions, exportOptions)
+		}
+	}
+
+	s.printPlan(plan)
+
+	return plan, nil
+}
+
+// ExportPlan writes the plan as JSON into the output dir.
+func (s XcodebuildArchiver) ExportPlan(plan Plan, outputDir string) error {
+	planPath := filepath.Join(outputDir, planFilename)
+	if err := cleanup(planPath); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the plan: %w", err)
+	}
+	if err := ExportOutputFileContent(s.outputExporter, string(content), planPath, bitrisePlanPthEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s: %w", bitrisePlanPthEnvKey, err)
+	}
+	s.logger.Donef("The plan path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitrisePlanPthEnvKey, planPath)
+
+	return nil
+}
+
+// resolvePackagesCommandArgs returns the arguments of the xcodebuild.ResolvePackagesCommandModel command run before the archive action,
+// as the command model doesn't expose them.
+func resolvePackagesCommandArgs(projectPath, scheme, configuration string, additionalOptions []string) []string {
+	args := []string{"xcodebuild"}
+	if filepath.Ext(projectPath) == ".xcworkspace" {
+		args = append(args, "-workspace", projectPath)
+	} else {
+		args = append(args, "-project", projectPath)
+	}
+	if scheme != "" {
+		args = append(args, "-scheme", scheme)
+	}
+	if configuration != "" {
+		args = append(args, "-configuration", configuration)
+	}
+	args = append(args, "-resolvePackageDependencies")
+	return append(args, additionalOptions...)
+}
+
+// planCodeSigning describes the code signing mode, the code signing assets are not prepared in a dry run.
+func planCodeSigning(opts RunOpts) string {
+	if opts.CodeSigningAuthSource == codeSignSourceOff {
+		return "automatic code signing is off, the installed certificates and provisioning profiles are used"
+	}
+
+	exportMethods := opts.ExportMethods
+	if opts.SkipExport {
+		exportMethods = []string{string(exportoptions.MethodDevelopment)}
+	}
+	return fmt.Sprintf("automatic code signing (%s), certificates and provisioning profiles would be prepared for: %s", opts.CodeSigningAuthSource, strings.Join(exportMethods, ", "))
+}
+
+// planExportOptions returns the export options for the distribution method.
+// The export options can only be generated in export-only mode, otherwise they are generated based on the archive created by the run.
+func (s XcodebuildArchiver) planExportOptions(opts RunOpts, exportMethod, artifactName string, isExportOnly bool) (PlanExportOptions, error) {
+	out := PlanExportOptions{ExportMethod: exportMethod}
+	isMerge := opts.CustomExportOptionsPlistContent != "" && opts.ExportOptionsMode == ExportOptionsModeMerge
+
+	if opts.CustomExportOptionsPlistContent != "" && !isMerge {
+		out.Source = "custom"
+		out.Content = opts.CustomExportOptionsPlistContent
+		return out, nil
+	}
+
+	if !isExportOnly {
+		out.Source = "generated after the archive action, based on the archive's code signing"
+		if isMerge {
+			out.Source += ", the custom export options are merged over them"
+			out.Content = opts.CustomExportOptionsPlistContent
+		}
+		return out, nil
+	}
+
+	var generated exportoptions.ExportOptions
+	var err error
+	if opts.MacosArchive != nil {
+		generated, err = s.generateMacosArchiveExportOptions(xcodeMacosExportOpts{
+			Archive:               *opts.MacosArchive,
+			ExportMethod:          exportMethod,
+			ExportDevelopmentTeam: opts.ExportDevelopmentTeam,
+		})
+	} else {
+		generated, err = s.generateIosExportOptions(xcodeIPAExportOpts{
+			XcodeMajorVersion:             opts.XcodeMajorVersion,
+			Archive:                       *opts.Archive,
+			ExportMethod:                  exportMethod,
+			TestFlightInternalTestingOnly: opts.TestFlightInternalTestingOnly,
+			ICloudContainerEnvironment:    opts.ICloudContainerEnvironment,
+			ExportDevelopmentTeam:         opts.ExportDevelopmentTeam,
+			UploadBitcode:                 opts.UploadBitcode,
+			CompileBitcode:                opts.CompileBitcode,
+			OTA:                           opts.OTA.expand(artifactName),
+			ProfileOverrides:              opts.ProfileOverrides,
+			ExportSigningCertificate:      opts.ExportSigningCertificate,
+			Thinning:                      opts.Thinning,
+			Upload:                        opts.ExportDestination == exportDestinationUpload && exportoptions.Method(exportMethod).IsAppStore(),
+		})
+	}
+	if err != nil {
+		return out, err
+	}
+
+	content, err := generated.String()
+	if err != nil {
+		return out, err
+	}
+	out.Source = "generated"
+	out.Content = content
+
+	if isMerge {
+		merged, _, err := mergeExportOptions([]byte(content), []byte(opts.CustomExportOptionsPlistContent))
+		if err != nil {
+			return out, fmt.Errorf("failed to merge custom export options: %w", err)
+		}
+		mergedContent, err := plist.MarshalIndent(merged, plist.XMLFormat, "\t")
+		if err != nil {
+			return out, fmt.Errorf("failed to marshal merged export options: %w", err)
+		}
+		out.Source = "generated, with the custom export options merged over them"
+		out.Content = string(mergedContent)
+	}
+
+	return out, nil
+}
+
+func (s XcodebuildArchiver) printPlan(plan Plan) {
+	s.logger.Println()
+	s.logger.Infof("Plan:")
+	s.logger.Printf("- mode: %s", plan.Mode)
+	if plan.ArchivePath != "" {
+		s.logger.Printf("- archive path: %s", plan.ArchivePath)
+	} else {
+		s.logger.Printf("- project path: %s", plan.ProjectPath)
+		s.logger.Printf("- scheme: %s", plan.Scheme)
+		s.logger.Printf("- configuration: %s", plan.Configuration)
+	}
+	s.logger.Printf("- platform: %s", plan.Platform)
+	s.logger.Printf("- artifact name: %s", plan.ArtifactName)
+	s.logger.Printf("- code signing: %s", plan.CodeSigning)
+	if len(plan.ExportMethods) > 0 {
+		s.logger.Printf("- distribution methods: %s", strings.Join(plan.ExportMethods, ", "))
+	}
+
+	if plan.XcconfigPath != "" {
+		s.logger.Println()
+		s.logger.Infof("xcconfig (%s):", plan.XcconfigPath)
+		if plan.XcconfigContent != "" {
+			s.logger.Printf("%s", plan.XcconfigContent)
+		}
+	}
+
+	s.logger.Println()
+	s.logger.Infof("xcodebuild commands:")
+	for _, cmd := range plan.Commands {
+		s.logger.Printf("- %s: %s", cmd.Description, v1command.PrintableCommandArgs(false, cmd.Args))
+	}
+
+	for _, exportOptions := range plan.ExportOptions {
+		s.logger.Println()
+		s.logger.Infof("Export options for %s (%s):", exportOptions.ExportMethod, exportOptions.Source)
+		if exportOptions.Content != "" {
+			s.logger.Printf("%s", exportOptions.Content)
+		}
+	}
+}
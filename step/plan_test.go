@@ -0,0 +1,72 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXcodebuildArchiver_Plan(t *testing.T) {
+	archiver := XcodebuildArchiver{logger: log.NewLogger()}
+
+	plan, err := archiver.Plan(RunOpts{
+		ProjectPath:                 "/project/App.xcworkspace",
+		Scheme:                      "App",
+		DestinationPlatform:         iOS,
+		Configuration:               "Release",
+		XcodeMajorVersion:           15,
+		ArtifactName:                "App",
+		CodeSigningAuthSource:       codeSignSourceOff,
+		PerformCleanAction:          true,
+		XcconfigContent:             "COMPILER_INDEX_STORE_ENABLE = NO",
+		XcodebuildAdditionalOptions: []string{"-skipPackagePluginValidation"},
+		DerivedDataPath:             "/derived-data",
+		CustomExportOptionsPlistContent: `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>thinning</key><string>&lt;none&gt;</string></dict></plist>`,
+		ExportOptionsMode: ExportOptionsModeMerge,
+		ExportMethods:     []string{"app-store", "ad-hoc"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, planModeArchiveAndExport, plan.Mode)
+	require.Equal(t, "iOS", plan.Platform)
+	require.Equal(t, "automatic code signing is off, the installed certificates and provisioning profiles are used", plan.CodeSigning)
+	require.Equal(t, "<temp dir>/temp.xcconfig", plan.XcconfigPath)
+	require.Equal(t, []PlanCommand{
+		{
+			Description: "resolve packages",
+			Args:        []string{"xcodebuild", "-workspace", "/project/App.xcworkspace", "-scheme", "App", "-configuration", "Release", "-resolvePackageDependencies", "-skipPackagePluginValidation", "-derivedDataPath", "/derived-data"},
+		},
+		{
+			Description: "archive",
+			Args: []string{"xcodebuild", "clean", "archive", "-workspace", "/project/App.xcworkspace", "-scheme", "App", "-configuration", "Release",
+				"-xcconfig", "<temp dir>/temp.xcconfig", "-archivePath", "<temp dir>/App.xcarchive", "-resultBundlePath", "<temp dir>/App.xcresult",
+				"-destination", "generic/platform=iOS", "-skipPackagePluginValidation", "-derivedDataPath", "/derived-data"},
+		},
+		{
+			Description: "export (app-store)",
+			Args:        []string{"xcodebuild", "-exportArchive", "-archivePath", "<temp dir>/App.xcarchive", "-exportPath", "<temp dir>/exported", "-exportOptionsPlist", "<temp dir>/export_options.plist"},
+		},
+		{
+			Description: "export (ad-hoc)",
+			Args:        []string{"xcodebuild", "-exportArchive", "-archivePath", "<temp dir>/App.xcarchive", "-exportPath", "<temp dir>/exported", "-exportOptionsPlist", "<temp dir>/export_options.plist"},
+		},
+	}, plan.Commands)
+	require.Len(t, plan.ExportOptions, 2)
+	require.Equal(t, "generated after the archive action, based on the archive's code signing, the custom export options are merged over them", plan.ExportOptions[0].Source)
+
+	// The code signing assets are not prepared in a dry run, only the signing mode is reported
+	plan, err = archiver.Plan(RunOpts{
+		ProjectPath:           "/project/App.xcodeproj",
+		Scheme:                "App",
+		DestinationPlatform:   iOS,
+		XcodeMajorVersion:     15,
+		ArtifactName:          "App",
+		CodeSigningAuthSource: "api-key",
+		SkipExport:            true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "automatic code signing (api-key), certificates and provisioning profiles would be prepared for: development", plan.CodeSigning)
+	require.Equal(t, []string{"resolve packages", "archive"}, []string{plan.Commands[0].Description, plan.Commands[1].Description})
+}
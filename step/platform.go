@@ -13,7 +13,7 @@ type Platform string
 const (
 	detectPlatform Platform = "detect"
 	iOS            Platform = "iOS"
-	osX            Platform = "OS X"
+	osX            Platform = "macOS"
 	tvOS           Platform = "tvOS"
 	watchOS        Platform = "watchOS"
 	visionOS       Platform = "visionOS"
@@ -25,6 +25,8 @@ func parsePlatform(platform string) (Platform, error) {
 		return detectPlatform, nil
 	case "ios":
 		return iOS, nil
+	case "macos":
+		return osX, nil
 	case "tvos":
 		return tvOS, nil
 	case "watchos":
@@ -107,3 +107,28 @@ func TestBuildableTargetPlatform(t *testing.T) {
 		})
 	}
 }
+
+func Test_parsePlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     Platform
+		wantErr  bool
+	}{
+		{platform: "detect", want: detectPlatform},
+		{platform: "iOS", want: iOS},
+		{platform: "macOS", want: osX},
+		{platform: "visionOS", want: visionOS},
+		{platform: "Android", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			got, err := parsePlatform(tt.platform)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	cache "github.com/bitrise-io/go-xcode/v2/xcodecache"
+)
+
+// RetryAction is the remediation performed before retrying a failed archive.
+type RetryAction string
+
+const (
+	RetryActionDeletePath      RetryAction = "delete-path"
+	RetryActionResolvePackages RetryAction = "resolve-packages"
+	RetryActionClean           RetryAction = "clean"
+)
+
+const (
+	swiftPackagesPathPlaceholder = "{swift_packages_path}"
+	derivedDataPathPlaceholder   = "{derived_data_path}"
+)
+
+// RetryRule describes a transient archive failure: if the archive log matches Pattern,
+// Action is performed and the archive is retried, at most MaxRetries times.
+type RetryRule struct {
+	Name       string
+	Pattern    *regexp.Regexp
+	Action     RetryAction
+	Path       string // the path removed by the delete-path action
+	MaxRetries int
+}
+
+var builtInRetryRules = []RetryRule{
+	{
+		Name:       "swift-packages-state-invalid",
+		Pattern:    regexp.MustCompile(regexp.QuoteMeta(cache.SwiftPackagesStateInvalid)),
+		Action:     RetryActionDeletePath,
+		Path:       swiftPackagesPathPlaceholder,
+		MaxRetries: 1,
+	},
+	{
+		Name:       "derived-data-db-corrupted",
+		Pattern:    regexp.MustCompile(`unable to attach DB`),
+		Action:     RetryActionDeletePath,
+		Path:       derivedDataPathPlaceholder,
+		MaxRetries: 1,
+	},
+	{
+		Name:       "package-fetch-interrupted",
+		Pattern:    regexp.MustCompile(`(?i)(fatal: early EOF|the remote end hung up unexpectedly|RPC failed|The network connection was lost)`),
+		Action:     RetryActionResolvePackages,
+		MaxRetries: 2,
+	},
+	{
+		Name:       "package-support-timeout",
+		Pattern:    regexp.MustCompile(`IDEPackageSupport.*(?i:timed out|timeout)`),
+		Action:     RetryActionResolvePackages,
+		MaxRetries: 2,
+	},
+}
+
+// parseRetryRules returns the enabled built-in rules (by name, separated by | or new lines) followed by the custom rules.
+// A custom rule is a line in the form of: <name>|<regexp>|<action>|<max retries>,
+// where action is one of: delete-path:<path>, resolve-packages, clean.
+func parseRetryRules(builtInRuleNames, customRules string) ([]RetryRule, error) {
+	var rules []RetryRule
+	names := map[string]bool{}
+
+	for _, name := range strings.FieldsFunc(builtInRuleNames, func(r rune) bool { return r == '|' || r == '\n' }) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		found := false
+		for _, rule := range builtInRetryRules {
+			if rule.Name == name {
+				rules = append(rules, rule)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown built-in retry rule: %s", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate retry rule: %s", name)
+		}
+		names[name] = true
+	}
+
+	for _, line := range splitInputLines(customRules) {
+		rule, err := parseCustomRetryRule(line)
+		if err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate retry rule: %s", rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func parseCustomRetryRule(line string) (RetryRule, error) {
+	parts := strings.Split(line, "|")
+	if len(parts) != 4 {
+		return RetryRule{}, fmt.Errorf("invalid retry rule (%s), should be in the form of: <name>|<regexp>|<action>|<max retries>", line)
+	}
+
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		return RetryRule{}, fmt.Errorf("invalid retry rule (%s): name is empty", line)
+	}
+
+	pattern, err := regexp.Compile(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return RetryRule{}, fmt.Errorf("invalid retry rule (%s): invalid regexp: %w", line, err)
+	}
+
+	rule := RetryRule{
+		Name:    name,
+		Pattern: pattern,
+	}
+
+	action := strings.TrimSpace(parts[2])
+	switch {
+	case strings.HasPrefix(action, string(RetryActionDeletePath)+":"):
+		rule.Action = RetryActionDeletePath
+		rule.Path = strings.TrimSpace(strings.TrimPrefix(action, string(RetryActionDeletePath)+":"))
+		if rule.Path == "" {
+			return RetryRule{}, fmt.Errorf("invalid retry rule (%s): %s action requires a path", line, RetryActionDeletePath)
+		}
+	case action == string(RetryActionResolvePackages):
+		rule.Action = RetryActionResolvePackages
+	case action == string(RetryActionClean):
+		rule.Action = RetryActionClean
+	default:
+		return RetryRule{}, fmt.Errorf("invalid retry rule (%s): unknown action: %s, available actions: %s:<path>, %s, %s", line, action, RetryActionDeletePath, RetryActionResolvePackages, RetryActionClean)
+	}
+
+	maxRetries, err := strconv.Atoi(strings.TrimSpace(parts[3]))
+	if err != nil || maxRetries < 1 {
+		return RetryRule{}, fmt.Errorf("invalid retry rule (%s): max retries should be a positive integer", line)
+	}
+	rule.MaxRetries = maxRetries
+
+	return rule, nil
+}
+
+// expandRetryRulePaths resolves the path placeholders of the rules.
+// Rules referring to an unavailable path (for example the Swift packages path before Xcode 11) are dropped.
+func expandRetryRulePaths(rules []RetryRule, swiftPackagesPath string) []RetryRule {
+	var derivedDataPath string
+	if swiftPackagesPath != "" {
+		derivedDataPath = filepath.Dir(swiftPackagesPath)
+	}
+
+	var expanded []RetryRule
+	for _, rule := range rules {
+		if rule.Action == RetryActionDeletePath {
+			if (strings.Contains(rule.Path, swiftPackagesPathPlaceholder) || strings.Contains(rule.Path, derivedDataPathPlaceholder)) && swiftPackagesPath == "" {
+				continue
+			}
+			rule.Path = strings.ReplaceAll(rule.Path, swiftPackagesPathPlaceholder, swiftPackagesPath)
+			rule.Path = strings.ReplaceAll(rule.Path, derivedDataPathPlaceholder, derivedDataPath)
+		}
+		expanded = append(expanded, rule)
+	}
+	return expanded
+}
+
+// matchRetryRule returns the first rule matching the log, which has retries left.
+func matchRetryRule(rules []RetryRule, output string, retries map[string]int) (RetryRule, bool) {
+	for _, rule := range rules {
+		if retries[rule.Name] >= rule.MaxRetries {
+			continue
+		}
+		if rule.Pattern.MatchString(output) {
+			return rule, true
+		}
+	}
+	return RetryRule{}, false
+}
@@ -0,0 +1,129 @@
+package step
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseRetryRules(t *testing.T) {
+	tests := []struct {
+		name             string
+		builtInRuleNames string
+		customRules      string
+		wantNames        []string
+		wantErr          string
+	}{
+		{
+			name:             "built-in rules",
+			builtInRuleNames: "swift-packages-state-invalid\n derived-data-db-corrupted \n\n",
+			wantNames:        []string{"swift-packages-state-invalid", "derived-data-db-corrupted"},
+		},
+		{
+			name:             "built-in rules separated by |",
+			builtInRuleNames: "swift-packages-state-invalid|derived-data-db-corrupted|package-fetch-interrupted|package-support-timeout",
+			wantNames:        []string{"swift-packages-state-invalid", "derived-data-db-corrupted", "package-fetch-interrupted", "package-support-timeout"},
+		},
+		{
+			name:             "built-in and custom rules",
+			builtInRuleNames: "package-support-timeout",
+			customRules:      "missing-module|no such module|clean|1\nstale-cache|stale cache|delete-path:{derived_data_path}/Build|2",
+			wantNames:        []string{"package-support-timeout", "missing-module", "stale-cache"},
+		},
+		{
+			name: "no rules",
+		},
+		{
+			name:             "unknown built-in rule",
+			builtInRuleNames: "unknown",
+			wantErr:          "unknown built-in retry rule: unknown",
+		},
+		{
+			name:             "duplicate rule",
+			builtInRuleNames: "package-support-timeout",
+			customRules:      "package-support-timeout|timeout|clean|1",
+			wantErr:          "duplicate retry rule: package-support-timeout",
+		},
+		{
+			name:        "invalid format",
+			customRules: "missing-module|no such module|clean",
+			wantErr:     "invalid retry rule (missing-module|no such module|clean), should be in the form of: <name>|<regexp>|<action>|<max retries>",
+		},
+		{
+			name:        "unknown action",
+			customRules: "missing-module|no such module|reboot|1",
+			wantErr:     "invalid retry rule (missing-module|no such module|reboot|1): unknown action: reboot, available actions: delete-path:<path>, resolve-packages, clean",
+		},
+		{
+			name:        "delete-path without path",
+			customRules: "missing-module|no such module|delete-path:|1",
+			wantErr:     "invalid retry rule (missing-module|no such module|delete-path:|1): delete-path action requires a path",
+		},
+		{
+			name:        "invalid max retries",
+			customRules: "missing-module|no such module|clean|0",
+			wantErr:     "invalid retry rule (missing-module|no such module|clean|0): max retries should be a positive integer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRetryRules(tt.builtInRuleNames, tt.customRules)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var gotNames []string
+			for _, rule := range got {
+				gotNames = append(gotNames, rule.Name)
+			}
+			require.Equal(t, tt.wantNames, gotNames)
+		})
+	}
+}
+
+func Test_expandRetryRulePaths(t *testing.T) {
+	rules := []RetryRule{
+		{Name: "packages", Action: RetryActionDeletePath, Path: swiftPackagesPathPlaceholder},
+		{Name: "build", Action: RetryActionDeletePath, Path: derivedDataPathPlaceholder + "/Build"},
+		{Name: "custom", Action: RetryActionDeletePath, Path: "/tmp/cache"},
+		{Name: "resolve", Action: RetryActionResolvePackages},
+	}
+
+	got := expandRetryRulePaths(rules, "/DerivedData/App-abc/SourcePackages")
+	require.Equal(t, []RetryRule{
+		{Name: "packages", Action: RetryActionDeletePath, Path: "/DerivedData/App-abc/SourcePackages"},
+		{Name: "build", Action: RetryActionDeletePath, Path: "/DerivedData/App-abc/Build"},
+		{Name: "custom", Action: RetryActionDeletePath, Path: "/tmp/cache"},
+		{Name: "resolve", Action: RetryActionResolvePackages},
+	}, got)
+
+	got = expandRetryRulePaths(rules, "")
+	require.Equal(t, []RetryRule{
+		{Name: "custom", Action: RetryActionDeletePath, Path: "/tmp/cache"},
+		{Name: "resolve", Action: RetryActionResolvePackages},
+	}, got)
+}
+
+func Test_matchRetryRule(t *testing.T) {
+	rules := []RetryRule{
+		{Name: "db", Pattern: regexp.MustCompile(`unable to attach DB`), MaxRetries: 1},
+		{Name: "any", Pattern: regexp.MustCompile(`error`), MaxRetries: 2},
+	}
+
+	rule, ok := matchRetryRule(rules, "error: unable to attach DB", map[string]int{})
+	require.True(t, ok)
+	require.Equal(t, "db", rule.Name)
+
+	rule, ok = matchRetryRule(rules, "error: unable to attach DB", map[string]int{"db": 1})
+	require.True(t, ok)
+	require.Equal(t, "any", rule.Name)
+
+	_, ok = matchRetryRule(rules, "error: unable to attach DB", map[string]int{"db": 1, "any": 2})
+	require.False(t, ok)
+
+	_, ok = matchRetryRule(rules, "** ARCHIVE SUCCEEDED **", map[string]int{})
+	require.False(t, ok)
+}
@@ -0,0 +1,153 @@
+package step
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/profileutil"
+)
+
+const (
+	signingSelectionSourceGenerated = "generated"
+	signingSelectionSourceOverride  = "override"
+)
+
+// ProfileOverride sets the provisioning profile (name or UUID) used for exporting a bundle.
+type ProfileOverride struct {
+	BundleID string
+	Profile  string
+}
+
+// parseProfileOverrides parses the overrides, one per line in the form of: <bundle ID>|<profile name or UUID>.
+func parseProfileOverrides(list string) ([]ProfileOverride, error) {
+	var overrides []ProfileOverride
+	bundleIDs := map[string]bool{}
+	for _, line := range splitInputLines(list) {
+		bundleID, profile, found := strings.Cut(line, "|")
+		bundleID, profile = strings.TrimSpace(bundleID), strings.TrimSpace(profile)
+		if !found || bundleID == "" || profile == "" {
+			return nil, fmt.Errorf("invalid provisioning profile override (%s), should be in the form of: <bundle ID>|<profile name or UUID>", line)
+		}
+		if bundleIDs[bundleID] {
+			return nil, fmt.Errorf("duplicate provisioning profile override for bundle ID: %s", bundleID)
+		}
+		bundleIDs[bundleID] = true
+		overrides = append(overrides, ProfileOverride{BundleID: bundleID, Profile: profile})
+	}
+	return overrides, nil
+}
+
+// profileSelection is the provisioning profile used for exporting a bundle.
+type profileSelection struct {
+	BundleID string
+	Profile  string
+	Source   string
+}
+
+// signingSelection is the final code signing selection of the export.
+type signingSelection struct {
+	Profiles           []profileSelection
+	SigningCertificate string
+}
+
+// applySigningOverrides sets the overridden provisioning profiles and signing certificate on the generated export options,
+// and switches them to manual signing. Every override is validated: the bundle ID needs to be archived,
+// and the profile needs to be installed (or embedded into the archive) for the bundle ID. Every issue is returned in a single error.
+func applySigningOverrides(exportOpts exportoptions.ExportOptions, profileOverrides []ProfileOverride, signingCertificate string, archivedBundleIDs []string, profiles []profileutil.ProvisioningProfileInfoModel) (exportoptions.ExportOptions, signingSelection, error) {
+	var profileMapping map[string]string
+	var signingStyle exportoptions.SigningStyle
+	switch options := exportOpts.(type) {
+	case exportoptions.AppStoreOptionsModel:
+		profileMapping, signingStyle = options.BundleIDProvisioningProfileMapping, options.SigningStyle
+	case exportoptions.NonAppStoreOptionsModel:
+		profileMapping, signingStyle = options.BundleIDProvisioningProfileMapping, options.SigningStyle
+	default:
+		return nil, signingSelection{}, fmt.Errorf("unsupported export options type: %T", exportOpts)
+	}
+	if signingStyle == exportoptions.SigningStyleAutomatic {
+		return nil, signingSelection{}, fmt.Errorf("provisioning profile overrides require manual signing, but the export options use automatic signing (set by automatic code signing with Xcode managed profiles)")
+	}
+
+	bundleIDs := append([]string{}, archivedBundleIDs...)
+	sort.Strings(bundleIDs)
+
+	mapping := map[string]string{}
+	for bundleID, profile := range profileMapping {
+		mapping[bundleID] = profile
+	}
+	overridden := map[string]bool{}
+
+	var issues []string
+	for _, override := range profileOverrides {
+		if !slices.Contains(bundleIDs, override.BundleID) {
+			issues = append(issues, fmt.Sprintf("- %s: the bundle ID is not archived (archived bundle IDs: %s)", override.BundleID, strings.Join(bundleIDs, ", ")))
+			continue
+		}
+		if _, found := findProfile(profiles, override.Profile, override.BundleID); !found {
+			issues = append(issues, fmt.Sprintf("- %s: no installed provisioning profile found with name or UUID %s for the bundle ID", override.BundleID, override.Profile))
+			continue
+		}
+		mapping[override.BundleID] = override.Profile
+		overridden[override.BundleID] = true
+	}
+	if len(issues) > 0 {
+		return nil, signingSelection{}, fmt.Errorf("invalid provisioning profile overrides:\n%s", strings.Join(issues, "\n"))
+	}
+
+	var selection signingSelection
+	switch options := exportOpts.(type) {
+	case exportoptions.AppStoreOptionsModel:
+		options.BundleIDProvisioningProfileMapping = mapping
+		options.SigningStyle = exportoptions.SigningStyleManual
+		if signingCertificate != "" {
+			options.SigningCertificate = signingCertificate
+		}
+		selection.SigningCertificate = options.SigningCertificate
+		exportOpts = options
+	case exportoptions.NonAppStoreOptionsModel:
+		options.BundleIDProvisioningProfileMapping = mapping
+		options.SigningStyle = exportoptions.SigningStyleManual
+		if signingCertificate != "" {
+			options.SigningCertificate = signingCertificate
+		}
+		selection.SigningCertificate = options.SigningCertificate
+		exportOpts = options
+	}
+
+	for _, bundleID := range bundleIDs {
+		source := signingSelectionSourceGenerated
+		if overridden[bundleID] {
+			source = signingSelectionSourceOverride
+		}
+		selection.Profiles = append(selection.Profiles, profileSelection{BundleID: bundleID, Profile: mapping[bundleID], Source: source})
+	}
+
+	return exportOpts, selection, nil
+}
+
+// printSigningSelection logs the provisioning profile of every bundle and the signing certificate as a table.
+func printSigningSelection(logger log.Logger, selection signingSelection) {
+	header := profileSelection{BundleID: "Bundle ID", Profile: "Provisioning profile", Source: "Source"}
+	bundleIDWidth, profileWidth := len(header.BundleID), len(header.Profile)
+	for _, profile := range selection.Profiles {
+		bundleIDWidth = max(bundleIDWidth, len(profile.BundleID))
+		profileWidth = max(profileWidth, len(profile.Profile))
+	}
+
+	logger.Println()
+	logger.Infof("Export code signing selection:")
+	for _, row := range append([]profileSelection{header}, selection.Profiles...) {
+		profile := row.Profile
+		if profile == "" {
+			profile = "-"
+		}
+		logger.Printf("%-*s  %-*s  %s", bundleIDWidth, row.BundleID, profileWidth, profile, row.Source)
+	}
+	if selection.SigningCertificate != "" {
+		logger.Printf("Signing certificate: %s", selection.SigningCertificate)
+	}
+}
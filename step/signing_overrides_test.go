@@ -0,0 +1,76 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/profileutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseProfileOverrides(t *testing.T) {
+	overrides, err := parseProfileOverrides("io.bitrise.app.network|Network Extension AdHoc\n\n io.bitrise.app.widget | 6b3c0f0e-1f2a-4a6e-9f1d-0c5d3e0e2b7a ")
+	require.NoError(t, err)
+	require.Equal(t, []ProfileOverride{
+		{BundleID: "io.bitrise.app.network", Profile: "Network Extension AdHoc"},
+		{BundleID: "io.bitrise.app.widget", Profile: "6b3c0f0e-1f2a-4a6e-9f1d-0c5d3e0e2b7a"},
+	}, overrides)
+
+	_, err = parseProfileOverrides("io.bitrise.app.network")
+	require.EqualError(t, err, "invalid provisioning profile override (io.bitrise.app.network), should be in the form of: <bundle ID>|<profile name or UUID>")
+	_, err = parseProfileOverrides("io.bitrise.app|A\nio.bitrise.app|B")
+	require.EqualError(t, err, "duplicate provisioning profile override for bundle ID: io.bitrise.app")
+}
+
+func Test_applySigningOverrides(t *testing.T) {
+	generated := exportoptions.NewNonAppStoreOptions(exportoptions.MethodAdHoc)
+	generated.BundleIDProvisioningProfileMapping = map[string]string{
+		"io.bitrise.app":         "App AdHoc",
+		"io.bitrise.app.network": "Wildcard AdHoc",
+	}
+	generated.SigningCertificate = "Apple Distribution: Bitrise (TEAM123)"
+
+	archivedBundleIDs := []string{"io.bitrise.app.network", "io.bitrise.app"}
+	profiles := []profileutil.ProvisioningProfileInfoModel{
+		{Name: "App AdHoc", UUID: "app-uuid", BundleID: "io.bitrise.app"},
+		{Name: "Network Extension AdHoc", UUID: "network-uuid", BundleID: "io.bitrise.app.network"},
+	}
+
+	exportOptions, selection, err := applySigningOverrides(generated, []ProfileOverride{{BundleID: "io.bitrise.app.network", Profile: "network-uuid"}}, "", archivedBundleIDs, profiles)
+	require.NoError(t, err)
+
+	options := exportOptions.(exportoptions.NonAppStoreOptionsModel)
+	require.Equal(t, map[string]string{"io.bitrise.app": "App AdHoc", "io.bitrise.app.network": "network-uuid"}, options.BundleIDProvisioningProfileMapping)
+	require.Equal(t, exportoptions.SigningStyleManual, options.SigningStyle)
+	require.Equal(t, "Wildcard AdHoc", generated.BundleIDProvisioningProfileMapping["io.bitrise.app.network"], "the generated export options should not be modified")
+	require.Equal(t, signingSelection{
+		Profiles: []profileSelection{
+			{BundleID: "io.bitrise.app", Profile: "App AdHoc", Source: "generated"},
+			{BundleID: "io.bitrise.app.network", Profile: "network-uuid", Source: "override"},
+		},
+		SigningCertificate: "Apple Distribution: Bitrise (TEAM123)",
+	}, selection)
+
+	exportOptions, _, err = applySigningOverrides(exportoptions.NewAppStoreOptions(), nil, "Apple Distribution", archivedBundleIDs, profiles)
+	require.NoError(t, err)
+	require.Equal(t, "Apple Distribution", exportOptions.(exportoptions.AppStoreOptionsModel).SigningCertificate)
+}
+
+func Test_applySigningOverrides_Invalid(t *testing.T) {
+	profiles := []profileutil.ProvisioningProfileInfoModel{
+		{Name: "App AdHoc", UUID: "app-uuid", BundleID: "io.bitrise.app"},
+	}
+
+	_, _, err := applySigningOverrides(exportoptions.NewNonAppStoreOptions(exportoptions.MethodAdHoc), []ProfileOverride{
+		{BundleID: "io.bitrise.app.network", Profile: "App AdHoc"},
+		{BundleID: "io.bitrise.other", Profile: "App AdHoc"},
+	}, "", []string{"io.bitrise.app", "io.bitrise.app.network"}, profiles)
+	require.EqualError(t, err, `invalid provisioning profile overrides:
+- io.bitrise.app.network: no installed provisioning profile found with name or UUID App AdHoc for the bundle ID
+- io.bitrise.other: the bundle ID is not archived (archived bundle IDs: io.bitrise.app, io.bitrise.app.network)`)
+
+	automatic := exportoptions.NewNonAppStoreOptions(exportoptions.MethodAdHoc)
+	automatic.SigningStyle = exportoptions.SigningStyleAutomatic
+	_, _, err = applySigningOverrides(automatic, []ProfileOverride{{BundleID: "io.bitrise.app", Profile: "App AdHoc"}}, "", []string{"io.bitrise.app"}, profiles)
+	require.Error(t, err)
+}
@@ -1,7 +1,10 @@
 package step
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
@@ -33,7 +36,6 @@ import (
 	"github.com/bitrise-io/go-xcode/v2/exportoptionsgenerator"
 	"github.com/bitrise-io/go-xcode/v2/xcarchive"
 	"github.com/bitrise-io/go-xcode/v2/xcconfig"
-	cache "github.com/bitrise-io/go-xcode/v2/xcodecache"
 	"github.com/bitrise-io/go-xcode/v2/xcodecommand"
 	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
 	"github.com/bitrise-io/go-xcode/xcodebuild"
@@ -45,9 +47,24 @@ const (
 	minSupportedXcodeMajorVersion = 9
 
 	// Deployed Outputs (moved to the OutputDir)
-	bitriseXCArchiveZipPthEnvKey = "BITRISE_XCARCHIVE_ZIP_PATH"
-	bitriseDSYMPthEnvKey         = "BITRISE_DSYM_PATH"
-	bitriseIPAPthEnvKey          = "BITRISE_IPA_PATH"
+	bitriseXCArchiveZipPthEnvKey     = "BITRISE_XCARCHIVE_ZIP_PATH"
+	bitriseDSYMPthEnvKey             = "BITRISE_DSYM_PATH"
+	bitriseIPAPthEnvKey              = "BITRISE_IPA_PATH"
+	bitriseAppPthEnvKey              = "BITRISE_APP_PATH"
+	bitrisePKGPthEnvKey              = "BITRISE_PKG_PATH"
+	bitriseIPAPthsEnvKey             = "BITRISE_IPA_PATHS"
+	bitriseIPAPthListEnvKey          = "BITRISE_IPA_PATH_LIST"
+	bitriseIPAManifestPthEnvKey      = "BITRISE_IPA_MANIFEST_PATH"
+	bitriseIPAInstallPagePthEnvKey   = "BITRISE_IPA_INSTALL_PAGE_PATH"
+	bitriseIPAVariantPthsEnvKey      = "BITRISE_IPA_VARIANT_PATHS"
+	bitriseThinningReportPthEnvKey   = "BITRISE_APP_THINNING_SIZE_REPORT_PATH"
+	bitriseThinningJSONPthEnvKey     = "BITRISE_APP_THINNING_SIZE_REPORT_JSON_PATH"
+	bitriseIPAVerificationPthEnvKey  = "BITRISE_IPA_VERIFICATION_REPORT_PATH"
+	bitriseXcresultZipPthEnvKey      = "BITRISE_XCRESULT_ZIP_PATH"
+	bitriseFailureSummaryPthEnvKey   = "BITRISE_XCODEBUILD_FAILURE_SUMMARY_PATH"
+	bitriseTimingReportPthEnvKey     = "BITRISE_XCODEBUILD_TIMING_REPORT_PATH"
+	bitriseTimingReportMDPthEnvKey   = "BITRISE_XCODEBUILD_TIMING_REPORT_MARKDOWN_PATH"
+	bitriseArtifactManifestPthEnvKey = "BITRISE_XCODE_ARCHIVE_MANIFEST_PATH"
 
 	// Deployed logs
 	xcodebuildArchiveLogPathEnvKey       = "BITRISE_XCODEBUILD_ARCHIVE_LOG_PATH"
@@ -55,11 +72,24 @@ const (
 	bitriseIDEDistributionLogsPthEnvKey  = "BITRISE_IDEDISTRIBUTION_LOGS_PATH"
 	xcodebuildArchiveLogFilename         = "xcodebuild-archive.log"
 	xcodebuildExportArchiveLogFilename   = "xcodebuild-export-archive.log"
+	failureSummaryFilename               = "xcodebuild-failure-summary.json"
+	timingReportFilename                 = "xcodebuild-timing-report.json"
+	timingReportMDFilename               = "xcodebuild-timing-report.md"
 
 	// Env Outputs
-	bitriseAppDirPthEnvKey    = "BITRISE_APP_DIR_PATH"
-	bitriseDSYMDirPthEnvKey   = "BITRISE_DSYM_DIR_PATH"
-	bitriseXCArchivePthEnvKey = "BITRISE_XCARCHIVE_PATH"
+	bitriseAppDirPthEnvKey           = "BITRISE_APP_DIR_PATH"
+	bitriseDSYMDirPthEnvKey          = "BITRISE_DSYM_DIR_PATH"
+	bitriseXCArchivePthEnvKey        = "BITRISE_XCARCHIVE_PATH"
+	bitriseXcresultPthEnvKey         = "BITRISE_XCRESULT_PATH"
+	bitriseRetryRulesEnvKey          = "BITRISE_XCODEBUILD_ARCHIVE_RETRY_RULES"
+	bitriseDerivedDataPthEnvKey      = "BITRISE_DERIVED_DATA_PATH"
+	bitriseDerivedDataCacheKeyEnvKey = "BITRISE_DERIVED_DATA_CACHE_KEY"
+	bitriseAppBuildNumberEnvKey      = "BITRISE_APP_BUILD_NUMBER"
+	bitriseAppMarketingVersionEnvKey = "BITRISE_APP_MARKETING_VERSION"
+	bitriseUploadStatusEnvKey        = "BITRISE_APP_STORE_CONNECT_UPLOAD_STATUS"
+	bitriseUploadDeliveryUUIDEnvKey  = "BITRISE_APP_STORE_CONNECT_DELIVERY_UUID"
+	bitriseUploadBuildIDEnvKey       = "BITRISE_APP_STORE_CONNECT_BUILD_ID"
+	bitriseUploadProcessingEnvKey    = "BITRISE_APP_STORE_CONNECT_BUILD_PROCESSING_STATE"
 
 	// Code Signing Authentication Source
 	codeSignSourceOff     = "off"
@@ -74,16 +104,28 @@ const (
 
 // Inputs ...
 type Inputs struct {
-	ProjectPath  string `env:"project_path,file"`
-	Scheme       string `env:"scheme,required"`
-	ExportMethod string `env:"distribution_method,opt[app-store,ad-hoc,enterprise,development]"`
-	Platform     string `env:"platform,opt[detect,iOS,watchOS,tvOS,visionOS]"`
+	ProjectPath  string `env:"project_path"`
+	Scheme       string `env:"scheme"`
+	ExportMethod string `env:"distribution_method,required"`
+	Platform     string `env:"platform,opt[detect,iOS,macOS,watchOS,tvOS,visionOS]"`
 
 	// xcodebuild configuration
 	Configuration      string `env:"configuration"`
 	XcconfigContent    string `env:"xcconfig_content"`
 	PerformCleanAction bool   `env:"perform_clean_action,opt[yes,no]"`
 	XcodebuildOptions  string `env:"xcodebuild_options"`
+	DerivedDataPath    string `env:"derived_data_path"`
+	RetryRules         string `env:"retry_rules"`
+	CustomRetryRules   string `env:"custom_retry_rules"`
+	SilenceTimeout     int    `env:"xcodebuild_silence_timeout,required"`
+	Timeout            int    `env:"xcodebuild_timeout,required"`
+	BuildTimingSummary bool   `env:"show_build_timing_summary,opt[yes,no]"`
+
+	// Versioning
+	BuildNumberStrategy string `env:"build_number_strategy,opt[off,ci-build-number,git-commit-count,timestamp,explicit]"`
+	BuildNumber         string `env:"build_number"`
+	BuildNumberOffset   int    `env:"build_number_offset,required"`
+	MarketingVersion    string `env:"marketing_version"`
 
 	// xcodebuild log formatting
 	LogFormatter string `env:"log_formatter,opt[xcbeautify,xcodebuild,xcpretty]"`
@@ -106,24 +148,47 @@ type Inputs struct {
 	ICloudContainerEnvironment    string `env:"icloud_container_environment"`
 	TestFlightInternalTestingOnly bool   `env:"testflight_internal_testing_only,opt[yes,no]"`
 	ExportOptionsPlistContent     string `env:"export_options_plist_content"`
+	ExportOptionsMode             string `env:"export_options_plist_mode,opt[replace,merge]"`
+	ExportProvisioningProfiles    string `env:"export_provisioning_profiles"`
+	ExportSigningCertificate      string `env:"export_signing_certificate"`
+	Thinning                      string `env:"thinning,required"`
+	ThinningSizeBudget            string `env:"thinning_size_budget"`
+	ExportDestination             string `env:"export_destination,opt[export,upload]"`
+	VerifyIPA                     string `env:"verify_ipa,opt[off,report,fail]"`
+	ArchivePath                   string `env:"archive_path"`
+	SkipExport                    bool   `env:"skip_export,opt[yes,no]"`
+
+	// Over-the-air installation
+	OTAAppURL               string `env:"ota_app_url"`
+	OTADisplayImageURL      string `env:"ota_display_image_url"`
+	OTAFullSizeImageURL     string `env:"ota_full_size_image_url"`
+	OTAAssetPackManifestURL string `env:"ota_asset_pack_manifest_url"`
+	OTAManifestURL          string `env:"ota_manifest_url"`
 
 	// Step Output Export configuration
 	OutputDir      string `env:"output_dir,required"`
 	ExportAllDsyms bool   `env:"export_all_dsyms,opt[yes,no]"`
 	ArtifactName   string `env:"artifact_name"`
+	OutputExporter string `env:"output_exporter,opt[auto,envman,dotenv,github,json]"`
+	OutputFilePath string `env:"output_file_path"`
 
 	// App Store Connect connection override
 	APIKeyPath              stepconf.Secret `env:"api_key_path"`
 	APIKeyID                string          `env:"api_key_id"`
 	APIKeyIssuerID          string          `env:"api_key_issuer_id"`
 	APIKeyEnterpriseAccount bool            `env:"api_key_enterprise_account,opt[yes,no]"`
+	AppStoreConnectAPIURL   string          `env:"app_store_connect_api_url"`
 
 	// Debugging
 	VerboseLog bool `env:"verbose_log,opt[yes,no]"`
+	DryRun     bool `env:"dry_run,opt[yes,no]"`
 
 	// Hidden inputs
 	BuildURL      string          `env:"BITRISE_BUILD_URL"`
+	CIBuildNumber string          `env:"BITRISE_BUILD_NUMBER"`
 	BuildAPIToken stepconf.Secret `env:"BITRISE_BUILD_API_TOKEN"`
+	EnvstorePath  string          `env:"ENVMAN_ENVSTORE_PATH"`
+	GithubOutput  string          `env:"GITHUB_OUTPUT"`
 }
 
 // Config ...
@@ -133,8 +198,24 @@ type Config struct {
 	ProjectManager              projectmanager.Project
 	DestinationPlatform         Platform
 	XcodeMajorVersion           int
+	XcodeVersion                xcodeversion.Version
 	XcodebuildAdditionalOptions []string
-	CodesignManager             *codesign.Manager // nil if automatic code signing is "off"
+	ExportMethods               []string
+	ArchiveRetryRules           []RetryRule
+	DerivedDataCacheKey         string
+	AppVersion                  AppVersion
+	OTA                         OTAConfig
+	ProfileOverrides            []ProfileOverride
+	ThinningSizeBudgets         []ThinningSizeBudget
+	UploadAPIKey                *devportalservice.APIKeyConnection // set if ExportDestination is upload
+	AppStoreConnectAPIBaseURL   *url.URL                           // nil if Apple's App Store Connect API is used
+	CodesignManager             *codesign.Manager                  // nil if automatic code signing is "off"
+	ExportCodesignManagers      []*codesign.Manager                // code signing for the additional distribution methods, empty if automatic code signing is "off"
+	StepOutputExporter          OutputExporter                     // envman, or the output file selected by OutputExporter
+
+	// Export-only mode, set if ArchivePath is provided
+	Archive      *xcarchive.IosArchive
+	MacosArchive *xcarchive.MacosArchive
 }
 
 type XcodebuildArchiveConfigParser struct {
@@ -157,6 +238,7 @@ type XcodebuildArchiver struct {
 	fileManager        fileutil.FileManager
 	logger             log.Logger
 	cmdFactory         command.Factory
+	outputExporter     OutputExporter
 	// xcodeRunnerCmdFactory is the factory used to (re)build xcodecommand runners.
 	// It matches cmdFactory for raw setups, or is wrapped with Bitrise Build Cache
 	// when RN cache activation was detected at main.go wiring time.
@@ -175,8 +257,8 @@ func NewXcodeArchiveConfigParser(stepInputParser stepconf.InputParser, xcodeVers
 }
 
 // NewXcodebuildArchiver ...
-func NewXcodebuildArchiver(xcodecommandRunner xcodecommand.Runner, logFormatter string, xcodeVersionReader xcodeversion.Reader, pathProvider pathutil.PathProvider, pathChecker pathutil.PathChecker, pathModifier pathutil.PathModifier, fileManager fileutil.FileManager, cmdFactory command.Factory, logger log.Logger) XcodebuildArchiver {
-	return NewXcodebuildArchiverWithRunnerFactory(xcodecommandRunner, logFormatter, xcodeVersionReader, pathProvider, pathChecker, pathModifier, fileManager, cmdFactory, cmdFactory, logger)
+func NewXcodebuildArchiver(xcodecommandRunner xcodecommand.Runner, logFormatter string, xcodeVersionReader xcodeversion.Reader, pathProvider pathutil.PathProvider, pathChecker pathutil.PathChecker, pathModifier pathutil.PathModifier, fileManager fileutil.FileManager, cmdFactory command.Factory, outputExporter OutputExporter, logger log.Logger) XcodebuildArchiver {
+	return NewXcodebuildArchiverWithRunnerFactory(xcodecommandRunner, logFormatter, xcodeVersionReader, pathProvider, pathChecker, pathModifier, fileManager, cmdFactory, cmdFactory, outputExporter, logger)
 }
 
 // NewXcodebuildArchiverWithRunnerFactory is a variant of NewXcodebuildArchiver
@@ -184,7 +266,7 @@ func NewXcodebuildArchiver(xcodecommandRunner xcodecommand.Runner, logFormatter
 // Bitrise Build Cache wraps xcodebuild, the runner factory is a wrapping
 // factory while cmdFactory remains unwrapped — so codesign / project reader
 // invocations stay unaffected.
-func NewXcodebuildArchiverWithRunnerFactory(xcodecommandRunner xcodecommand.Runner, logFormatter string, xcodeVersionReader xcodeversion.Reader, pathProvider pathutil.PathProvider, pathChecker pathutil.PathChecker, pathModifier pathutil.PathModifier, fileManager fileutil.FileManager, cmdFactory, xcodeRunnerCmdFactory command.Factory, logger log.Logger) XcodebuildArchiver {
+func NewXcodebuildArchiverWithRunnerFactory(xcodecommandRunner xcodecommand.Runner, logFormatter string, xcodeVersionReader xcodeversion.Reader, pathProvider pathutil.PathProvider, pathChecker pathutil.PathChecker, pathModifier pathutil.PathModifier, fileManager fileutil.FileManager, cmdFactory, xcodeRunnerCmdFactory command.Factory, outputExporter OutputExporter, logger log.Logger) XcodebuildArchiver {
 	return XcodebuildArchiver{
 		xcodeCommandRunner:    xcodecommandRunner,
 		logFormatter:          logFormatter,
@@ -195,6 +277,7 @@ func NewXcodebuildArchiverWithRunnerFactory(xcodecommandRunner xcodecommand.Runn
 		fileManager:           fileManager,
 		logger:                logger,
 		cmdFactory:            cmdFactory,
+		outputExporter:        outputExporter,
 		xcodeRunnerCmdFactory: xcodeRunnerCmdFactory,
 	}
 }
@@ -221,6 +304,23 @@ func (s XcodebuildArchiveConfigParser) ProcessInputs() (Config, error) {
 		return Config{}, fmt.Errorf("issue with input Platform: %w", err)
 	}
 
+	if config.ExportMethods, err = parseExportMethods(config.ExportMethod); err != nil {
+		return Config{}, fmt.Errorf("issue with input DistributionMethod: %w", err)
+	}
+	// The first distribution method is used for signing the archive and for the unsuffixed outputs (for example BITRISE_IPA_PATH).
+	config.ExportMethod = config.ExportMethods[0]
+
+	if config.SilenceTimeout < 0 {
+		return Config{}, fmt.Errorf("issue with input SilenceTimeout: should be a non-negative number of seconds")
+	}
+	if config.Timeout < 0 {
+		return Config{}, fmt.Errorf("issue with input Timeout: should be a non-negative number of seconds")
+	}
+
+	if config.ArchiveRetryRules, err = parseRetryRules(config.RetryRules, config.CustomRetryRules); err != nil {
+		return Config{}, fmt.Errorf("issue with input RetryRules or CustomRetryRules: %w", err)
+	}
+
 	config.XcodebuildAdditionalOptions, err = shellquote.Split(inputs.XcodebuildOptions)
 	if err != nil {
 		return Config{}, fmt.Errorf("provided XcodebuildOptions (%s) are not valid CLI parameters: %s", inputs.XcodebuildOptions, err)
@@ -233,6 +333,10 @@ func (s XcodebuildArchiveConfigParser) ProcessInputs() (Config, error) {
 		config.XcconfigContent != "" {
 		return Config{}, fmt.Errorf("`-xcconfig` option found in XcodebuildOptions (`xcodebuild_options`), please clear Build settings (xcconfig) (`xcconfig_content`) input as only one can be set")
 	}
+	if slices.Contains(config.XcodebuildAdditionalOptions, derivedDataPathOption) &&
+		config.DerivedDataPath != "" {
+		return Config{}, fmt.Errorf("`%s` option found in XcodebuildOptions (`xcodebuild_options`), please clear DerivedData path (`derived_data_path`) input as only one can be set", derivedDataPathOption)
+	}
 
 	if config.ExportOptionsPlistContent != "" {
 		var options map[string]interface{}
@@ -241,9 +345,35 @@ func (s XcodebuildArchiveConfigParser) ProcessInputs() (Config, error) {
 		}
 	}
 
-	if filepath.Ext(config.ProjectPath) != ".xcodeproj" && filepath.Ext(config.ProjectPath) != ".xcworkspace" {
-		return Config{}, fmt.Errorf("issue with input ProjectPath: should be and .xcodeproj or .xcworkspace path")
+	if config.ArchivePath == "" {
+		if config.Scheme == "" {
+			return Config{}, fmt.Errorf("issue with input Scheme: required variable is not present")
+		}
+		if exist, err := v1pathutil.IsPathExists(config.ProjectPath); err != nil {
+			return Config{}, fmt.Errorf("issue with input ProjectPath: failed to check if path exist: %s", err)
+		} else if !exist {
+			return Config{}, fmt.Errorf("issue with input ProjectPath: path does not exist: %s", config.ProjectPath)
+		}
+		if filepath.Ext(config.ProjectPath) != ".xcodeproj" && filepath.Ext(config.ProjectPath) != ".xcworkspace" {
+			return Config{}, fmt.Errorf("issue with input ProjectPath: should be and .xcodeproj or .xcworkspace path")
+		}
+	} else if filepath.Ext(config.ArchivePath) != ".xcarchive" {
+		return Config{}, fmt.Errorf("issue with input ArchivePath: should be an .xcarchive path")
+	} else if config.SkipExport {
+		return Config{}, fmt.Errorf("issue with input SkipExport: can not be used together with ArchivePath, as nothing would be done")
+	}
+	if config.ArchivePath == "" && config.DestinationPlatform == osX && config.CodeSigningAuthSource != codeSignSourceOff {
+		return Config{}, fmt.Errorf("issue with input CodeSigningAuthSource: %w", errMacosAutomaticCodeSigning)
+	}
+
+	outputExporter := config.OutputExporter
+	if outputExporter == outputExporterAuto {
+		outputExporter = detectOutputExporter(config.EnvstorePath, config.GithubOutput)
+	}
+	if config.StepOutputExporter, err = newOutputExporter(outputExporter, config.OutputFilePath, config.GithubOutput, s.cmdFactory); err != nil {
+		return Config{}, fmt.Errorf("issue with input OutputExporter: %w", err)
 	}
+	s.logger.Printf("Step outputs are exported to %s", config.StepOutputExporter.Describe())
 
 	s.logger.Infof("Xcode version:")
 
@@ -258,6 +388,13 @@ func (s XcodebuildArchiveConfigParser) ProcessInputs() (Config, error) {
 		return Config{}, fmt.Errorf("invalid xcode major version (%d), should not be less then min supported: %d", xcodebuildVersion.Major, minSupportedXcodeMajorVersion)
 	}
 	config.XcodeMajorVersion = int(xcodebuildVersion.Major)
+	config.XcodeVersion = xcodebuildVersion
+
+	for _, exportMethod := range config.ExportMethods {
+		if xcodeExportMethod := exportMethodForXcode(exportoptions.Method(exportMethod), xcodebuildVersion); string(xcodeExportMethod) != exportMethod {
+			s.logger.Printf("Distribution method %s is passed to xcodebuild as %s (Xcode %d.%d)", exportMethod, xcodeExportMethod, xcodebuildVersion.Major, xcodebuildVersion.Minor)
+		}
+	}
 
 	// Validation ExportOptionsPlistContent
 	exportOptionsPlistContent := strings.TrimSpace(config.ExportOptionsPlistContent)
@@ -268,6 +405,31 @@ func (s XcodebuildArchiveConfigParser) ProcessInputs() (Config, error) {
 	}
 
 	if exportOptionsPlistContent != "" {
+		var options map[string]interface{}
+		if _, err := plist.Unmarshal([]byte(exportOptionsPlistContent), &options); err == nil {
+			if method, ok := options[exportoptions.MethodKey].(string); ok {
+				if err := checkExportMethodSupported(method, xcodebuildVersion); err != nil {
+					return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent: %w", err)
+				}
+			}
+		}
+	}
+
+	isExportOptionsMerge := config.ExportOptionsMode == ExportOptionsModeMerge
+	if exportOptionsPlistContent != "" && len(config.ExportMethods) > 1 {
+		if !isExportOptionsMerge {
+			return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent: custom export options can not be used with multiple distribution methods (%s), unless ExportOptionsMode is %s", strings.Join(config.ExportMethods, ", "), ExportOptionsModeMerge)
+		}
+
+		var options map[string]interface{}
+		if _, err := plist.Unmarshal([]byte(exportOptionsPlistContent), &options); err == nil {
+			if _, ok := options["method"]; ok {
+				return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent: the method key can not be merged over multiple distribution methods (%s)", strings.Join(config.ExportMethods, ", "))
+			}
+		}
+	}
+
+	if exportOptionsPlistContent != "" && !isExportOptionsMerge {
 		s.logger.Println()
 		s.logger.Warnf("Ignoring the following options because ExportOptionsPlistContent provided:")
 		s.logger.Printf("- DistributionMethod: %s", config.ExportMethod)
@@ -275,21 +437,131 @@ func (s XcodebuildArchiveConfigParser) ProcessInputs() (Config, error) {
 		s.logger.Printf("- CompileBitcode: %s", config.CompileBitcode)
 		s.logger.Printf("- ExportDevelopmentTeam: %s", config.ExportDevelopmentTeam)
 		s.logger.Printf("- ICloudContainerEnvironment: %s", config.ICloudContainerEnvironment)
+		s.logger.Printf("- ExportProvisioningProfiles: %s", config.ExportProvisioningProfiles)
+		s.logger.Printf("- ExportSigningCertificate: %s", config.ExportSigningCertificate)
+		s.logger.Printf("- ExportDestination: %s", config.ExportDestination)
 		s.logger.Println()
 	}
 	config.ExportOptionsPlistContent = exportOptionsPlistContent
 
-	if config.ExportMethod != "app-store" && config.TestFlightInternalTestingOnly {
+	if err := validateArtifactNameTemplate(config.ArtifactName); err != nil {
+		return Config{}, fmt.Errorf("issue with input ArtifactName: %w", err)
+	}
+
+	config.OTA = OTAConfig{
+		AppURL:               config.OTAAppURL,
+		DisplayImageURL:      config.OTADisplayImageURL,
+		FullSizeImageURL:     config.OTAFullSizeImageURL,
+		AssetPackManifestURL: config.OTAAssetPackManifestURL,
+		ManifestURL:          config.OTAManifestURL,
+	}
+	if err := config.OTA.validate(); err != nil {
+		return Config{}, fmt.Errorf("issue with input OTAAppURL, OTADisplayImageURL or OTAFullSizeImageURL: %w", err)
+	}
+	if !config.OTA.IsEmpty() && !slices.ContainsFunc(config.ExportMethods, func(method string) bool {
+		return exportoptions.Method(method).IsAdHoc() || exportoptions.Method(method).IsEnterprise()
+	}) {
+		s.logger.Warnf("The over-the-air installation inputs are ignored, as none of the distribution methods (%s) is ad-hoc or enterprise", strings.Join(config.ExportMethods, ", "))
+	}
+
+	if config.ProfileOverrides, err = parseProfileOverrides(config.ExportProvisioningProfiles); err != nil {
+		return Config{}, fmt.Errorf("issue with input ExportProvisioningProfiles: %w", err)
+	}
+
+	if config.Thinning, err = parseThinning(config.Thinning); err != nil {
+		return Config{}, fmt.Errorf("issue with input Thinning: %w", err)
+	}
+	if config.ThinningSizeBudgets, err = parseThinningSizeBudgets(config.ThinningSizeBudget); err != nil {
+		return Config{}, fmt.Errorf("issue with input ThinningSizeBudget: %w", err)
+	}
+	if len(config.ThinningSizeBudgets) > 0 && config.Thinning == thinningNone {
+		return Config{}, fmt.Errorf("issue with input ThinningSizeBudget: size budgets require thinning, set the Thinning input")
+	}
+	if config.Thinning != thinningNone && !slices.ContainsFunc(config.ExportMethods, func(method string) bool {
+		return !exportoptions.Method(method).IsAppStore()
+	}) {
+		s.logger.Warnf("Thinning is ignored, as it is not available for the %s distribution method", strings.Join(config.ExportMethods, ", "))
+	}
+
+	isUpload := config.ExportDestination == exportDestinationUpload
+	if isUpload && !slices.ContainsFunc(config.ExportMethods, func(method string) bool { return exportoptions.Method(method).IsAppStore() }) {
+		return Config{}, fmt.Errorf("issue with input ExportDestination: %s is only available for the app-store distribution method, got: %s", exportDestinationUpload, strings.Join(config.ExportMethods, ", "))
+	}
+	if isUpload && config.DestinationPlatform == osX {
+		return Config{}, fmt.Errorf("issue with input ExportDestination: %s is not supported for macOS", exportDestinationUpload)
+	}
+	if config.AppStoreConnectAPIBaseURL, err = parseAppStoreConnectAPIURL(config.AppStoreConnectAPIURL); err != nil {
+		return Config{}, fmt.Errorf("issue with input AppStoreConnectAPIURL: %w", err)
+	}
+
+	if config.SkipExport {
+		s.logger.Println()
+		s.logger.Warnf("SkipExport is set, ignoring the export related inputs (DistributionMethod, ExportOptionsPlistContent, ...)")
+		s.logger.Println()
+	}
+
+	if slices.Contains(config.ExportMethods, string(exportoptions.MethodDeveloperID)) && !config.SkipExport {
+		if config.DestinationPlatform != detectPlatform && config.DestinationPlatform != osX {
+			return Config{}, fmt.Errorf("issue with input DistributionMethod: %s is only available for macOS, but platform is set to %s", exportoptions.MethodDeveloperID, config.DestinationPlatform)
+		}
+		if config.CodeSigningAuthSource != codeSignSourceOff {
+			return Config{}, fmt.Errorf("issue with input DistributionMethod: automatic code signing does not support %s distribution, install the Developer ID certificate before this Step and set Automatic code signing method to off", exportoptions.MethodDeveloperID)
+		}
+	}
+
+	if !slices.ContainsFunc(config.ExportMethods, func(method string) bool { return exportoptions.Method(method).IsAppStore() }) && config.TestFlightInternalTestingOnly {
 		s.logger.Println()
 		s.logger.Warnf("TestFlightInternalTestingOnly is valid only for Distribution Method app-store.")
 		s.logger.Println()
 	}
 
-	absProjectPath, err := filepath.Abs(config.ProjectPath)
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to get absolute project path, error: %s", err)
+	if config.ArchivePath == "" {
+		absProjectPath, err := filepath.Abs(config.ProjectPath)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to get absolute project path, error: %s", err)
+		}
+		config.ProjectPath = absProjectPath
+	}
+
+	if config.DerivedDataPath != "" {
+		if config.ArchivePath != "" {
+			s.logger.Warnf("DerivedDataPath is ignored, as ArchivePath is set and the project is not built")
+			config.DerivedDataPath = ""
+		} else {
+			absDerivedDataPath, err := v1pathutil.AbsPath(config.DerivedDataPath)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to expand DerivedDataPath (%s), error: %s", config.DerivedDataPath, err)
+			}
+			config.DerivedDataPath = absDerivedDataPath
+
+			if config.DerivedDataCacheKey, err = derivedDataCacheKey(config.ProjectPath, config.Scheme, config.Configuration, xcodebuildVersion); err != nil {
+				return Config{}, fmt.Errorf("failed to compute DerivedData cache key: %w", err)
+			}
+		}
+	}
+
+	isBuildNumberManaged := config.BuildNumberStrategy != "" && config.BuildNumberStrategy != string(BuildNumberStrategyOff)
+	if isBuildNumberManaged || config.MarketingVersion != "" {
+		if config.ArchivePath != "" {
+			s.logger.Warnf("BuildNumberStrategy and MarketingVersion are ignored, as ArchivePath is set and the project is not built")
+		} else {
+			if config.AppVersion, err = s.resolveAppVersion(config); err != nil {
+				return Config{}, err
+			}
+			if config.XcconfigContent, err = config.AppVersion.applyToXcconfig(config.XcconfigContent); err != nil {
+				return Config{}, err
+			}
+
+			s.logger.Println()
+			s.logger.Infof("Versioning:")
+			if config.AppVersion.BuildNumber != "" {
+				s.logger.Printf("- %s: %s", buildNumberBuildSetting, config.AppVersion.BuildNumber)
+			}
+			if config.AppVersion.MarketingVersion != "" {
+				s.logger.Printf("- %s: %s", marketingVersionBuildSetting, config.AppVersion.MarketingVersion)
+			}
+		}
 	}
-	config.ProjectPath = absProjectPath
 
 	// abs out dir pth
 	absOutputDir, err := v1pathutil.AbsPath(config.OutputDir)
@@ -306,35 +578,113 @@ func (s XcodebuildArchiveConfigParser) ProcessInputs() (Config, error) {
 		}
 	}
 
-	showbuildSettingsAdditionalOptions := filterSPMAdditionalOptions(config.XcodebuildAdditionalOptions)
-	if len(showbuildSettingsAdditionalOptions) != len(config.XcodebuildAdditionalOptions) {
-		s.logger.Printf("Some xcodebuild additional options are filtered out when reading build settings. Options used: %s", strings.Join(showbuildSettingsAdditionalOptions, " "))
+	if config.ArchivePath != "" {
+		// Export-only mode: the archive is exported as it is, the project is not opened
+		s.logger.TInfof("Opening existing archive at path: %s", config.ArchivePath)
+		if config.Archive, config.MacosArchive, err = openArchive(config.ArchivePath, pathutil.NewPathChecker(), s.logger); err != nil {
+			return Config{}, fmt.Errorf("issue with input ArchivePath: %w", err)
+		}
+
+		if config.MacosArchive != nil && config.CodeSigningAuthSource != codeSignSourceOff {
+			return Config{}, fmt.Errorf("issue with input ArchivePath: automatic code signing is not supported for exporting a macOS archive, set Automatic code signing method to off")
+		}
+	} else {
+		showbuildSettingsAdditionalOptions := filterSPMAdditionalOptions(config.XcodebuildAdditionalOptions)
+		if len(showbuildSettingsAdditionalOptions) != len(config.XcodebuildAdditionalOptions) {
+			s.logger.Printf("Some xcodebuild additional options are filtered out when reading build settings. Options used: %s", strings.Join(showbuildSettingsAdditionalOptions, " "))
+		}
+
+		// Open Xcode project
+		s.logger.TInfof("Opening Xcode project at path: %s for scheme: %s", config.ProjectPath, config.Scheme)
+		project, err := s.projectFactory.Create(projectmanager.InitParams{
+			ProjectOrWorkspacePath: config.ProjectPath,
+			SchemeName:             config.Scheme,
+			ConfigurationName:      config.Configuration,
+			AdditionalXcodebuildShowbuildsettingsOptions: showbuildSettingsAdditionalOptions,
+		})
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to open Project or Workspace: %w", err)
+		}
+		config.ProjectManager = project
+
+		if config.DestinationPlatform == detectPlatform && config.CodeSigningAuthSource != codeSignSourceOff {
+			// The platform is detected up front, as automatic code signing of a macOS project would only fail in xcodebuild
+			s.logger.TInfof("Platform is set to 'automatic', detecting platform from the project.")
+			if config.DestinationPlatform, err = BuildableTargetPlatform(s.logger, project); err != nil {
+				return Config{}, fmt.Errorf("failed to read project platform: %s: %s", config.ProjectPath, err)
+			}
+			s.logger.Printf("Platform type: %s", config.DestinationPlatform)
+
+			if config.DestinationPlatform == osX {
+				return Config{}, fmt.Errorf("issue with input CodeSigningAuthSource: %w", errMacosAutomaticCodeSigning)
+			}
+		}
 	}
 
-	// Open Xcode project
-	s.logger.TInfof("Opening Xcode project at path: %s for scheme: %s", config.ProjectPath, config.Scheme)
-	project, err := s.projectFactory.Create(projectmanager.InitParams{
-		ProjectOrWorkspacePath: config.ProjectPath,
-		SchemeName:             config.Scheme,
-		ConfigurationName:      config.Configuration,
-		AdditionalXcodebuildShowbuildsettingsOptions: showbuildSettingsAdditionalOptions,
-	})
-	if err != nil {
-		return Config{}, fmt.Errorf("failed to open Project or Workspace: %w", err)
+	if isUpload && !config.SkipExport && !config.DryRun {
+		s.logger.Println()
+		s.logger.Infof("Selecting the App Store Connect API key for the upload")
+		if config.UploadAPIKey, err = s.selectUploadAPIKey(config); err != nil {
+			return Config{}, fmt.Errorf("issue with input ExportDestination: %s requires an App Store Connect API key connection: %w", exportDestinationUpload, err)
+		}
 	}
-	config.ProjectManager = project
 
-	if config.CodeSigningAuthSource != codeSignSourceOff {
-		codesignManager, err := s.createCodesignManager(config, project)
+	if config.CodeSigningAuthSource != codeSignSourceOff && config.DryRun {
+		// Preparing the code signing assets would contact the Bitrise and Apple services
+		s.logger.Println()
+		s.logger.Infof("Dry run, skipping the automatic code signing preparation")
+	} else if config.CodeSigningAuthSource != codeSignSourceOff && config.SkipExport {
+		// Archive-only mode: only the archive action needs to be signed, which uses development signing
+		codesignManager, err := s.createCodesignManager(config, string(exportoptions.MethodDevelopment))
 		if err != nil {
 			return Config{}, fmt.Errorf("failed to prepare automatic code signing: %w", err)
 		}
 		config.CodesignManager = &codesignManager
+	} else if config.CodeSigningAuthSource != codeSignSourceOff {
+		codesignManager, err := s.createCodesignManager(config, config.ExportMethod)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to prepare automatic code signing: %w", err)
+		}
+		config.CodesignManager = &codesignManager
+
+		for _, exportMethod := range config.ExportMethods[1:] {
+			exportCodesignManager, err := s.createCodesignManager(config, exportMethod)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to prepare automatic code signing for %s distribution: %w", exportMethod, err)
+			}
+			config.ExportCodesignManagers = append(config.ExportCodesignManagers, &exportCodesignManager)
+		}
 	}
 
 	return config, nil
 }
 
+func (s XcodebuildArchiveConfigParser) resolveAppVersion(config Config) (AppVersion, error) {
+	if slices.Contains(config.XcodebuildAdditionalOptions, "-xcconfig") {
+		return AppVersion{}, fmt.Errorf("`-xcconfig` option found in XcodebuildOptions (`xcodebuild_options`), the versions are set through Build settings (xcconfig) (`xcconfig_content`), please use that input instead")
+	}
+
+	gitCommitCount := func() (string, error) {
+		cmd := s.cmdFactory.Create("git", []string{"rev-list", "--count", "HEAD"}, &command.Opts{Dir: filepath.Dir(config.ProjectPath)})
+		out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %s: %w", cmd.PrintableCommandArgs(), out, err)
+		}
+		return out, nil
+	}
+
+	buildNumber, err := resolveBuildNumber(BuildNumberStrategy(config.BuildNumberStrategy), config.BuildNumber, config.BuildNumberOffset, config.CIBuildNumber, gitCommitCount, time.Now())
+	if err != nil {
+		return AppVersion{}, fmt.Errorf("issue with input BuildNumberStrategy: %w", err)
+	}
+
+	if config.MarketingVersion != "" && !bundleVersionPattern.MatchString(config.MarketingVersion) {
+		return AppVersion{}, fmt.Errorf("issue with input MarketingVersion: should be one to three period-separated integers, got: %s", config.MarketingVersion)
+	}
+
+	return AppVersion{BuildNumber: buildNumber, MarketingVersion: config.MarketingVersion}, nil
+}
+
 // EnsureDependencies ...
 func (s *XcodebuildArchiver) EnsureDependencies() {
 	logFormatterVersion, err := s.xcodeCommandRunner.CheckInstall()
@@ -365,34 +715,72 @@ type RunOpts struct {
 	ArtifactName        string
 
 	// Code signing, nil if automatic code signing is "off"
-	CodesignManager *codesign.Manager
+	CodeSigningAuthSource  string
+	CodesignManager        *codesign.Manager
+	ExportCodesignManagers []*codesign.Manager
+
+	// Export-only mode, the archive action is skipped if any of them is set
+	Archive      *xcarchive.IosArchive
+	MacosArchive *xcarchive.MacosArchive
+
+	// Archive-only mode, the export action is skipped
+	SkipExport bool
 
 	// Archive
 	PerformCleanAction          bool
 	XcconfigContent             string
 	XcodebuildAdditionalOptions []string
+	DerivedDataPath             string
+	AppVersion                  AppVersion
+	ArchiveRetryRules           []RetryRule
+	BuildTimingSummary          bool
 
 	// IPA Export
 	CustomExportOptionsPlistContent string
-	ExportMethod                    string
+	ExportOptionsMode               string
+	ExportMethods                   []string
 	TestFlightInternalTestingOnly   bool
 	ICloudContainerEnvironment      string
 	ExportDevelopmentTeam           string
 	UploadBitcode                   bool
 	CompileBitcode                  bool
+	OTA                             OTAConfig
+	ProfileOverrides                []ProfileOverride
+	ExportSigningCertificate        string
+	Thinning                        string
+	ThinningSizeBudgets             []ThinningSizeBudget
+	ExportDestination               string
+	UploadAPIKey                    *devportalservice.APIKeyConnection
+	AppStoreConnectAPIBaseURL       *url.URL
+	VerifyIPA                       string
+}
+
+// IPAExport describes the result of exporting the archive with a single distribution method.
+type IPAExport struct {
+	ExportMethod      string
+	ArtifactName      string // the name of the distribution method's products
+	ExportOptionsPath string
+	IPAExportDir      string
+	ThinningReport    *ThinningReport        // set if the export is thinned
+	Upload            *UploadResult          // set if the export is uploaded to App Store Connect, no IPA is exported then
+	Verification      *IPAVerificationReport // set if the exported IPA is verified
 }
 
 // RunResult ...
 type RunResult struct {
 	Archive      *xcarchive.IosArchive
+	MacosArchive *xcarchive.MacosArchive // set instead of Archive for macOS archives
 	ArtifactName string
 
-	ExportOptionsPath string
-	IPAExportDir      string
+	IPAExports []IPAExport // in the order of the distribution methods
 
+	XcresultPath               string
+	FiredRetryRules            []string
 	XcodebuildArchiveLog       string
 	XcodebuildExportArchiveLog string
 	IDEDistrubutionLogsDir     string
+	FailureSummary             *FailureSummary // set if the archive or export action failed
+	StageTimings               []StageTiming
 }
 
 // Run ...
@@ -404,7 +792,13 @@ func (s XcodebuildArchiver) Run(opts RunOpts) (RunResult, error) {
 
 	s.logger.Println()
 
-	if opts.XcodeMajorVersion >= 11 {
+	isExportOnly := opts.Archive != nil || opts.MacosArchive != nil
+
+	// Package resolution and the archive action share the same DerivedData dir
+	opts.XcodebuildAdditionalOptions = withDerivedDataPath(opts.XcodebuildAdditionalOptions, opts.DerivedDataPath)
+
+	if opts.XcodeMajorVersion >= 11 && !isExportOnly {
+		start := time.Now()
 		s.logger.Infof("Running resolve Swift package dependencies")
 		// Resolve Swift package dependencies, so running -showBuildSettings later is faster later
 		// Specifying a scheme is required for workspaces
@@ -413,25 +807,30 @@ func (s XcodebuildArchiver) Run(opts RunOpts) (RunResult, error) {
 		if err := resolveDepsCmd.Run(); err != nil {
 			s.logger.Warnf("%s", err)
 		}
+		out.StageTimings = append(out.StageTimings, newStageTiming(stageResolvePackages, start))
 	}
 
-	if opts.ArtifactName == "" {
-		s.logger.Infof("Looking for artifact name as field is empty")
-
-		productName, err := opts.ProjectManager.ReadSchemeBuildSettingString("PRODUCT_NAME")
-		if err != nil && !serialized.IsKeyNotFoundError(err) {
-			return out, fmt.Errorf("failed to read product name build setting: %w", err)
-		}
-		if productName == "" {
-			s.logger.Warnf("Product name not found in build settings, using scheme (%s) as artifact name", opts.Scheme)
-			productName = opts.Scheme
-		}
-
-		opts.ArtifactName = productName
+	artifactName, err := s.resolveArtifactName(opts, isExportOnly)
+	if err != nil {
+		return out, err
 	}
+	// The archive is named by the product name, the artifact name template is resolved after the archive action
+	artifactNameInput := opts.ArtifactName
+	opts.ArtifactName = artifactName
 	out.ArtifactName = opts.ArtifactName
 
 	if opts.CodesignManager != nil {
+		start := time.Now()
+		// The additional distribution methods are prepared first,
+		// so that the code signing settings forced on the project belong to the archive's distribution method.
+		for i, exportCodesignManager := range opts.ExportCodesignManagers {
+			s.logger.Infof("Preparing code signing assets for %s distribution", opts.ExportMethods[i+1])
+
+			if _, _, err := exportCodesignManager.PrepareCodesigning(); err != nil {
+				return RunResult{}, fmt.Errorf("failed to manage code signing for %s distribution: %s", opts.ExportMethods[i+1], err)
+			}
+		}
+
 		s.logger.Infof("Preparing code signing assets (certificates, profiles) before Archive action")
 
 		xcodebuildAuthParams, _, err := opts.CodesignManager.PrepareCodesigning()
@@ -457,277 +856,1087 @@ func (s XcodebuildArchiver) Run(opts RunOpts) (RunResult, error) {
 				KeyPath:   privateKey,
 			}
 		}
+		out.StageTimings = append(out.StageTimings, newStageTiming(stageCodeSigning, start))
 	} else {
 		s.logger.Infof("Automatic code signing is disabled, skipped downloading code sign assets")
 	}
 	s.logger.Println()
 
-	archiveOpts := xcodeArchiveOpts{
-		ProjectManager:      opts.ProjectManager,
-		ProjectPath:         opts.ProjectPath,
-		Scheme:              opts.Scheme,
-		DestinationPlatform: opts.DestinationPlatform,
-		Configuration:       opts.Configuration,
-		XcodeMajorVersion:   opts.XcodeMajorVersion,
-		ArtifactName:        opts.ArtifactName,
-		XcodeAuthOptions:    authOptions,
-
-		PerformCleanAction: opts.PerformCleanAction,
-		XcconfigContent:    opts.XcconfigContent,
-		AdditionalOptions:  opts.XcodebuildAdditionalOptions,
-	}
-	archiveOut, err := s.xcodeArchive(archiveOpts)
-	out.XcodebuildArchiveLog = archiveOut.XcodebuildArchiveLog
-	if err != nil {
-		return out, err
+	var archiveOut xcodeArchiveResult
+	if isExportOnly {
+		s.logger.Infof("Archive path is provided, skipping the Archive action")
+		archiveOut.Archive = opts.Archive
+		archiveOut.MacosArchive = opts.MacosArchive
+
+		if opts.MacosArchive != nil {
+			printMacosArchiveInfo(*opts.MacosArchive, s.logger)
+		} else {
+			printIosArchiveInfo(*opts.Archive, s.logger)
+		}
+	} else {
+		start := time.Now()
+		var err error
+		archiveOut, err = s.xcodeArchive(xcodeArchiveOpts{
+			ProjectManager:      opts.ProjectManager,
+			ProjectPath:         opts.ProjectPath,
+			Scheme:              opts.Scheme,
+			DestinationPlatform: opts.DestinationPlatform,
+			Configuration:       opts.Configuration,
+			XcodeMajorVersion:   opts.XcodeMajorVersion,
+			ArtifactName:        opts.ArtifactName,
+			XcodeAuthOptions:    authOptions,
+
+			PerformCleanAction: opts.PerformCleanAction,
+			XcconfigContent:    opts.XcconfigContent,
+			AdditionalOptions:  opts.XcodebuildAdditionalOptions,
+			DerivedDataPath:    opts.DerivedDataPath,
+			RetryRules:         opts.ArchiveRetryRules,
+			BuildTimingSummary: opts.BuildTimingSummary,
+		})
+		out.StageTimings = append(out.StageTimings, newStageTiming(stageArchive, start))
+		out.XcresultPath = archiveOut.XcresultPath
+		out.FiredRetryRules = archiveOut.FiredRetryRules
+		out.XcodebuildArchiveLog = archiveOut.XcodebuildArchiveLog
+		if err != nil {
+			out.FailureSummary, err = summarizeFailure(failureStageArchive, err, archiveOut.XcodebuildArchiveLog, "", s.logger)
+			return out, err
+		}
 	}
 
 	out.Archive = archiveOut.Archive
+	out.MacosArchive = archiveOut.MacosArchive
 
-	IPAExportOpts := xcodeIPAExportOpts{
-		XcodeMajorVersion: opts.XcodeMajorVersion,
-		XcodeAuthOptions:  authOptions,
-
-		Archive:                         *archiveOut.Archive,
-		CustomExportOptionsPlistContent: opts.CustomExportOptionsPlistContent,
-		ExportMethod:                    opts.ExportMethod,
-		TestFlightInternalTestingOnly:   opts.TestFlightInternalTestingOnly,
-		ICloudContainerEnvironment:      opts.ICloudContainerEnvironment,
-		ExportDevelopmentTeam:           opts.ExportDevelopmentTeam,
-		UploadBitcode:                   opts.UploadBitcode,
-		CompileBitcode:                  opts.CompileBitcode,
-	}
-	exportOut, err := s.xcodeIPAExport(IPAExportOpts)
-	out.XcodebuildExportArchiveLog = exportOut.XcodebuildExportArchiveLog
-	if err != nil {
-		out.IDEDistrubutionLogsDir = exportOut.IDEDistrubutionLogsDir
-		return out, err
+	artifactNames := newArtifactNameTemplate(artifactNameInput, opts.ArtifactName, opts.Configuration, archiveOut.Archive, archiveOut.MacosArchive)
+	var mainExportMethod string
+	if len(opts.ExportMethods) > 0 {
+		mainExportMethod = opts.ExportMethods[0]
+	}
+	out.ArtifactName = artifactNames.name(mainExportMethod)
+	if isArtifactNameTemplate(artifactNameInput) {
+		s.logger.Printf("Artifact name: %s", out.ArtifactName)
 	}
 
-	out.ExportOptionsPath = exportOut.ExportOptionsPath
-	out.IPAExportDir = exportOut.IPAExportDir
-
-	return out, nil
-}
-
-// ExportOpts ...
-type ExportOpts struct {
-	OutputDir      string
-	ArtifactName   string
-	ExportAllDsyms bool
+	if !isExportOnly && !opts.AppVersion.IsEmpty() {
+		if err := checkArchivedVersions(archivedInfoPlists(archiveOut.Archive, archiveOut.MacosArchive), opts.AppVersion); err != nil {
+			return out, err
+		}
+		s.logger.Donef("Every archived bundle has the expected versions")
+	}
 
-	Archive *xcarchive.IosArchive
+	if opts.SkipExport {
+		s.logger.Println()
+		s.logger.Infof("SkipExport is set, skipping the Export action")
+		return out, nil
+	}
 
-	ExportOptionsPath string
-	IPAExportDir      string
+	exportStart := time.Now()
 
-	XcodebuildArchiveLog       string
-	XcodebuildExportArchiveLog string
-	IDEDistrubutionLogsDir     string
-}
+	isUpload := opts.ExportDestination == exportDestinationUpload && archiveOut.Archive != nil &&
+		(opts.CustomExportOptionsPlistContent == "" || opts.ExportOptionsMode == ExportOptionsModeMerge)
+	if opts.ExportDestination == exportDestinationUpload && archiveOut.MacosArchive != nil {
+		s.logger.Warnf("ExportDestination %s is ignored, as it is not supported for macOS archives", exportDestinationUpload)
+	}
 
-// ExportOutput ...
-func (s XcodebuildArchiver) ExportOutput(opts ExportOpts) error {
-	s.logger.Println()
-	s.logger.TInfof("Exporting outputs...")
+	uploadAuthOptions := authOptions
+	if isUpload && uploadAuthOptions == nil && opts.UploadAPIKey != nil {
+		privateKey, err := opts.UploadAPIKey.WritePrivateKeyToFile()
+		if err != nil {
+			return out, err
+		}
 
-	cleanup := func(pth string) error {
-		if exist, err := v1pathutil.IsPathExists(pth); err != nil {
-			return fmt.Errorf("failed to check if path (%s) exist, error: %s", pth, err)
-		} else if exist {
-			if err := os.RemoveAll(pth); err != nil {
-				return fmt.Errorf("failed to remove path (%s), error: %s", pth, err)
+		defer func() {
+			if err := os.Remove(privateKey); err != nil {
+				s.logger.Warnf("failed to remove private key file: %s", err)
 			}
+		}()
+
+		uploadAuthOptions = &xcodebuild.AuthenticationParams{
+			KeyID:     opts.UploadAPIKey.KeyID,
+			IsssuerID: opts.UploadAPIKey.IssuerID,
+			KeyPath:   privateKey,
 		}
-		return nil
 	}
 
-	if opts.Archive != nil {
-		archivePath := opts.Archive.Path
-		if err := ExportOutputDir(s.cmdFactory, archivePath, archivePath, bitriseXCArchivePthEnvKey, s.logger); err != nil {
-			return fmt.Errorf("failed to export %s, error: %s", bitriseXCArchivePthEnvKey, err)
-		}
-		s.logger.Donef("The xcarchive path is now available in the Environment Variable: %s (value: %s)", bitriseXCArchivePthEnvKey, archivePath)
+	// The size budget and IPA verification check errors fail the Step once every export is done
+	var budgetErrs, verificationErrs []string
+	for i, exportMethod := range opts.ExportMethods {
+		isUploadExport := isUpload && exportoptions.Method(exportMethod).IsAppStore()
+		exportName := artifactNames.exportName(exportMethod, i == 0)
 
-		archiveZipPath := filepath.Join(opts.OutputDir, opts.ArtifactName+".xcarchive.zip")
-		if err := cleanup(archiveZipPath); err != nil {
-			return err
+		var exportOut xcodeIPAExportResult
+		var err error
+		if archiveOut.MacosArchive != nil {
+			exportOut, err = s.xcodeMacosExport(xcodeMacosExportOpts{
+				XcodeAuthOptions: authOptions,
+
+				Archive:                         *archiveOut.MacosArchive,
+				CustomExportOptionsPlistContent: opts.CustomExportOptionsPlistContent,
+				ExportOptionsMode:               opts.ExportOptionsMode,
+				ExportMethod:                    exportMethod,
+				ExportDevelopmentTeam:           opts.ExportDevelopmentTeam,
+			})
+		} else {
+			exportOut, err = s.xcodeIPAExport(xcodeIPAExportOpts{
+				XcodeMajorVersion: opts.XcodeMajorVersion,
+				XcodeAuthOptions:  authOptions,
+
+				Archive:                         *archiveOut.Archive,
+				CustomExportOptionsPlistContent: opts.CustomExportOptionsPlistContent,
+				ExportOptionsMode:               opts.ExportOptionsMode,
+				ExportMethod:                    exportMethod,
+				TestFlightInternalTestingOnly:   opts.TestFlightInternalTestingOnly,
+				ICloudContainerEnvironment:      opts.ICloudContainerEnvironment,
+				ExportDevelopmentTeam:           opts.ExportDevelopmentTeam,
+				UploadBitcode:                   opts.UploadBitcode,
+				CompileBitcode:                  opts.CompileBitcode,
+				OTA:                             opts.OTA.expand(exportName),
+				ProfileOverrides:                opts.ProfileOverrides,
+				ExportSigningCertificate:        opts.ExportSigningCertificate,
+				Thinning:                        opts.Thinning,
+				Upload:                          isUploadExport,
+				UploadAuthOptions:               uploadAuthOptions,
+			})
 		}
+		out.XcodebuildExportArchiveLog += exportOut.XcodebuildExportArchiveLog
 
-		if err := ExportOutputDirAsZip(s.cmdFactory, archivePath, archiveZipPath, bitriseXCArchiveZipPthEnvKey, s.logger); err != nil {
-			return fmt.Errorf("failed to export %s, error: %s", bitriseXCArchiveZipPthEnvKey, err)
+		var upload *UploadResult
+		if isUploadExport {
+			result := parseUploadResult(exportOut.XcodebuildExportArchiveLog, err == nil)
+			upload = &result
 		}
-		s.logger.Donef("The xcarchive zip path is now available in the Environment Variable: %s (value: %s)", bitriseXCArchiveZipPthEnvKey, archiveZipPath)
 
-		appPath := filepath.Join(opts.OutputDir, opts.ArtifactName+".app")
-		if err := cleanup(appPath); err != nil {
-			return err
+		if err != nil {
+			if upload != nil {
+				out.IPAExports = append(out.IPAExports, IPAExport{ExportMethod: exportMethod, ArtifactName: exportName, Upload: upload})
+			}
+			out.IDEDistrubutionLogsDir = exportOut.IDEDistrubutionLogsDir
+			out.FailureSummary, err = summarizeFailure(failureStageExport, err, exportOut.XcodebuildExportArchiveLog, exportOut.IDEDistrubutionLogsDir, s.logger)
+			out.StageTimings = append(out.StageTimings, newStageTiming(stageExport, exportStart))
+			return out, err
 		}
 
-		if err := ExportOutputDir(s.cmdFactory, opts.Archive.Application.Path, appPath, bitriseAppDirPthEnvKey, s.logger); err != nil {
-			return fmt.Errorf("failed to export %s, error: %s", bitriseAppDirPthEnvKey, err)
+		ipaExport := IPAExport{
+			ExportMethod:      exportMethod,
+			ArtifactName:      exportName,
+			ExportOptionsPath: exportOut.ExportOptionsPath,
+			IPAExportDir:      exportOut.IPAExportDir,
+			Upload:            upload,
 		}
-		s.logger.Donef("The app directory is now available in the Environment Variable: %s (value: %s)", bitriseAppDirPthEnvKey, appPath)
-
-		s.logger.Printf("Looking for app and framework dSYMs.")
-
-		appDSYMPaths, frameworkDSYMPaths, err := opts.Archive.FindDSYMs()
-		if err != nil {
-			return fmt.Errorf("failed to export dSYMs, error: %s", err)
+		if upload != nil {
+			s.logger.Donef("Uploaded to App Store Connect (%s distribution)", exportMethod)
+			s.lookUpUploadedBuild(opts, *archiveOut.Archive, upload)
 		}
-
-		appDSYMPathsCount := len(appDSYMPaths)
-		frameworkDSYMPathsCount := len(frameworkDSYMPaths)
-
-		s.logger.Printf("Found %d app dSYMs and %d framework dSYMs.", appDSYMPathsCount, frameworkDSYMPathsCount)
-
-		if appDSYMPathsCount > 0 || frameworkDSYMPathsCount > 0 {
-			dsymDir, err := v1pathutil.NormalizedOSTempDirPath("__dsyms__")
-			if err != nil {
-				return fmt.Errorf("failed to create tmp dir, error: %s", err)
-			}
-
-			if appDSYMPathsCount > 0 {
-				if err := ExportDSYMs(dsymDir, appDSYMPaths); err != nil {
-					return fmt.Errorf("failed to export dSYMs: %v", err)
-				}
-			} else {
-				s.logger.Warnf("No app dSYMs found to export")
-			}
-
-			if opts.ExportAllDsyms && frameworkDSYMPathsCount > 0 {
-				if err := ExportDSYMs(dsymDir, frameworkDSYMPaths); err != nil {
-					return fmt.Errorf("failed to export dSYMs: %v", err)
+		if archiveOut.Archive != nil && opts.Thinning != "" && opts.Thinning != thinningNone {
+			if ipaExport.ThinningReport, err = readThinningReport(exportOut.ExportOptionsPath, exportOut.IPAExportDir); err != nil {
+				s.logger.Warnf("Failed to read the app thinning size report, error: %s", err)
+				if len(opts.ThinningSizeBudgets) > 0 {
+					budgetErrs = append(budgetErrs, fmt.Sprintf("the size budgets can not be checked (%s distribution), failed to read the app thinning size report: %s", exportMethod, err))
 				}
 			}
-
-			if err := ExportOutputDir(s.cmdFactory, dsymDir, dsymDir, bitriseDSYMDirPthEnvKey, s.logger); err != nil {
-				return fmt.Errorf("failed to export %s, error: %s", bitriseDSYMDirPthEnvKey, err)
-			}
-			s.logger.Donef("The dSYM dir path is now available in the Environment Variable: %s (value: %s)", bitriseDSYMDirPthEnvKey, dsymDir)
-
-			dsymZipPath := filepath.Join(opts.OutputDir, opts.ArtifactName+".dSYM.zip")
-			if err := cleanup(dsymZipPath); err != nil {
-				return err
-			}
-
-			if err := ExportOutputDirAsZip(s.cmdFactory, dsymDir, dsymZipPath, bitriseDSYMPthEnvKey, s.logger); err != nil {
-				return fmt.Errorf("failed to export %s, error: %s", bitriseDSYMPthEnvKey, err)
+		}
+		if archiveOut.Archive != nil && upload == nil && opts.VerifyIPA != "" && opts.VerifyIPA != ipaVerificationOff {
+			if err := s.checkExportedIPA(&ipaExport, *archiveOut.Archive, opts.ExportDevelopmentTeam, opts.VerifyIPA); err != nil {
+				verificationErrs = append(verificationErrs, err.Error())
 			}
-			s.logger.Donef("The dSYM zip path is now available in the Environment Variable: %s (value: %s)", bitriseDSYMPthEnvKey, dsymZipPath)
 		}
+		out.IPAExports = append(out.IPAExports, ipaExport)
 	}
-
-	if opts.ExportOptionsPath != "" {
-		exportOptionsPath := filepath.Join(opts.OutputDir, "export_options.plist")
-		if err := cleanup(exportOptionsPath); err != nil {
-			return err
+	out.StageTimings = append(out.StageTimings, newStageTiming(stageExport, exportStart))
+
+	var checkErrs []string
+	if len(opts.ThinningSizeBudgets) > 0 {
+		checkedReports := 0
+		for _, ipaExport := range out.IPAExports {
+			if ipaExport.ThinningReport == nil {
+				continue
+			}
+			checkedReports++
+			if err := checkThinningSizeBudgets(ipaExport.ExportMethod, *ipaExport.ThinningReport, opts.ThinningSizeBudgets); err != nil {
+				budgetErrs = append(budgetErrs, err.Error())
+			}
 		}
-
-		if err := v1command.CopyFile(opts.ExportOptionsPath, exportOptionsPath); err != nil {
-			return err
+		if checkedReports > 0 && len(budgetErrs) == 0 {
+			s.logger.Donef("Every thinned variant is within its size budget")
 		}
+		checkErrs = append(checkErrs, budgetErrs...)
 	}
 
-	if opts.IPAExportDir != "" {
-		fileList := []string{}
-		ipaFiles := []string{}
-		if walkErr := filepath.Walk(opts.IPAExportDir, func(pth string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+	checkErrs = append(checkErrs, verificationErrs...)
 
-			fileList = append(fileList, pth)
+	if len(checkErrs) > 0 {
+		return out, errors.New(strings.Join(checkErrs, "\n"))
+	}
 
-			if filepath.Ext(pth) == ".ipa" {
-				ipaFiles = append(ipaFiles, pth)
-			}
+	return out, nil
+}
 
-			return nil
-		}); walkErr != nil {
-			return fmt.Errorf("failed to search for .ipa file, error: %s", walkErr)
+// checkExportedIPA verifies the exported IPA and sets the verification report of the export.
+// In fail mode the verification errors, or the failure of the verification itself, are returned as a check error.
+func (s XcodebuildArchiver) checkExportedIPA(ipaExport *IPAExport, archive xcarchive.IosArchive, teamID, mode string) error {
+	report, err := s.verifyExportedIPA(*ipaExport, archive, teamID)
+	if err != nil {
+		s.logger.Warnf("Failed to verify the exported IPA, error: %s", err)
+		if mode == ipaVerificationFail {
+			return fmt.Errorf("IPA verification failed (%s): %w", ipaExport.ExportMethod, err)
 		}
+		return nil
+	}
+	ipaExport.Verification = report
 
-		if len(ipaFiles) == 0 {
-			s.logger.Printf("File list in the export dir:")
-			for _, pth := range fileList {
-				s.logger.Printf("- %s", pth)
+	if mode != ipaVerificationFail || len(report.Errors()) == 0 {
+		return nil
+	}
+	var findings []string
+	for _, finding := range report.Errors() {
+		findings = append(findings, "- "+finding.String())
+	}
+	return fmt.Errorf("IPA verification failed (%s):\n%s", ipaExport.ExportMethod, strings.Join(findings, "\n"))
+}
+
+// verifyExportedIPA verifies the exported IPA against the archived application and the distribution method, and logs the findings.
+func (s XcodebuildArchiver) verifyExportedIPA(ipaExport IPAExport, archive xcarchive.IosArchive, teamID string) (*IPAVerificationReport, error) {
+	s.logger.Println()
+	s.logger.Infof("Verifying the exported IPA (%s distribution)...", ipaExport.ExportMethod)
+
+	var variantPaths map[string]string
+	if ipaExport.ThinningReport != nil {
+		var err error
+		if variantPaths, err = variantIPAPaths(ipaExport.IPAExportDir, *ipaExport.ThinningReport); err != nil {
+			return nil, err
+		}
+	}
+	ipaFiles, _, err := findExportedIPAs(ipaExport.IPAExportDir, variantPaths)
+	if err != nil {
+		return nil, err
+	}
+	if len(ipaFiles) == 0 {
+		return nil, fmt.Errorf("no .ipa file found at export dir: %s", ipaExport.IPAExportDir)
+	}
+
+	if teamID == "" {
+		if teamID, err = archive.TeamID(); err != nil {
+			s.logger.Warnf("Failed to read the archive's team, the provisioning profiles' team is not verified: %s", err)
+		}
+	}
+	// The export dir can contain other .ipa files too, for example of an App Clip
+	mainIPA, _, err := identifyExportedIPAs(ipaFiles, archive.Application.BundleIdentifier())
+	if err != nil {
+		return nil, err
+	}
+
+	version, _ := archive.Application.InfoPlist.GetString(bundleShortVersionKey)
+	buildNumber, _ := archive.Application.InfoPlist.GetString(bundleVersionKey)
+
+	report, err := verifyIPA(mainIPA.Path, ipaExpectations{
+		ExportMethod: exportoptions.Method(ipaExport.ExportMethod),
+		BundleID:     archive.Application.BundleIdentifier(),
+		Version:      version,
+		BuildNumber:  buildNumber,
+		TeamID:       teamID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(report.Findings) == 0 {
+		s.logger.Donef("The exported IPA passed every check")
+	}
+	for _, finding := range report.Findings {
+		if finding.Severity == ipaFindingError {
+			s.logger.Errorf("%s", finding)
+		} else {
+			s.logger.Warnf("%s", finding)
+		}
+	}
+
+	return &report, nil
+}
+
+// lookUpUploadedBuild sets the ID and processing state of the uploaded build on the upload result.
+// The upload already succeeded, so a failing lookup is only logged.
+func (s XcodebuildArchiver) lookUpUploadedBuild(opts RunOpts, archive xcarchive.IosArchive, upload *UploadResult) {
+	if opts.UploadAPIKey == nil {
+		return
+	}
+
+	bundleID := archive.Application.BundleIdentifier()
+	buildNumber, _ := archive.Application.InfoPlist.GetString(bundleVersionKey)
+
+	s.logger.Printf("Looking up build %s of %s on App Store Connect (waiting up to %s)", buildNumber, bundleID, uploadedBuildLookupTimeout)
+	client := newAppStoreConnectClient(*opts.UploadAPIKey, opts.AppStoreConnectAPIBaseURL, s.logger)
+	buildID, processingState, err := waitForUploadedBuild(client, bundleID, buildNumber, uploadedBuildLookupTimeout, uploadedBuildLookupInterval)
+	if err != nil {
+		s.logger.Warnf("Failed to look up the uploaded build on App Store Connect, error: %s", err)
+		return
+	}
+	if buildID == "" {
+		s.logger.Printf("Build %s of %s is not available on App Store Connect yet, the upload is still being received", buildNumber, bundleID)
+		return
+	}
+
+	upload.BuildID, upload.ProcessingState = buildID, processingState
+	s.logger.Printf("Build %s of %s on App Store Connect: %s (processing state: %s)", buildNumber, bundleID, buildID, processingState)
+}
+
+// exportArtifactName returns the artifact name of a distribution method's products,
+// the first distribution method's artifacts keep the unsuffixed name.
+func exportArtifactName(artifactName, exportMethod string, isMainExport bool) string {
+	if isMainExport {
+		return artifactName
+	}
+	return artifactName + "-" + exportMethod
+}
+
+// resolveArtifactName returns the artifact name input, or if it is empty (or a template), the archived application's name (in export-only mode)
+// or the product name of the scheme.
+func (s XcodebuildArchiver) resolveArtifactName(opts RunOpts, isExportOnly bool) (string, error) {
+	isTemplate := isArtifactNameTemplate(opts.ArtifactName)
+	if opts.ArtifactName != "" && !isTemplate {
+		return opts.ArtifactName, nil
+	}
+
+	if isExportOnly {
+		artifactName := artifactNameFromArchive(opts.Archive, opts.MacosArchive)
+		if !isTemplate {
+			s.logger.Infof("Artifact name is empty, using the archived application's name: %s", artifactName)
+		}
+		return artifactName, nil
+	}
+
+	if isTemplate {
+		s.logger.Infof("Looking for the product name of the artifact name template")
+	} else {
+		s.logger.Infof("Looking for artifact name as field is empty")
+	}
+
+	productName, err := opts.ProjectManager.ReadSchemeBuildSettingString("PRODUCT_NAME")
+	if err != nil && !serialized.IsKeyNotFoundError(err) {
+		return "", fmt.Errorf("failed to read product name build setting: %w", err)
+	}
+	if productName == "" {
+		s.logger.Warnf("Product name not found in build settings, using scheme (%s) as artifact name", opts.Scheme)
+		productName = opts.Scheme
+	}
+
+	return productName, nil
+}
+
+// ExportOpts ...
+type ExportOpts struct {
+	OutputDir      string
+	ArtifactName   string
+	ExportAllDsyms bool
+
+	Scheme        string
+	Configuration string
+	XcodeVersion  xcodeversion.Version
+
+	Archive      *xcarchive.IosArchive
+	MacosArchive *xcarchive.MacosArchive
+
+	IPAExports []IPAExport
+
+	XcresultPath               string
+	FiredRetryRules            []string
+	XcodebuildArchiveLog       string
+	XcodebuildExportArchiveLog string
+	IDEDistrubutionLogsDir     string
+	FailureSummary             *FailureSummary
+
+	BuildTimingReport bool
+	StageTimings      []StageTiming
+
+	DerivedDataPath     string
+	DerivedDataCacheKey string
+
+	AppVersion AppVersion
+	OTA        OTAConfig
+}
+
+// ExportOutput ...
+func (s XcodebuildArchiver) ExportOutput(opts ExportOpts) error {
+	start := time.Now()
+	s.logger.Println()
+	s.logger.TInfof("Exporting outputs...")
+
+	manifest := ArtifactManifest{Archive: newArchiveManifest(opts)}
+
+	var (
+		archivePath     string
+		applicationPath string
+		findDSYMs       func() ([]string, []string, error)
+	)
+	if opts.Archive != nil {
+		archivePath = opts.Archive.Path
+		applicationPath = opts.Archive.Application.Path
+		findDSYMs = opts.Archive.FindDSYMs
+	} else if opts.MacosArchive != nil {
+		archivePath = opts.MacosArchive.Path
+		applicationPath = opts.MacosArchive.Application.Path
+		findDSYMs = opts.MacosArchive.FindDSYMs
+	}
+
+	if archivePath != "" {
+		if err := ExportOutputDir(s.outputExporter, archivePath, archivePath, bitriseXCArchivePthEnvKey, s.logger); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", bitriseXCArchivePthEnvKey, err)
+		}
+		s.logger.Donef("The xcarchive path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseXCArchivePthEnvKey, archivePath)
+
+		archiveZipPath := filepath.Join(opts.OutputDir, opts.ArtifactName+".xcarchive.zip")
+		if err := cleanup(archiveZipPath); err != nil {
+			return err
+		}
+
+		if err := ExportOutputDirAsZip(s.outputExporter, archivePath, archiveZipPath, bitriseXCArchiveZipPthEnvKey, ZipLevelDefault, s.logger); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", bitriseXCArchiveZipPthEnvKey, err)
+		}
+		s.logger.Donef("The xcarchive zip path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseXCArchiveZipPthEnvKey, archiveZipPath)
+		manifest.addArtifact(artifactKindXCArchiveZip, archiveZipPath, "")
+
+		appPath := filepath.Join(opts.OutputDir, opts.ArtifactName+".app")
+		if err := cleanup(appPath); err != nil {
+			return err
+		}
+
+		if err := ExportOutputDir(s.outputExporter, applicationPath, appPath, bitriseAppDirPthEnvKey, s.logger); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", bitriseAppDirPthEnvKey, err)
+		}
+		s.logger.Donef("The app directory is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseAppDirPthEnvKey, appPath)
+
+		s.logger.Printf("Looking for app and framework dSYMs.")
+
+		appDSYMPaths, frameworkDSYMPaths, err := findDSYMs()
+		if err != nil {
+			return fmt.Errorf("failed to export dSYMs, error: %s", err)
+		}
+
+		appDSYMPathsCount := len(appDSYMPaths)
+		frameworkDSYMPathsCount := len(frameworkDSYMPaths)
+
+		s.logger.Printf("Found %d app dSYMs and %d framework dSYMs.", appDSYMPathsCount, frameworkDSYMPathsCount)
+
+		if appDSYMPathsCount > 0 || frameworkDSYMPathsCount > 0 {
+			dsymDir, err := v1pathutil.NormalizedOSTempDirPath("__dsyms__")
+			if err != nil {
+				return fmt.Errorf("failed to create tmp dir, error: %s", err)
+			}
+
+			if appDSYMPathsCount > 0 {
+				if err := ExportDSYMs(dsymDir, appDSYMPaths); err != nil {
+					return fmt.Errorf("failed to export dSYMs: %v", err)
+				}
+			} else {
+				s.logger.Warnf("No app dSYMs found to export")
+			}
+
+			if opts.ExportAllDsyms && frameworkDSYMPathsCount > 0 {
+				if err := ExportDSYMs(dsymDir, frameworkDSYMPaths); err != nil {
+					return fmt.Errorf("failed to export dSYMs: %v", err)
+				}
+			}
+
+			if err := ExportOutputDir(s.outputExporter, dsymDir, dsymDir, bitriseDSYMDirPthEnvKey, s.logger); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", bitriseDSYMDirPthEnvKey, err)
+			}
+			s.logger.Donef("The dSYM dir path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseDSYMDirPthEnvKey, dsymDir)
+
+			dsymZipPath := filepath.Join(opts.OutputDir, opts.ArtifactName+".dSYM.zip")
+			if err := cleanup(dsymZipPath); err != nil {
+				return err
+			}
+
+			if err := ExportOutputDirAsZip(s.outputExporter, dsymDir, dsymZipPath, bitriseDSYMPthEnvKey, ZipLevelDefault, s.logger); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", bitriseDSYMPthEnvKey, err)
+			}
+			s.logger.Donef("The dSYM zip path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseDSYMPthEnvKey, dsymZipPath)
+			manifest.addArtifact(artifactKindDSYMZip, dsymZipPath, "")
+		}
+	}
+
+	var (
+		ipaPaths     []string
+		exportedIPAs []ExportedIPA
+	)
+	for i, ipaExport := range opts.IPAExports {
+		// The first distribution method's artifacts keep the unsuffixed names and outputs.
+		isMainExport := i == 0
+		artifactName := ipaExport.ArtifactName
+		exportOptionsName := "export_options"
+		if !isMainExport {
+			exportOptionsName += "-" + ipaExport.ExportMethod
+		}
+
+		if ipaExport.ExportOptionsPath != "" {
+			exportOptionsPath := filepath.Join(opts.OutputDir, exportOptionsName+".plist")
+			if err := cleanup(exportOptionsPath); err != nil {
+				return err
+			}
+
+			if err := v1command.CopyFile(ipaExport.ExportOptionsPath, exportOptionsPath); err != nil {
+				return err
+			}
+			manifest.addArtifact(artifactKindExportOptions, exportOptionsPath, ipaExport.ExportMethod)
+		}
+
+		if ipaExport.Upload != nil {
+			s.exportUploadResult(*ipaExport.Upload)
+			continue
+		}
+
+		if ipaExport.IPAExportDir == "" {
+			continue
+		}
+
+		if opts.MacosArchive != nil {
+			if err := s.exportMacosProducts(ipaExport.IPAExportDir, opts.OutputDir, artifactName, ipaExport.ExportMethod, isMainExport, &manifest); err != nil {
+				return err
 			}
-			return fmt.Errorf("No .ipa file found at export dir: %s", opts.IPAExportDir)
+			continue
+		}
+
+		ipaPath := filepath.Join(opts.OutputDir, artifactName+".ipa")
+		envKeys := []string{exportMethodEnvKey(bitriseIPAPthEnvKey, ipaExport.ExportMethod)}
+		if isMainExport {
+			envKeys = append([]string{bitriseIPAPthEnvKey}, envKeys...)
+		}
+		var variantPaths map[string]string
+		if ipaExport.ThinningReport != nil {
+			var err error
+			if variantPaths, err = variantIPAPaths(ipaExport.IPAExportDir, *ipaExport.ThinningReport); err != nil {
+				return err
+			}
+		}
+		ipas, err := s.exportIPA(ipaExport.IPAExportDir, ipaPath, opts.Archive.Application.BundleIdentifier(), envKeys, variantPaths)
+		if err != nil {
+			return err
+		}
+		ipaPaths = append(ipaPaths, ipaPath)
+		for _, ipa := range ipas {
+			exportedIPAs = append(exportedIPAs, ipa)
+			manifest.addArtifact(artifactKindIPA, ipa.Path, ipaExport.ExportMethod)
+		}
+
+		if ipaExport.ThinningReport != nil {
+			if err := s.exportThinningReport(ipaExport, variantPaths, opts.OutputDir, artifactName, isMainExport, &manifest); err != nil {
+				return err
+			}
+		}
+
+		if err := s.exportOTAManifest(ipaExport, opts.OutputDir, artifactName, opts.OTA.expand(artifactName), isMainExport, &manifest); err != nil {
+			s.logger.Warnf("Failed to export the over-the-air installation manifest, error: %s", err)
+		}
+
+		if ipaExport.Verification != nil {
+			if err := s.exportIPAVerificationReport(*ipaExport.Verification, ipaPath, opts.OutputDir, artifactName, isMainExport, &manifest); err != nil {
+				s.logger.Warnf("Failed to export the IPA verification report, error: %s", err)
+			}
+		}
+	}
+
+	if len(ipaPaths) > 1 {
+		ipaPathList := strings.Join(ipaPaths, "|")
+		if err := s.outputExporter.ExportOutput(bitriseIPAPthsEnvKey, ipaPathList); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", bitriseIPAPthsEnvKey, err)
+		}
+		s.logger.Donef("The ipa path list is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseIPAPthsEnvKey, ipaPathList)
+	}
+
+	if len(exportedIPAs) > 0 {
+		var ipaList []string
+		for _, ipa := range exportedIPAs {
+			ipaList = append(ipaList, exportedIPAName(ipa)+":"+ipa.Path)
+		}
+		ipaPathList := strings.Join(ipaList, "|")
+		if err := s.outputExporter.ExportOutput(bitriseIPAPthListEnvKey, ipaPathList); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", bitriseIPAPthListEnvKey, err)
+		}
+		s.logger.Donef("The bundle ID and ipa path list is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseIPAPthListEnvKey, ipaPathList)
+	}
+
+	if len(opts.FiredRetryRules) > 0 {
+		firedRetryRules := strings.Join(opts.FiredRetryRules, "|")
+		if err := s.outputExporter.ExportOutput(bitriseRetryRulesEnvKey, firedRetryRules); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", bitriseRetryRulesEnvKey, err)
+		} else {
+			s.logger.Donef("The fired retry rules are now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseRetryRulesEnvKey, firedRetryRules)
+		}
+	}
+
+	for _, output := range []struct{ envKey, value, description string }{
+		{bitriseAppBuildNumberEnvKey, opts.AppVersion.BuildNumber, "build number"},
+		{bitriseAppMarketingVersionEnvKey, opts.AppVersion.MarketingVersion, "marketing version"},
+	} {
+		if output.value == "" {
+			continue
+		}
+		if err := s.outputExporter.ExportOutput(output.envKey, output.value); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", output.envKey, err)
+		} else {
+			s.logger.Donef("The %s is now exported to %s: %s (value: %s)", output.description, s.outputExporter.Describe(), output.envKey, output.value)
+		}
+	}
+
+	if opts.DerivedDataPath != "" {
+		if err := s.outputExporter.ExportOutput(bitriseDerivedDataPthEnvKey, opts.DerivedDataPath); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", bitriseDerivedDataPthEnvKey, err)
+		} else {
+			s.logger.Donef("The DerivedData path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseDerivedDataPthEnvKey, opts.DerivedDataPath)
+		}
+
+		if err := s.outputExporter.ExportOutput(bitriseDerivedDataCacheKeyEnvKey, opts.DerivedDataCacheKey); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", bitriseDerivedDataCacheKeyEnvKey, err)
+		} else {
+			s.logger.Donef("The DerivedData cache key is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseDerivedDataCacheKeyEnvKey, opts.DerivedDataCacheKey)
+		}
+	}
+
+	if opts.XcresultPath != "" {
+		if err := s.exportXcresult(opts.XcresultPath, opts.OutputDir, opts.ArtifactName, &manifest); err != nil {
+			s.logger.Warnf("Failed to export the result bundle, error: %s", err)
+		}
+	}
+
+	if opts.IDEDistrubutionLogsDir != "" {
+		ideDistributionLogsZipPath := filepath.Join(opts.OutputDir, "xcodebuild.xcdistributionlogs.zip")
+		if err := cleanup(ideDistributionLogsZipPath); err != nil {
+			return err
+		}
+
+		if err := ExportOutputDirAsZip(s.outputExporter, opts.IDEDistrubutionLogsDir, ideDistributionLogsZipPath, bitriseIDEDistributionLogsPthEnvKey, ZipLevelDefault, s.logger); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", bitriseIDEDistributionLogsPthEnvKey, err)
+		} else {
+			s.logger.Donef("The xcdistributionlogs zip path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseIDEDistributionLogsPthEnvKey, ideDistributionLogsZipPath)
+			manifest.addArtifact(artifactKindIDEDistributionLogs, ideDistributionLogsZipPath, "")
+		}
+	}
+
+	if opts.XcodebuildArchiveLog != "" {
+		xcodebuildArchiveLogPath := filepath.Join(opts.OutputDir, xcodebuildArchiveLogFilename)
+		if err := cleanup(xcodebuildArchiveLogPath); err != nil {
+			return err
+		}
+
+		if err := ExportOutputFileContent(s.outputExporter, opts.XcodebuildArchiveLog, xcodebuildArchiveLogPath, xcodebuildArchiveLogPathEnvKey); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", xcodebuildArchiveLogPathEnvKey, err)
+		} else {
+			s.logger.Donef("The xcodebuild archive log path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), xcodebuildArchiveLogPathEnvKey, xcodebuildArchiveLogPath)
+			manifest.addArtifact(artifactKindArchiveLog, xcodebuildArchiveLogPath, "")
+		}
+	}
+
+	if opts.XcodebuildExportArchiveLog != "" {
+		xcodebuildExportArchiveLogPath := filepath.Join(opts.OutputDir, xcodebuildExportArchiveLogFilename)
+		if err := cleanup(xcodebuildExportArchiveLogPath); err != nil {
+			return err
+		}
+
+		if err := ExportOutputFileContent(s.outputExporter, opts.XcodebuildExportArchiveLog, xcodebuildExportArchiveLogPath, xcodebuildExportArchiveLogPathEnvKey); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", xcodebuildExportArchiveLogPathEnvKey, err)
+		} else {
+			s.logger.Donef("The xcodebuild -exportArchive log path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), xcodebuildExportArchiveLogPathEnvKey, xcodebuildExportArchiveLogPath)
+			manifest.addArtifact(artifactKindExportArchiveLog, xcodebuildExportArchiveLogPath, "")
+		}
+	}
+
+	if opts.BuildTimingReport {
+		report := TimingReport{
+			BuildPhases: parseBuildTimingSummary(opts.XcodebuildArchiveLog),
+			StepStages:  append(opts.StageTimings, newStageTiming(stageOutput, start)),
+		}
+		if err := s.exportTimingReport(report, opts.OutputDir, &manifest); err != nil {
+			s.logger.Warnf("Failed to export the timing report, error: %s", err)
+		}
+	}
+
+	if opts.FailureSummary != nil {
+		failureSummaryPath := filepath.Join(opts.OutputDir, failureSummaryFilename)
+		if err := cleanup(failureSummaryPath); err != nil {
+			return err
+		}
+
+		if content, err := json.MarshalIndent(opts.FailureSummary, "", "  "); err != nil {
+			s.logger.Warnf("Failed to encode the failure summary, error: %s", err)
+		} else if err := ExportOutputFileContent(s.outputExporter, string(content), failureSummaryPath, bitriseFailureSummaryPthEnvKey); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", bitriseFailureSummaryPthEnvKey, err)
+		} else {
+			s.logger.Donef("The failure summary path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseFailureSummaryPthEnvKey, failureSummaryPath)
+			manifest.addArtifact(artifactKindFailureSummary, failureSummaryPath, "")
+		}
+	}
+
+	if err := s.exportArtifactManifest(manifest, opts.OutputDir); err != nil {
+		s.logger.Warnf("Failed to export the artifact manifest, error: %s", err)
+	}
+
+	return nil
+}
+
+// exportArtifactManifest writes the manifest of the exported artifacts into the output dir as JSON.
+func (s XcodebuildArchiver) exportArtifactManifest(manifest ArtifactManifest, outputDir string) error {
+	if err := manifest.addDigests(); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the artifact manifest: %w", err)
+	}
+
+	manifestPath := filepath.Join(outputDir, artifactManifestFilename)
+	if err := cleanup(manifestPath); err != nil {
+		return err
+	}
+	if err := ExportOutputFileContent(s.outputExporter, string(content), manifestPath, bitriseArtifactManifestPthEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", bitriseArtifactManifestPthEnvKey, err)
+	}
+	s.logger.Donef("The artifact manifest path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseArtifactManifestPthEnvKey, manifestPath)
+
+	return nil
+}
+
+// exportUploadResult exports the App Store Connect upload status and the uploaded build's details.
+func (s XcodebuildArchiver) exportUploadResult(upload UploadResult) {
+	for _, output := range []struct{ envKey, value, description string }{
+		{bitriseUploadStatusEnvKey, upload.Status, "App Store Connect upload status"},
+		{bitriseUploadDeliveryUUIDEnvKey, upload.DeliveryUUID, "App Store Connect delivery UUID"},
+		{bitriseUploadBuildIDEnvKey, upload.BuildID, "App Store Connect build ID"},
+		{bitriseUploadProcessingEnvKey, upload.ProcessingState, "App Store Connect build processing state"},
+	} {
+		if output.value == "" {
+			continue
+		}
+		if err := s.outputExporter.ExportOutput(output.envKey, output.value); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", output.envKey, err)
+		} else {
+			s.logger.Donef("The %s is now exported to %s: %s (value: %s)", output.description, s.outputExporter.Describe(), output.envKey, output.value)
+		}
+	}
+
+	for _, uploadErr := range upload.Errors {
+		s.logger.Warnf("Upload error: %s", uploadErr)
+	}
+}
+
+// exportTimingReport writes the timing report into the output dir as JSON and Markdown.
+func (s XcodebuildArchiver) exportTimingReport(report TimingReport, outputDir string, manifest *ArtifactManifest) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the timing report: %w", err)
+	}
+
+	reportPath := filepath.Join(outputDir, timingReportFilename)
+	if err := cleanup(reportPath); err != nil {
+		return err
+	}
+	if err := ExportOutputFileContent(s.outputExporter, string(content), reportPath, bitriseTimingReportPthEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", bitriseTimingReportPthEnvKey, err)
+	}
+	s.logger.Donef("The timing report path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseTimingReportPthEnvKey, reportPath)
+	manifest.addArtifact(artifactKindTimingReport, reportPath, "")
+
+	markdownPath := filepath.Join(outputDir, timingReportMDFilename)
+	if err := cleanup(markdownPath); err != nil {
+		return err
+	}
+	if err := ExportOutputFileContent(s.outputExporter, report.Markdown(), markdownPath, bitriseTimingReportMDPthEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", bitriseTimingReportMDPthEnvKey, err)
+	}
+	s.logger.Donef("The Markdown timing report path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseTimingReportMDPthEnvKey, markdownPath)
+	manifest.addArtifact(artifactKindTimingReportMarkdown, markdownPath, "")
+
+	return nil
+}
+
+// exportXcresult exports the result bundle of the archive action, and its zipped version into the output dir.
+func (s XcodebuildArchiver) exportXcresult(xcresultPath, outputDir, artifactName string, manifest *ArtifactManifest) error {
+	if err := ExportOutputDir(s.outputExporter, xcresultPath, xcresultPath, bitriseXcresultPthEnvKey, s.logger); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", bitriseXcresultPthEnvKey, err)
+	}
+	s.logger.Donef("The xcresult path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseXcresultPthEnvKey, xcresultPath)
+
+	xcresultZipPath := filepath.Join(outputDir, artifactName+".xcresult.zip")
+	if err := cleanup(xcresultZipPath); err != nil {
+		return err
+	}
+
+	if err := ExportOutputDirAsZip(s.outputExporter, xcresultPath, xcresultZipPath, bitriseXcresultZipPthEnvKey, ZipLevelStore, s.logger); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", bitriseXcresultZipPthEnvKey, err)
+	}
+	s.logger.Donef("The xcresult zip path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), bitriseXcresultZipPthEnvKey, xcresultZipPath)
+	manifest.addArtifact(artifactKindXcresultZip, xcresultZipPath, "")
+
+	return nil
+}
+
+// exportIPA exports the archived app's .ipa found in the export dir to ipaPath, and sets every given output key to its path.
+// Other .ipa files of the export dir are exported next to it, named by their app's bundle ID.
+// The thinned variants (by name) are only exported if the export dir doesn't contain any other .ipa.
+func (s XcodebuildArchiver) exportIPA(ipaExportDir, ipaPath, mainBundleID string, envKeys []string, variantPaths map[string]string) ([]ExportedIPA, error) {
+	ipaFiles, fileList, err := findExportedIPAs(ipaExportDir, variantPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ipaFiles) == 0 {
+		s.logger.Printf("File list in the export dir:")
+		for _, pth := range fileList {
+			s.logger.Printf("- %s", pth)
+		}
+		return nil, fmt.Errorf("No .ipa file found at export dir: %s", ipaExportDir)
+	}
+
+	mainIPA, otherIPAs, err := identifyExportedIPAs(ipaFiles, mainBundleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cleanup(ipaPath); err != nil {
+		return nil, err
+	}
+
+	for i, envKey := range envKeys {
+		if i == 0 {
+			if err := ExportOutputFile(s.outputExporter, mainIPA.Path, ipaPath, envKey); err != nil {
+				return nil, fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+		} else if err := s.outputExporter.ExportOutput(envKey, ipaPath); err != nil {
+			return nil, fmt.Errorf("failed to export %s, error: %s", envKey, err)
+		}
+		s.logger.Donef("The ipa path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, ipaPath)
+	}
+
+	exportedIPAs := []ExportedIPA{{BundleID: mainIPA.BundleID, Path: ipaPath}}
+	for _, ipa := range otherIPAs {
+		deployPth := strings.TrimSuffix(ipaPath, ".ipa") + "-" + exportedIPAName(ipa) + ".ipa"
+		if err := cleanup(deployPth); err != nil {
+			return nil, err
+		}
+		if err := v1command.CopyFile(ipa.Path, deployPth); err != nil {
+			return nil, fmt.Errorf("failed to copy (%s) -> (%s), error: %s", ipa.Path, deployPth, err)
+		}
+		s.logger.Printf("Exported the .ipa of %s: %s", exportedIPAName(ipa), deployPth)
+
+		exportedIPAs = append(exportedIPAs, ExportedIPA{BundleID: ipa.BundleID, Path: deployPth})
+	}
+
+	return exportedIPAs, nil
+}
+
+// findExportedIPAs returns the .ipa files of the export dir, the thinned variants are only returned if there is no other .ipa.
+// Every file path of the export dir is returned too, for debugging a missing .ipa.
+func findExportedIPAs(ipaExportDir string, variantPaths map[string]string) ([]string, []string, error) {
+	fileList := []string{}
+	ipaFiles := []string{}
+	if walkErr := filepath.Walk(ipaExportDir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		fileList = append(fileList, pth)
+
+		if filepath.Ext(pth) == ".ipa" {
+			ipaFiles = append(ipaFiles, pth)
+		}
+
+		return nil
+	}); walkErr != nil {
+		return nil, nil, fmt.Errorf("failed to search for .ipa file, error: %s", walkErr)
+	}
+
+	var nonVariantIPAFiles []string
+	for _, pth := range ipaFiles {
+		if variantPaths[filepath.Base(pth)] != pth {
+			nonVariantIPAFiles = append(nonVariantIPAFiles, pth)
+		}
+	}
+	if len(nonVariantIPAFiles) > 0 {
+		ipaFiles = nonVariantIPAFiles
+	}
+
+	return ipaFiles, fileList, nil
+}
+
+// exportThinningReport exports the app thinning size report as it is and in JSON, together with every thinned variant .ipa.
+func (s XcodebuildArchiver) exportThinningReport(ipaExport IPAExport, variantPaths map[string]string, outputDir, artifactName string, isMainExport bool, manifest *ArtifactManifest) error {
+	outputEnvKeys := func(envKey string) []string {
+		envKeys := []string{exportMethodEnvKey(envKey, ipaExport.ExportMethod)}
+		if isMainExport {
+			envKeys = append([]string{envKey}, envKeys...)
 		}
+		return envKeys
+	}
 
-		ipaPath := filepath.Join(opts.OutputDir, opts.ArtifactName+".ipa")
-		if err := cleanup(ipaPath); err != nil {
+	report := *ipaExport.ThinningReport
+	report.Variants = append([]ThinningVariant{}, report.Variants...)
+	var exportedVariantPaths []string
+	for i, variant := range report.Variants {
+		sourcePath, ok := variantPaths[variant.Variant]
+		if !ok {
+			s.logger.Warnf("Thinned variant .ipa not found in the export dir: %s", variant.Variant)
+			continue
+		}
+		variantPath := filepath.Join(outputDir, fmt.Sprintf("%s-variant-%d.ipa", artifactName, i+1))
+		if err := cleanup(variantPath); err != nil {
 			return err
 		}
+		if err := v1command.CopyFile(sourcePath, variantPath); err != nil {
+			return fmt.Errorf("failed to copy (%s) -> (%s), error: %s", sourcePath, variantPath, err)
+		}
+		report.Variants[i].IPAPath = variantPath
+		exportedVariantPaths = append(exportedVariantPaths, variantPath)
+		manifest.addArtifact(artifactKindIPAVariant, variantPath, ipaExport.ExportMethod)
+	}
+
+	if len(exportedVariantPaths) > 0 {
+		variantPathList := strings.Join(exportedVariantPaths, "|")
+		for _, envKey := range outputEnvKeys(bitriseIPAVariantPthsEnvKey) {
+			if err := s.outputExporter.ExportOutput(envKey, variantPathList); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+			s.logger.Donef("The thinned variant .ipa path list is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, variantPathList)
+		}
+	}
+
+	reportPath := filepath.Join(outputDir, artifactName+".app-thinning-size-report.txt")
+	if err := cleanup(reportPath); err != nil {
+		return err
+	}
+	for i, envKey := range outputEnvKeys(bitriseThinningReportPthEnvKey) {
+		if i == 0 {
+			if err := ExportOutputFile(s.outputExporter, report.ReportPath, reportPath, envKey); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+		} else if err := s.outputExporter.ExportOutput(envKey, reportPath); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+		}
+		s.logger.Donef("The app thinning size report path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, reportPath)
+	}
+	manifest.addArtifact(artifactKindThinningReport, reportPath, ipaExport.ExportMethod)
 
-		if err := ExportOutputFile(s.cmdFactory, ipaFiles[0], ipaPath, bitriseIPAPthEnvKey); err != nil {
-			return fmt.Errorf("failed to export %s, error: %s", bitriseIPAPthEnvKey, err)
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the app thinning size report: %w", err)
+	}
+	jsonPath := filepath.Join(outputDir, artifactName+".app-thinning-size-report.json")
+	if err := cleanup(jsonPath); err != nil {
+		return err
+	}
+	for i, envKey := range outputEnvKeys(bitriseThinningJSONPthEnvKey) {
+		if i == 0 {
+			if err := ExportOutputFileContent(s.outputExporter, string(content), jsonPath, envKey); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+		} else if err := s.outputExporter.ExportOutput(envKey, jsonPath); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", envKey, err)
 		}
-		s.logger.Donef("The ipa path is now available in the Environment Variable: %s (value: %s)", bitriseIPAPthEnvKey, ipaPath)
+		s.logger.Donef("The app thinning size report JSON path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, jsonPath)
+	}
+	manifest.addArtifact(artifactKindThinningReportJSON, jsonPath, ipaExport.ExportMethod)
 
-		if len(ipaFiles) > 1 {
-			s.logger.Warnf("More than 1 .ipa file found, exporting first one: %s", ipaFiles[0])
-			s.logger.Warnf("Moving every ipa to the BITRISE_DEPLOY_DIR")
+	return nil
+}
 
-			for i, pth := range ipaFiles {
-				if i == 0 {
-					continue
-				}
+// exportIPAVerificationReport writes the IPA verification report into the output dir as JSON.
+func (s XcodebuildArchiver) exportIPAVerificationReport(report IPAVerificationReport, ipaPath, outputDir, artifactName string, isMainExport bool, manifest *ArtifactManifest) error {
+	report.IPAPath = ipaPath
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the IPA verification report: %w", err)
+	}
 
-				base := filepath.Base(pth)
-				deployPth := filepath.Join(opts.OutputDir, base)
+	reportPath := filepath.Join(outputDir, artifactName+".ipa-verification.json")
+	if err := cleanup(reportPath); err != nil {
+		return err
+	}
 
-				if err := v1command.CopyFile(pth, deployPth); err != nil {
-					return fmt.Errorf("failed to copy (%s) -> (%s), error: %s", pth, deployPth, err)
-				}
+	envKeys := []string{exportMethodEnvKey(bitriseIPAVerificationPthEnvKey, report.ExportMethod)}
+	if isMainExport {
+		envKeys = append([]string{bitriseIPAVerificationPthEnvKey}, envKeys...)
+	}
+	for i, envKey := range envKeys {
+		if i == 0 {
+			if err := ExportOutputFileContent(s.outputExporter, string(content), reportPath, envKey); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
 			}
+		} else if err := s.outputExporter.ExportOutput(envKey, reportPath); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", envKey, err)
 		}
+		s.logger.Donef("The IPA verification report path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, reportPath)
 	}
+	manifest.addArtifact(artifactKindIPAVerificationReport, reportPath, report.ExportMethod)
 
-	if opts.IDEDistrubutionLogsDir != "" {
-		ideDistributionLogsZipPath := filepath.Join(opts.OutputDir, "xcodebuild.xcdistributionlogs.zip")
-		if err := cleanup(ideDistributionLogsZipPath); err != nil {
-			return err
-		}
+	return nil
+}
 
-		if err := ExportOutputDirAsZip(s.cmdFactory, opts.IDEDistrubutionLogsDir, ideDistributionLogsZipPath, bitriseIDEDistributionLogsPthEnvKey, s.logger); err != nil {
-			s.logger.Warnf("Failed to export %s, error: %s", bitriseIDEDistributionLogsPthEnvKey, err)
-		} else {
-			s.logger.Donef("The xcdistributionlogs zip path is now available in the Environment Variable: %s (value: %s)", bitriseIDEDistributionLogsPthEnvKey, ideDistributionLogsZipPath)
-		}
+// exportOTAManifest exports the manifest.plist written by xcodebuild for ad-hoc and enterprise exports with a manifest,
+// and if the manifest URL is known, an HTML page to install the app over-the-air.
+func (s XcodebuildArchiver) exportOTAManifest(ipaExport IPAExport, outputDir, artifactName string, ota OTAConfig, isMainExport bool, artifacts *ArtifactManifest) error {
+	exportedManifestPath := filepath.Join(ipaExport.IPAExportDir, otaManifestFilename)
+	if exist, err := s.pathChecker.IsPathExists(exportedManifestPath); err != nil {
+		return fmt.Errorf("failed to check if manifest exists: %w", err)
+	} else if !exist {
+		return nil
 	}
 
-	if opts.XcodebuildArchiveLog != "" {
-		xcodebuildArchiveLogPath := filepath.Join(opts.OutputDir, xcodebuildArchiveLogFilename)
-		if err := cleanup(xcodebuildArchiveLogPath); err != nil {
-			return err
+	manifestPath := filepath.Join(outputDir, artifactName+".manifest.plist")
+	if err := cleanup(manifestPath); err != nil {
+		return err
+	}
+	envKeys := []string{exportMethodEnvKey(bitriseIPAManifestPthEnvKey, ipaExport.ExportMethod)}
+	if isMainExport {
+		envKeys = append([]string{bitriseIPAManifestPthEnvKey}, envKeys...)
+	}
+	for i, envKey := range envKeys {
+		if i == 0 {
+			if err := ExportOutputFile(s.outputExporter, exportedManifestPath, manifestPath, envKey); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+		} else if err := s.outputExporter.ExportOutput(envKey, manifestPath); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", envKey, err)
 		}
+		s.logger.Donef("The manifest path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, manifestPath)
+	}
+	artifacts.addArtifact(artifactKindOTAManifest, manifestPath, ipaExport.ExportMethod)
 
-		if err := ExportOutputFileContent(s.cmdFactory, opts.XcodebuildArchiveLog, xcodebuildArchiveLogPath, xcodebuildArchiveLogPathEnvKey); err != nil {
-			s.logger.Warnf("Failed to export %s, error: %s", xcodebuildArchiveLogPathEnvKey, err)
-		} else {
-			s.logger.Donef("The xcodebuild archive log path is now available in the Environment Variable: %s (value: %s)", xcodebuildArchiveLogPathEnvKey, xcodebuildArchiveLogPath)
-		}
+	if ota.ManifestURL == "" {
+		return nil
 	}
 
-	if opts.XcodebuildExportArchiveLog != "" {
-		xcodebuildExportArchiveLogPath := filepath.Join(opts.OutputDir, xcodebuildExportArchiveLogFilename)
-		if err := cleanup(xcodebuildExportArchiveLogPath); err != nil {
-			return err
-		}
+	manifest, err := readOTAManifest(exportedManifestPath)
+	if err != nil {
+		return err
+	}
+	installPage, err := renderInstallPage(manifest, ota.ManifestURL)
+	if err != nil {
+		return fmt.Errorf("failed to render install page: %w", err)
+	}
 
-		if err := ExportOutputFileContent(s.cmdFactory, opts.XcodebuildExportArchiveLog, xcodebuildExportArchiveLogPath, xcodebuildExportArchiveLogPathEnvKey); err != nil {
-			s.logger.Warnf("Failed to export %s, error: %s", xcodebuildExportArchiveLogPathEnvKey, err)
-		} else {
-			s.logger.Donef("The xcodebuild -exportArchive log path is now available in the Environment Variable: %s (value: %s)", xcodebuildExportArchiveLogPathEnvKey, xcodebuildExportArchiveLogPath)
+	installPagePath := filepath.Join(outputDir, artifactName+".install.html")
+	if err := cleanup(installPagePath); err != nil {
+		return err
+	}
+	envKeys = []string{exportMethodEnvKey(bitriseIPAInstallPagePthEnvKey, ipaExport.ExportMethod)}
+	if isMainExport {
+		envKeys = append([]string{bitriseIPAInstallPagePthEnvKey}, envKeys...)
+	}
+	for i, envKey := range envKeys {
+		if i == 0 {
+			if err := ExportOutputFileContent(s.outputExporter, installPage, installPagePath, envKey); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+		} else if err := s.outputExporter.ExportOutput(envKey, installPagePath); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", envKey, err)
 		}
+		s.logger.Donef("The install page path is now exported to %s: %s (value: %s)", s.outputExporter.Describe(), envKey, installPagePath)
 	}
+	artifacts.addArtifact(artifactKindOTAInstallPage, installPagePath, ipaExport.ExportMethod)
 
 	return nil
 }
 
-func (s XcodebuildArchiveConfigParser) createCodesignManager(config Config, project projectmanager.Project) (codesign.Manager, error) {
+// createCodesignManager creates a code signing manager for the given distribution method,
+// which reads the code signing requirements from the existing archive in export-only mode, otherwise from the project.
+func (s XcodebuildArchiveConfigParser) createCodesignManager(config Config, exportMethod string) (codesign.Manager, error) {
 	var authType codesign.AuthType
 	switch config.CodeSigningAuthSource {
 	case codeSignSourceAppleID:
@@ -740,7 +1949,7 @@ func (s XcodebuildArchiveConfigParser) createCodesignManager(config Config, proj
 
 	codesignInputs := codesign.Input{
 		AuthType:                     authType,
-		DistributionMethod:           config.ExportMethod,
+		DistributionMethod:           legacyExportMethod(exportMethod),
 		CertificateURLList:           config.CertificateURLList,
 		CertificatePassphraseList:    config.CertificatePassphraseList,
 		KeychainPath:                 config.KeychainPath,
@@ -755,21 +1964,12 @@ func (s XcodebuildArchiveConfigParser) createCodesignManager(config Config, proj
 
 	devPortalClientFactory := devportalclient.NewFactory(s.logger, s.fileManager)
 
-	var serviceConnection *devportalservice.AppleDeveloperConnection = nil
-	if config.BuildURL != "" && config.BuildAPIToken != "" {
-		if serviceConnection, err = devPortalClientFactory.CreateBitriseConnection(config.BuildURL, string(config.BuildAPIToken)); err != nil {
-			return codesign.Manager{}, err
-		}
-	}
-
-	connectionInputs := codesign.ConnectionOverrideInputs{
-		APIKeyPath:              config.Inputs.APIKeyPath,
-		APIKeyID:                config.Inputs.APIKeyID,
-		APIKeyIssuerID:          config.Inputs.APIKeyIssuerID,
-		APIKeyEnterpriseAccount: config.Inputs.APIKeyEnterpriseAccount,
+	serviceConnection, err := s.bitriseConnection(config, devPortalClientFactory)
+	if err != nil {
+		return codesign.Manager{}, err
 	}
 
-	appleAuthCredentials, err := codesign.SelectConnectionCredentials(authType, serviceConnection, connectionInputs, s.logger)
+	appleAuthCredentials, err := codesign.SelectConnectionCredentials(authType, serviceConnection, connectionOverrideInputs(config), s.logger)
 	if err != nil {
 		return codesign.Manager{}, err
 	}
@@ -796,6 +1996,22 @@ func (s XcodebuildArchiveConfigParser) createCodesignManager(config Config, proj
 		testDevices = serviceConnection.TestDevices
 	}
 
+	if config.Archive != nil {
+		return codesign.NewManagerWithArchive(
+			opts,
+			appleAuthCredentials,
+			testDevices,
+			devPortalClientFactory,
+			certdownloader.NewDownloader(codesignConfig.CertificatesAndPassphrases, s.logger),
+			profiledownloader.New(codesignConfig.FallbackProvisioningProfiles, s.logger),
+			codesignasset.NewWriter(s.logger, codesignConfig.Keychain, s.fileManager, int64(config.XcodeMajorVersion)),
+			localcodesignasset.NewManager(localcodesignasset.NewProvisioningProfileProvider(), localcodesignasset.NewProvisioningProfileConverter()),
+			localcodesignasset.NewProvisioningProfileConverter(),
+			*config.Archive,
+			s.logger,
+		), nil
+	}
+
 	return codesign.NewManagerWithProject(
 		opts,
 		appleAuthCredentials,
@@ -806,11 +2022,46 @@ func (s XcodebuildArchiveConfigParser) createCodesignManager(config Config, proj
 		codesignasset.NewWriter(s.logger, codesignConfig.Keychain, s.fileManager, int64(config.XcodeMajorVersion)),
 		localcodesignasset.NewManager(localcodesignasset.NewProvisioningProfileProvider(), localcodesignasset.NewProvisioningProfileConverter()),
 		localcodesignasset.NewProvisioningProfileConverter(),
-		project,
+		config.ProjectManager,
 		s.logger,
 	), nil
 }
 
+// bitriseConnection returns the Bitrise Apple Service connection, nil if the build URL or the build API token is not available.
+func (s XcodebuildArchiveConfigParser) bitriseConnection(config Config, devPortalClientFactory devportalclient.Factory) (*devportalservice.AppleDeveloperConnection, error) {
+	if config.BuildURL == "" || config.BuildAPIToken == "" {
+		return nil, nil
+	}
+	return devPortalClientFactory.CreateBitriseConnection(config.BuildURL, string(config.BuildAPIToken))
+}
+
+func connectionOverrideInputs(config Config) codesign.ConnectionOverrideInputs {
+	return codesign.ConnectionOverrideInputs{
+		APIKeyPath:              config.Inputs.APIKeyPath,
+		APIKeyID:                config.Inputs.APIKeyID,
+		APIKeyIssuerID:          config.Inputs.APIKeyIssuerID,
+		APIKeyEnterpriseAccount: config.Inputs.APIKeyEnterpriseAccount,
+	}
+}
+
+// selectUploadAPIKey returns the App Store Connect API key used for uploading the export:
+// the connection override inputs or the Bitrise Apple Service connection, the same way as automatic code signing selects it.
+func (s XcodebuildArchiveConfigParser) selectUploadAPIKey(config Config) (*devportalservice.APIKeyConnection, error) {
+	serviceConnection, err := s.bitriseConnection(config, devportalclient.NewFactory(s.logger, s.fileManager))
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := codesign.SelectConnectionCredentials(codesign.APIKeyAuth, serviceConnection, connectionOverrideInputs(config), s.logger)
+	if err != nil {
+		return nil, err
+	}
+	if credentials.APIKey == nil {
+		return nil, fmt.Errorf("no App Store Connect API key available")
+	}
+	return credentials.APIKey, nil
+}
+
 type xcodeArchiveOpts struct {
 	ProjectManager      projectmanager.Project
 	ProjectPath         string
@@ -824,13 +2075,59 @@ type xcodeArchiveOpts struct {
 	PerformCleanAction bool
 	XcconfigContent    string
 	AdditionalOptions  []string
+	DerivedDataPath    string
+	RetryRules         []RetryRule
+	BuildTimingSummary bool
 }
 
 type xcodeArchiveResult struct {
 	Archive              *xcarchive.IosArchive
+	MacosArchive         *xcarchive.MacosArchive
+	XcresultPath         string
+	FiredRetryRules      []string
 	XcodebuildArchiveLog string
 }
 
+// newArchiveCommand creates the archive command, which writes the archive and the result bundle into outputDir.
+// It also returns the custom options of the command and the result bundle path (empty if the result bundle is not created).
+func (s XcodebuildArchiver) newArchiveCommand(opts xcodeArchiveOpts, outputDir, xcconfigPath string) (*xcodebuild.CommandBuilder, []string, string) {
+	var actions []string
+	if opts.PerformCleanAction {
+		actions = []string{"clean", "archive"}
+	} else {
+		actions = []string{"archive"}
+	}
+
+	archiveCmd := xcodebuild.NewCommandBuilder(opts.ProjectPath, actions...)
+	archiveCmd.SetScheme(opts.Scheme)
+	archiveCmd.SetConfiguration(opts.Configuration)
+
+	if xcconfigPath != "" {
+		archiveCmd.SetXCConfigPath(xcconfigPath)
+	}
+
+	archiveCmd.SetArchivePath(filepath.Join(outputDir, opts.ArtifactName+".xcarchive"))
+	if opts.XcodeAuthOptions != nil {
+		archiveCmd.SetAuthentication(*opts.XcodeAuthOptions)
+	}
+
+	var xcresultPth string
+	if slices.Contains(opts.AdditionalOptions, "-resultBundlePath") {
+		s.logger.Warnf("-resultBundlePath is set in the additional xcodebuild options, the result bundle will not be exported")
+	} else if opts.XcodeMajorVersion >= 11 {
+		xcresultPth = filepath.Join(outputDir, opts.ArtifactName+".xcresult")
+		archiveCmd.SetResultBundlePath(xcresultPth)
+	}
+
+	additionalOptions := generateAdditionalOptions(string(opts.DestinationPlatform), opts.AdditionalOptions)
+	if opts.BuildTimingSummary && !slices.Contains(additionalOptions, showBuildTimingSummaryFlag) {
+		additionalOptions = append(additionalOptions, showBuildTimingSummaryFlag)
+	}
+	archiveCmd.SetCustomOptions(additionalOptions)
+
+	return archiveCmd, additionalOptions, xcresultPth
+}
+
 func (s XcodebuildArchiver) xcodeArchive(opts xcodeArchiveOpts) (xcodeArchiveResult, error) {
 	out := xcodeArchiveResult{}
 	if opts.DestinationPlatform == detectPlatform {
@@ -855,50 +2152,64 @@ and use 'Export iOS and tvOS Xcode archive' step to export an App Clip.`, opts.S
 	s.logger.Println()
 	s.logger.TInfof("Creating the Archive ...")
 
-	var actions []string
-	if opts.PerformCleanAction {
-		actions = []string{"clean", "archive"}
-	} else {
-		actions = []string{"archive"}
-	}
-
-	archiveCmd := xcodebuild.NewCommandBuilder(opts.ProjectPath, actions...)
-	archiveCmd.SetScheme(opts.Scheme)
-	archiveCmd.SetConfiguration(opts.Configuration)
-
+	var xcconfigPath string
 	if opts.XcconfigContent != "" {
 		xcconfigWriter := xcconfig.NewWriter(s.pathProvider, s.fileManager, s.pathChecker, s.pathModifier)
-		xcconfigPath, err := xcconfigWriter.Write(opts.XcconfigContent)
-		if err != nil {
+		var err error
+		if xcconfigPath, err = xcconfigWriter.Write(opts.XcconfigContent); err != nil {
 			return out, fmt.Errorf("failed to write xcconfig file contents: %w", err)
 		}
-		archiveCmd.SetXCConfigPath(xcconfigPath)
 	}
 
 	tmpDir, err := v1pathutil.NormalizedOSTempDirPath("xcodeArchive")
 	if err != nil {
 		return out, fmt.Errorf("failed to create temp dir, error: %s", err)
 	}
-	archivePth := filepath.Join(tmpDir, opts.ArtifactName+".xcarchive")
-
-	archiveCmd.SetArchivePath(archivePth)
-	if opts.XcodeAuthOptions != nil {
-		archiveCmd.SetAuthentication(*opts.XcodeAuthOptions)
-	}
 
-	additionalOptions := generateAdditionalOptions(string(opts.DestinationPlatform), opts.AdditionalOptions)
-	archiveCmd.SetCustomOptions(additionalOptions)
+	archiveCmd, additionalOptions, xcresultPth := s.newArchiveCommand(opts, tmpDir, xcconfigPath)
+	archivePth := filepath.Join(tmpDir, opts.ArtifactName+".xcarchive")
 
 	var swiftPackagesPath string
 	if opts.XcodeMajorVersion >= 11 {
 		var err error
-		if swiftPackagesPath, err = cache.NewSwiftPackageCache().SwiftPackagesPath(opts.ProjectPath); err != nil {
+		if swiftPackagesPath, err = swiftPackagesDir(opts.ProjectPath, opts.DerivedDataPath); err != nil {
 			return out, fmt.Errorf("failed to get Swift Packages path, error: %s", err)
 		}
 	}
 
-	xcodebuildLog, err := runArchiveCommandWithRetry(s.xcodeCommandRunner, s.logFormatter, archiveCmd, swiftPackagesPath, s.logger)
+	remediate := func(rule RetryRule) error {
+		switch rule.Action {
+		case RetryActionDeletePath:
+			s.logger.Printf("Removing %s", rule.Path)
+			return os.RemoveAll(rule.Path)
+		case RetryActionResolvePackages:
+			resolveDepsCmd := xcodebuild.NewResolvePackagesCommandModel(opts.ProjectPath, opts.Scheme, opts.Configuration)
+			resolveDepsCmd.SetCustomOptions(opts.AdditionalOptions)
+			return resolveDepsCmd.Run()
+		case RetryActionClean:
+			cleanCmd := xcodebuild.NewCommandBuilder(opts.ProjectPath, "clean")
+			cleanCmd.SetScheme(opts.Scheme)
+			cleanCmd.SetConfiguration(opts.Configuration)
+			cleanCmd.SetCustomOptions(additionalOptions)
+			_, err := s.xcodeCommandRunner.Run("", cleanCmd.CommandArgs(), []string{})
+			return err
+		default:
+			return fmt.Errorf("unknown retry action: %s", rule.Action)
+		}
+	}
+
+	retryRules := expandRetryRulePaths(opts.RetryRules, swiftPackagesPath)
+	xcodebuildLog, firedRetryRules, err := runArchiveCommandWithRetry(s.xcodeCommandRunner, s.logFormatter, archiveCmd, retryRules, remediate, xcresultPth, s.logger)
 	out.XcodebuildArchiveLog = xcodebuildLog
+	out.FiredRetryRules = firedRetryRules
+	// The result bundle is also created if the archive fails
+	if xcresultPth != "" {
+		if exist, err := s.pathChecker.IsDirExists(xcresultPth); err != nil {
+			s.logger.Warnf("Failed to check if result bundle exist, error: %s", err)
+		} else if exist {
+			out.XcresultPath = xcresultPth
+		}
+	}
 	if err != nil {
 		return out, fmt.Errorf("failed to archive the project: %w", err)
 	}
@@ -910,20 +2221,18 @@ and use 'Export iOS and tvOS Xcode archive' step to export an App Clip.`, opts.S
 		return out, fmt.Errorf("no archive generated at: %s", archivePth)
 	}
 
-	archive, err := xcarchive.NewIosArchive(archivePth)
+	archive, macosArchive, err := openArchive(archivePth, s.pathChecker, s.logger)
 	if err != nil {
-		return out, fmt.Errorf("failed to parse archive, error: %s", err)
+		return out, err
 	}
-	out.Archive = &archive
+	out.Archive = archive
+	out.MacosArchive = macosArchive
 
-	mainApplication := archive.Application
-
-	s.logger.Println()
-	s.logger.Infof("Archive info:")
-	s.logger.Printf("team: %s (%s)", mainApplication.ProvisioningProfile.TeamName, mainApplication.ProvisioningProfile.TeamID)
-	s.logger.Printf("profile: %s (%s)", mainApplication.ProvisioningProfile.Name, mainApplication.ProvisioningProfile.UUID)
-	s.logger.Printf("export: %s", mainApplication.ProvisioningProfile.ExportType)
-	s.logger.Printf("xcode managed profile: %v", profileutil.IsXcodeManaged(mainApplication.ProvisioningProfile.Name))
+	if macosArchive != nil {
+		printMacosArchiveInfo(*macosArchive, s.logger)
+	} else {
+		printIosArchiveInfo(*archive, s.logger)
+	}
 
 	return out, nil
 }
@@ -934,12 +2243,19 @@ type xcodeIPAExportOpts struct {
 
 	Archive                         xcarchive.IosArchive
 	CustomExportOptionsPlistContent string
+	ExportOptionsMode               string
 	ExportMethod                    string
 	TestFlightInternalTestingOnly   bool
 	ICloudContainerEnvironment      string
 	ExportDevelopmentTeam           string
 	UploadBitcode                   bool
 	CompileBitcode                  bool
+	OTA                             OTAConfig // with the artifact name expanded
+	ProfileOverrides                []ProfileOverride
+	ExportSigningCertificate        string
+	Thinning                        string
+	Upload                          bool                             // uploads the export to App Store Connect
+	UploadAuthOptions               *xcodebuild.AuthenticationParams // authenticates the upload, if XcodeAuthOptions is not set
 }
 
 type xcodeIPAExportResult struct {
@@ -952,24 +2268,6 @@ type xcodeIPAExportResult struct {
 func (s XcodebuildArchiver) xcodeIPAExport(opts xcodeIPAExportOpts) (xcodeIPAExportResult, error) {
 	out := xcodeIPAExportResult{}
 
-	// Exporting the ipa with Xcode Command Line tools
-
-	/*
-		You'll get an "Error Domain=IDEDistributionErrorDomain Code=14 "No applicable devices found."" error
-		if $GEM_HOME is set and the project's directory includes a Gemfile - to fix this
-		we'll unset GEM_HOME as that's not required for xcodebuild anyway.
-		This probably fixes the RVM issue too, but that still should be tested.
-		See also:
-		- http://stackoverflow.com/questions/33041109/xcodebuild-no-applicable-devices-found-when-exporting-archive
-		- https://gist.github.com/claybridges/cea5d4afd24eda268164
-	*/
-	envsToUnset := []string{"GEM_HOME", "GEM_PATH", "RUBYLIB", "RUBYOPT", "BUNDLE_BIN_PATH", "_ORIGINAL_GEM_PATH", "BUNDLE_GEMFILE"}
-	for _, key := range envsToUnset {
-		if err := os.Unsetenv(key); err != nil {
-			return out, fmt.Errorf("failed to unset (%s), error: %s", key, err)
-		}
-	}
-
 	s.logger.Println()
 	s.logger.Infof("Collecting export options...")
 
@@ -980,74 +2278,159 @@ func (s XcodebuildArchiver) xcodeIPAExport(opts xcodeIPAExportOpts) (xcodeIPAExp
 
 	exportOptionsPath := filepath.Join(tmpDir, "export_options.plist")
 
-	if opts.CustomExportOptionsPlistContent != "" {
-		s.logger.Printf("Custom export options content provided, using it:")
-		s.logger.Printf(opts.CustomExportOptionsPlistContent)
+	generate := func() (exportoptions.ExportOptions, error) {
+		return s.generateIosExportOptions(opts)
+	}
+
+	if err := s.writeExportOptions(exportOptionsPath, opts.CustomExportOptionsPlistContent, opts.ExportOptionsMode, generate); err != nil {
+		return out, err
+	}
+
+	if err := s.preflightExportOptions(exportOptionsPath, opts.Archive); err != nil {
+		return out, err
+	}
+
+	ipaExportDir := filepath.Join(tmpDir, "exported")
 
-		if err := v1fileutil.WriteStringToFile(exportOptionsPath, opts.CustomExportOptionsPlistContent); err != nil {
-			return out, fmt.Errorf("failed to write export options to file, error: %s", err)
+	authOptions := opts.XcodeAuthOptions
+	s.logger.Println()
+	if opts.Upload {
+		s.logger.Infof("Exporting IPA from the archive and uploading it to App Store Connect...")
+		if authOptions == nil {
+			authOptions = opts.UploadAuthOptions
 		}
 	} else {
-		s.logger.Printf("No custom export options content provided, generating export options...")
+		s.logger.Infof("Exporting IPA from the archive...")
+	}
+	exportOut, err := s.exportArchive(opts.Archive.Path, exportOptionsPath, ipaExportDir, authOptions)
+	if err != nil {
+		return exportOut, fmt.Errorf("failed to export IPA: %w", err)
+	}
 
-		archiveExportMethod := opts.Archive.Application.ProvisioningProfile.ExportType
+	return exportOut, nil
+}
 
-		exportMethod, err := determineExportMethod(opts.ExportMethod, archiveExportMethod, s.logger)
-		if err != nil {
-			return out, err
-		}
+// generateIosExportOptions generates the export options of the archive, based on the installed code signing assets.
+func (s XcodebuildArchiver) generateIosExportOptions(opts xcodeIPAExportOpts) (exportoptions.ExportOptions, error) {
+	archiveExportMethod := opts.Archive.Application.ProvisioningProfile.ExportType
 
-		archiveCodeSignIsXcodeManaged := opts.Archive.IsXcodeManaged()
-		signingStyle := exportoptions.SigningStyleManual
-		if opts.XcodeAuthOptions != nil {
-			signingStyle = exportoptions.SigningStyleAutomatic
-		}
+	exportMethod, err := determineExportMethod(opts.ExportMethod, archiveExportMethod, s.logger)
+	if err != nil {
+		return nil, err
+	}
+	if exportMethod == exportoptions.MethodDeveloperID {
+		return nil, fmt.Errorf("distribution method %s is only available for macOS archives", exportMethod)
+	}
 
-		archiveInfo, err := exportoptionsgenerator.ReadArchiveExportInfo(opts.Archive)
-		if err != nil {
-			return out, fmt.Errorf("failed to read xcarchive: %s", err)
-		}
-
-		generator := exportoptionsgenerator.New(s.xcodeVersionReader, s.logger)
-		exportOptions, err := generator.GenerateApplicationExportOptions(exportoptionsgenerator.ExportProductApp, archiveInfo, exportMethod, signingStyle, exportoptionsgenerator.Opts{
-			ContainerEnvironment:             opts.ICloudContainerEnvironment,
-			TeamID:                           opts.ExportDevelopmentTeam,
-			UploadBitcode:                    opts.UploadBitcode,
-			CompileBitcode:                   opts.CompileBitcode,
-			ArchivedWithXcodeManagedProfiles: archiveCodeSignIsXcodeManaged,
-			TestFlightInternalTestingOnly:    opts.TestFlightInternalTestingOnly,
-			ManageVersionAndBuildNumber:      false,
-		})
-		if err != nil {
-			return out, fmt.Errorf("failed to generate xcode export options: %s", err)
-		}
+	archiveCodeSignIsXcodeManaged := opts.Archive.IsXcodeManaged()
+	signingStyle := exportoptions.SigningStyleManual
+	if opts.XcodeAuthOptions != nil {
+		signingStyle = exportoptions.SigningStyleAutomatic
+	}
 
-		s.logger.Println()
-		s.logger.Printf("generated export options content:")
-		s.logger.Println()
-		exportOptionsContent, err := exportOptions.String()
-		if err != nil {
-			return out, err
+	archiveInfo, err := exportoptionsgenerator.ReadArchiveExportInfo(opts.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read xcarchive: %s", err)
+	}
+
+	generator := exportoptionsgenerator.New(s.xcodeVersionReader, s.logger)
+	exportOptions, err := generator.GenerateApplicationExportOptions(exportoptionsgenerator.ExportProductApp, archiveInfo, exportMethod, signingStyle, exportoptionsgenerator.Opts{
+		ContainerEnvironment:             opts.ICloudContainerEnvironment,
+		TeamID:                           opts.ExportDevelopmentTeam,
+		UploadBitcode:                    opts.UploadBitcode,
+		CompileBitcode:                   opts.CompileBitcode,
+		ArchivedWithXcodeManagedProfiles: archiveCodeSignIsXcodeManaged,
+		TestFlightInternalTestingOnly:    opts.TestFlightInternalTestingOnly,
+		// The versions are set on the archived targets (see AppVersion), the export must not change them
+		ManageVersionAndBuildNumber: false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate xcode export options: %s", err)
+	}
+
+	if len(opts.ProfileOverrides) > 0 || opts.ExportSigningCertificate != "" {
+		if exportOptions, err = s.applyExportSigningOverrides(exportOptions, opts); err != nil {
+			return nil, err
 		}
-		s.logger.Printf("%s", exportOptionsContent)
+	}
 
-		if err := exportOptions.WriteToFile(exportOptionsPath); err != nil {
-			return out, err
+	if opts.Thinning != "" {
+		exportOptions, _ = addThinning(exportOptions, opts.Thinning)
+	}
+
+	if opts.Upload {
+		exportOptions, _ = addUploadDestination(exportOptions)
+	}
+
+	if !opts.OTA.IsEmpty() {
+		var added bool
+		if exportOptions, added = addOTAManifest(exportOptions, opts.OTA.manifest()); added {
+			s.logger.Printf("Over-the-air installation manifest added to the export options")
 		}
 	}
 
-	ipaExportDir := filepath.Join(tmpDir, "exported")
+	return exportOptions, nil
+}
+
+// applyExportSigningOverrides applies the provisioning profile and signing certificate overrides on the generated export options,
+// and logs the final selection.
+func (s XcodebuildArchiver) applyExportSigningOverrides(exportOptions exportoptions.ExportOptions, opts xcodeIPAExportOpts) (exportoptions.ExportOptions, error) {
+	profiles, err := profileutil.InstalledProvisioningProfileInfos(profileutil.ProfileTypeIos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list installed provisioning profiles: %w", err)
+	}
+
+	bundleIDProfileMap := opts.Archive.BundleIDProfileInfoMap()
+	var archivedBundleIDs []string
+	for bundleID, profile := range bundleIDProfileMap {
+		archivedBundleIDs = append(archivedBundleIDs, bundleID)
+		profiles = append(profiles, profile)
+	}
+
+	exportOptions, selection, err := applySigningOverrides(exportOptions, opts.ProfileOverrides, opts.ExportSigningCertificate, archivedBundleIDs, profiles)
+	if err != nil {
+		return nil, err
+	}
+	printSigningSelection(s.logger, selection)
+
+	return exportOptions, nil
+}
 
+func newExportCommand(archivePath, exportOptionsPath, exportDir string, authOptions *xcodebuild.AuthenticationParams) *xcodebuild.ExportCommandModel {
 	exportCmd := xcodebuild.NewExportCommand()
-	exportCmd.SetArchivePath(opts.Archive.Path)
-	exportCmd.SetExportDir(ipaExportDir)
+	exportCmd.SetArchivePath(archivePath)
+	exportCmd.SetExportDir(exportDir)
 	exportCmd.SetExportOptionsPlist(exportOptionsPath)
-	if opts.XcodeAuthOptions != nil {
-		exportCmd.SetAuthentication(*opts.XcodeAuthOptions)
+	if authOptions != nil {
+		exportCmd.SetAuthentication(*authOptions)
 	}
+	return exportCmd
+}
+
+// exportArchive runs `xcodebuild -exportArchive` and, on failure, locates the xcdistributionlogs to help debugging.
+func (s XcodebuildArchiver) exportArchive(archivePath, exportOptionsPath, exportDir string, authOptions *xcodebuild.AuthenticationParams) (xcodeIPAExportResult, error) {
+	out := xcodeIPAExportResult{}
+
+	// Exporting the archive with Xcode Command Line tools
+
+	/*
+		You'll get an "Error Domain=IDEDistributionErrorDomain Code=14 "No applicable devices found."" error
+		if $GEM_HOME is set and the project's directory includes a Gemfile - to fix this
+		we'll unset GEM_HOME as that's not required for xcodebuild anyway.
+		This probably fixes the RVM issue too, but that still should be tested.
+		See also:
+		- http://stackoverflow.com/questions/33041109/xcodebuild-no-applicable-devices-found-when-exporting-archive
+		- https://gist.github.com/claybridges/cea5d4afd24eda268164
+	*/
+	envsToUnset := []string{"GEM_HOME", "GEM_PATH", "RUBYLIB", "RUBYOPT", "BUNDLE_BIN_PATH", "_ORIGINAL_GEM_PATH", "BUNDLE_GEMFILE"}
+	for _, key := range envsToUnset {
+		if err := os.Unsetenv(key); err != nil {
+			return out, fmt.Errorf("failed to unset (%s), error: %s", key, err)
+		}
+	}
+
+	exportCmd := newExportCommand(archivePath, exportOptionsPath, exportDir, authOptions)
 
-	s.logger.Println()
-	s.logger.Infof("Exporting IPA from the archive...")
 	exportArchiveLog, exportErr := runIPAExportCommand(s.xcodeCommandRunner, s.logFormatter, exportCmd, s.logger)
 	out.XcodebuildExportArchiveLog = exportArchiveLog
 	if exportErr != nil {
@@ -1083,11 +2466,11 @@ is available in the $BITRISE_IDEDISTRIBUTION_LOGS_PATH environment variable`)
 			}
 		}
 
-		return out, fmt.Errorf("failed to export IPA: %w", exportErr)
+		return out, exportErr
 	}
 
 	out.ExportOptionsPath = exportOptionsPath
-	out.IPAExportDir = ipaExportDir
+	out.IPAExportDir = exportDir
 
 	return out, nil
 }
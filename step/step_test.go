@@ -1,7 +1,11 @@
 package step
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"testing"
@@ -9,12 +13,16 @@ import (
 	"github.com/bitrise-io/go-steputils/v2/stepconf"
 	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/v2/pathutil"
 	"github.com/bitrise-io/go-xcode/models"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 )
 
 func TestXcodeArchiveStep_ProcessInputs(t *testing.T) {
+	macosProjectPath := filepath.Join(t.TempDir(), "macos-sample.xcodeproj")
+	require.NoError(t, os.MkdirAll(macosProjectPath, 0755))
+
 	tests := []struct {
 		name string
 		envs map[string]string
@@ -31,6 +39,48 @@ func TestXcodeArchiveStep_ProcessInputs(t *testing.T) {
 			want: Config{},
 			err:  "issue with input ProjectPath: should be and .xcodeproj or .xcworkspace path",
 		},
+		{
+			name: "scheme is required if archive_path is not set",
+			envs: override(thisStepInputs(t), map[string]string{
+				"project_path": "../_tmp/ios-sample.xcodeproj",
+				"scheme":       "",
+				"archive_path": "",
+			}),
+			want: Config{},
+			err:  "issue with input Scheme: required variable is not present",
+		},
+		{
+			name: "archive_path should be an .xcarchive path",
+			envs: override(thisStepInputs(t), map[string]string{
+				"project_path": "",
+				"scheme":       "",
+				"archive_path": "../_tmp/ios-sample.ipa",
+			}),
+			want: Config{},
+			err:  "issue with input ArchivePath: should be an .xcarchive path",
+		},
+		{
+			name: "skip_export can not be used with archive_path",
+			envs: override(thisStepInputs(t), map[string]string{
+				"project_path": "",
+				"scheme":       "",
+				"archive_path": "../_tmp/ios-sample.xcarchive",
+				"skip_export":  "yes",
+			}),
+			want: Config{},
+			err:  "issue with input SkipExport: can not be used together with ArchivePath, as nothing would be done",
+		},
+		{
+			name: "automatic code signing is not supported for macOS projects",
+			envs: override(thisStepInputs(t), map[string]string{
+				"project_path":           macosProjectPath,
+				"scheme":                 "My Scheme",
+				"platform":               "macOS",
+				"automatic_code_signing": "api-key",
+			}),
+			want: Config{},
+			err:  "issue with input CodeSigningAuthSource: automatic code signing is not supported for macOS, install the certificates and profiles before this Step and set Automatic code signing method to off",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -44,11 +94,58 @@ func TestXcodeArchiveStep_ProcessInputs(t *testing.T) {
 			gotErr := err != nil
 			wantErr := tt.err != ""
 			require.Equal(t, wantErr, gotErr, fmt.Sprintf("Step.ValidateConfig() error = %v, wantErr %v", err, tt.err))
+			if wantErr {
+				require.EqualError(t, err, tt.err)
+			}
 			require.Equal(t, tt.want, config)
 		})
 	}
 }
 
+func TestXcodebuildArchiver_xcodeArchive_ExportsXcresultOfFailedArchive(t *testing.T) {
+	outputsPath := filepath.Join(t.TempDir(), "outputs.json")
+	archiver := XcodebuildArchiver{
+		xcodeCommandRunner: &fakeXcodeCommandRunner{
+			outputs: []string{"error: Build input file cannot be found\n** ARCHIVE FAILED **"},
+			errs:    []error{errors.New("exit status 65")},
+		},
+		logFormatter:   XcodebuildTool,
+		pathChecker:    pathutil.NewPathChecker(),
+		logger:         log.NewLogger(),
+		outputExporter: NewJSONOutputExporter(outputsPath),
+	}
+
+	out, err := archiver.xcodeArchive(xcodeArchiveOpts{
+		ProjectPath:         "App.xcodeproj",
+		Scheme:              "App",
+		DestinationPlatform: iOS,
+		Configuration:       "Release",
+		XcodeMajorVersion:   15,
+		ArtifactName:        "App",
+		DerivedDataPath:     t.TempDir(),
+	})
+	require.Error(t, err)
+	// The result bundle is created even if the archive fails, and is exported with the failure
+	require.Equal(t, "App.xcresult", filepath.Base(out.XcresultPath))
+
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("rsync is required to export the zipped result bundle")
+	}
+	outputDir := t.TempDir()
+	var manifest ArtifactManifest
+	require.NoError(t, archiver.exportXcresult(out.XcresultPath, outputDir, "App", &manifest))
+
+	content, err := os.ReadFile(outputsPath)
+	require.NoError(t, err)
+	var outputs map[string]string
+	require.NoError(t, json.Unmarshal(content, &outputs))
+	require.Equal(t, map[string]string{
+		bitriseXcresultPthEnvKey:    out.XcresultPath,
+		bitriseXcresultZipPthEnvKey: filepath.Join(outputDir, "App.xcresult.zip"),
+	}, outputs)
+	require.FileExists(t, filepath.Join(outputDir, "App.xcresult.zip"))
+}
+
 type MockXcodeVersionProvider struct {
 	version models.XcodebuildVersionModel
 }
@@ -0,0 +1,303 @@
+package step
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"howett.net/plist"
+)
+
+const (
+	thinningInputNone        = "none"
+	thinningInputAllVariants = "all"
+
+	// The thinning export option values, besides a device model identifier (for example iPhone15,2)
+	thinningNone        = "<none>"
+	thinningAllVariants = "<thin-for-all-variants>"
+
+	thinningSizeReportFilename = "App Thinning Size Report.txt"
+
+	// thinningSizeBudgetAnyDevice is the budget device matching every variant.
+	thinningSizeBudgetAnyDevice = "*"
+)
+
+var (
+	deviceModelPattern       = regexp.MustCompile(`^[A-Za-z]+\d+,\d+$`)
+	variantDescriptorPattern = regexp.MustCompile(`\[device: (.+?), os-version: (.+?)\]`)
+	reportSizesPattern       = regexp.MustCompile(`^(.+) compressed, (.+) uncompressed$`)
+)
+
+// parseThinning returns the thinning export option value of the input: none, all or a device model identifier.
+func parseThinning(thinning string) (string, error) {
+	switch thinning {
+	case thinningInputNone, "":
+		return thinningNone, nil
+	case thinningInputAllVariants:
+		return thinningAllVariants, nil
+	}
+	if !deviceModelPattern.MatchString(thinning) {
+		return "", fmt.Errorf("should be %s, %s or a device model identifier (for example iPhone15,2), got: %s", thinningInputNone, thinningInputAllVariants, thinning)
+	}
+	return thinning, nil
+}
+
+// addThinning sets thinning on the non-App Store export options, App Store export options are returned unchanged.
+func addThinning(exportOpts exportoptions.ExportOptions, thinning string) (exportoptions.ExportOptions, bool) {
+	options, ok := exportOpts.(exportoptions.NonAppStoreOptionsModel)
+	if !ok || thinning == thinningNone {
+		return exportOpts, false
+	}
+	options.Thinning = thinning
+	return options, true
+}
+
+// ThinningVariantDescriptor is a device and OS version supported by a thinned variant.
+type ThinningVariantDescriptor struct {
+	Device    string `json:"device"`
+	OSVersion string `json:"os_version"`
+}
+
+// ThinningVariant is a thinned variant of the app, the sizes are in bytes.
+type ThinningVariant struct {
+	Variant                           string                      `json:"variant"`
+	SupportedVariantDescriptors       []ThinningVariantDescriptor `json:"supported_variant_descriptors"`
+	CompressedSize                    int64                       `json:"compressed_size"`
+	UncompressedSize                  int64                       `json:"uncompressed_size"`
+	OnDemandResourcesCompressedSize   int64                       `json:"on_demand_resources_compressed_size"`
+	OnDemandResourcesUncompressedSize int64                       `json:"on_demand_resources_uncompressed_size"`
+	// IPAPath is the exported path of the variant, set when exporting the outputs.
+	IPAPath string `json:"ipa_path,omitempty"`
+}
+
+// ThinningReport is the parsed App Thinning Size Report.txt of an export.
+type ThinningReport struct {
+	ReportPath string            `json:"-"`
+	Variants   []ThinningVariant `json:"variants"`
+}
+
+// readThinningReport parses the App Thinning Size Report.txt written by xcodebuild into the export dir.
+// Returns nil if the export options don't thin the app, and an error if they do, but the export dir doesn't contain a report.
+func readThinningReport(exportOptionsPath, exportDir string) (*ThinningReport, error) {
+	content, err := os.ReadFile(exportOptionsPath)
+	if err != nil {
+		return nil, err
+	}
+	var exportOptions map[string]interface{}
+	if _, err := plist.Unmarshal(content, &exportOptions); err != nil {
+		return nil, fmt.Errorf("failed to parse the export options: %w", err)
+	}
+	if thinning, _ := exportOptions["thinning"].(string); thinning == "" || thinning == thinningNone {
+		return nil, nil
+	}
+
+	reportPath := filepath.Join(exportDir, thinningSizeReportFilename)
+	content, err = os.ReadFile(reportPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("the export options thin the app, but xcodebuild did not write %s", thinningSizeReportFilename)
+	} else if err != nil {
+		return nil, err
+	}
+
+	variants, err := parseThinningReport(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", thinningSizeReportFilename, err)
+	}
+	return &ThinningReport{ReportPath: reportPath, Variants: variants}, nil
+}
+
+func parseThinningReport(content string) ([]ThinningVariant, error) {
+	var variants []ThinningVariant
+	var variant *ThinningVariant
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), ": ")
+		if !found {
+			continue
+		}
+
+		if key == "Variant" {
+			variants = append(variants, ThinningVariant{Variant: value, SupportedVariantDescriptors: []ThinningVariantDescriptor{}})
+			variant = &variants[len(variants)-1]
+			continue
+		}
+		if variant == nil {
+			continue
+		}
+
+		switch key {
+		case "Supported variant descriptors":
+			for _, match := range variantDescriptorPattern.FindAllStringSubmatch(value, -1) {
+				variant.SupportedVariantDescriptors = append(variant.SupportedVariantDescriptors, ThinningVariantDescriptor{Device: match[1], OSVersion: match[2]})
+			}
+		case "App size":
+			compressed, uncompressed, err := parseReportSizes(value)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %w", variant.Variant, err)
+			}
+			variant.CompressedSize, variant.UncompressedSize = compressed, uncompressed
+		case "On Demand Resources size":
+			compressed, uncompressed, err := parseReportSizes(value)
+			if err != nil {
+				return nil, fmt.Errorf("variant %s: %w", variant.Variant, err)
+			}
+			variant.OnDemandResourcesCompressedSize, variant.OnDemandResourcesUncompressedSize = compressed, uncompressed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no variants found")
+	}
+	return variants, nil
+}
+
+// parseReportSizes parses a size line of the report, for example: 6.7 MB compressed, 18.6 MB uncompressed
+func parseReportSizes(sizes string) (int64, int64, error) {
+	match := reportSizesPattern.FindStringSubmatch(sizes)
+	if match == nil {
+		return 0, 0, fmt.Errorf("invalid sizes: %s", sizes)
+	}
+	compressed, err := parseSize(match[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	uncompressed, err := parseSize(match[2])
+	if err != nil {
+		return 0, 0, err
+	}
+	return compressed, uncompressed, nil
+}
+
+// parseSize parses a size in the format of the report (decimal units), for example: 6.7 MB, Zero KB, 512 bytes
+func parseSize(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	units := []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"bytes", 1},
+		{"KB", 1e3},
+		{"MB", 1e6},
+		{"GB", 1e9},
+	}
+	for _, unit := range units {
+		value, found := strings.CutSuffix(size, unit.suffix)
+		if !found {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "Zero" {
+			return 0, nil
+		}
+		number, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("invalid size: %s", size)
+		}
+		return int64(math.Round(number * unit.multiplier)), nil
+	}
+	return 0, fmt.Errorf("invalid size (%s), should be in bytes, KB, MB or GB", size)
+}
+
+func formatSize(size int64) string {
+	return fmt.Sprintf("%.1f MB", float64(size)/1e6)
+}
+
+// ThinningSizeBudget is the maximum compressed app size of the variants supporting Device.
+type ThinningSizeBudget struct {
+	Device  string
+	MaxSize int64
+}
+
+// parseThinningSizeBudgets parses the budgets, one per line in the form of: <device model or *>|<max size>.
+func parseThinningSizeBudgets(list string) ([]ThinningSizeBudget, error) {
+	var budgets []ThinningSizeBudget
+	devices := map[string]bool{}
+	for _, line := range splitInputLines(list) {
+		device, size, found := strings.Cut(line, "|")
+		device = strings.TrimSpace(device)
+		if !found || device == "" {
+			return nil, fmt.Errorf("invalid size budget (%s), should be in the form of: <device model or %s>|<max size>", line, thinningSizeBudgetAnyDevice)
+		}
+		maxSize, err := parseSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size budget (%s): %w", line, err)
+		}
+		if devices[device] {
+			return nil, fmt.Errorf("duplicate size budget for device: %s", device)
+		}
+		devices[device] = true
+		budgets = append(budgets, ThinningSizeBudget{Device: device, MaxSize: maxSize})
+	}
+	return budgets, nil
+}
+
+// variantSizeBudget returns the budget of the variant: the smallest budget of its devices, or the * budget.
+func variantSizeBudget(variant ThinningVariant, budgets []ThinningSizeBudget) (ThinningSizeBudget, bool) {
+	var budget ThinningSizeBudget
+	var found bool
+	for _, b := range budgets {
+		for _, descriptor := range variant.SupportedVariantDescriptors {
+			if descriptor.Device == b.Device && (!found || b.MaxSize < budget.MaxSize) {
+				budget, found = b, true
+			}
+		}
+	}
+	if found {
+		return budget, true
+	}
+	for _, b := range budgets {
+		if b.Device == thinningSizeBudgetAnyDevice {
+			return b, true
+		}
+	}
+	return ThinningSizeBudget{}, false
+}
+
+// checkThinningSizeBudgets checks the compressed app size of every variant against its budget.
+// Every exceeded budget is returned in a single error.
+func checkThinningSizeBudgets(exportMethod string, report ThinningReport, budgets []ThinningSizeBudget) error {
+	var exceeded []string
+	for _, variant := range report.Variants {
+		budget, ok := variantSizeBudget(variant, budgets)
+		if !ok || variant.CompressedSize <= budget.MaxSize {
+			continue
+		}
+		exceeded = append(exceeded, fmt.Sprintf("- %s: compressed app size %s exceeds the %s budget of %s", variant.Variant, formatSize(variant.CompressedSize), budget.Device, formatSize(budget.MaxSize)))
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("app size budget exceeded (%s):\n%s", exportMethod, strings.Join(exceeded, "\n"))
+	}
+	return nil
+}
+
+// variantIPAPaths returns the paths of the variants' .ipa files in the export dir, by variant name.
+func variantIPAPaths(exportDir string, report ThinningReport) (map[string]string, error) {
+	names := map[string]bool{}
+	for _, variant := range report.Variants {
+		names[variant.Variant] = true
+	}
+
+	paths := map[string]string{}
+	if err := filepath.Walk(exportDir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && names[info.Name()] {
+			paths[info.Name()] = pth
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to search for the variant .ipa files: %w", err)
+	}
+	return paths, nil
+}
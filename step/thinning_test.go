@@ -0,0 +1,140 @@
+package step
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/stretchr/testify/require"
+	"howett.net/plist"
+)
+
+const sampleThinningReport = `
+App Thinning Size Report for All Variants of Sample
+
+Variant: Sample-0A1B2C3D.ipa
+Supported variant descriptors: [device: iPhone15,2, os-version: 16.0], [device: iPhone15,3, os-version: 16.0]
+App + On Demand Resources size: 6.7 MB compressed, 18.6 MB uncompressed
+App size: 6.7 MB compressed, 18.6 MB uncompressed
+On Demand Resources size: Zero KB compressed, Zero KB uncompressed
+
+
+Variant: Sample-4E5F6A7B.ipa
+Supported variant descriptors: [device: iPad13,1, os-version: 16.0]
+App + On Demand Resources size: 7.9 MB compressed, 21.2 MB uncompressed
+App size: 7.4 MB compressed, 20.1 MB uncompressed
+On Demand Resources size: 512 KB compressed, 1.1 MB uncompressed
+`
+
+func Test_parseThinning(t *testing.T) {
+	for input, want := range map[string]string{
+		"none":       thinningNone,
+		"all":        thinningAllVariants,
+		"iPhone15,2": "iPhone15,2",
+	} {
+		got, err := parseThinning(input)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+
+	_, err := parseThinning("iPhone 15")
+	require.EqualError(t, err, "should be none, all or a device model identifier (for example iPhone15,2), got: iPhone 15")
+}
+
+func Test_addThinning(t *testing.T) {
+	options, added := addThinning(exportoptions.NewNonAppStoreOptions(exportoptions.MethodAdHoc), thinningAllVariants)
+	require.True(t, added)
+	require.Equal(t, thinningAllVariants, options.(exportoptions.NonAppStoreOptionsModel).Thinning)
+
+	_, added = addThinning(exportoptions.NewAppStoreOptions(), thinningAllVariants)
+	require.False(t, added)
+	_, added = addThinning(exportoptions.NewNonAppStoreOptions(exportoptions.MethodAdHoc), thinningNone)
+	require.False(t, added)
+}
+
+func Test_parseThinningReport(t *testing.T) {
+	variants, err := parseThinningReport(sampleThinningReport)
+	require.NoError(t, err)
+	require.Equal(t, []ThinningVariant{
+		{
+			Variant: "Sample-0A1B2C3D.ipa",
+			SupportedVariantDescriptors: []ThinningVariantDescriptor{
+				{Device: "iPhone15,2", OSVersion: "16.0"},
+				{Device: "iPhone15,3", OSVersion: "16.0"},
+			},
+			CompressedSize:   6700000,
+			UncompressedSize: 18600000,
+		},
+		{
+			Variant:                           "Sample-4E5F6A7B.ipa",
+			SupportedVariantDescriptors:       []ThinningVariantDescriptor{{Device: "iPad13,1", OSVersion: "16.0"}},
+			CompressedSize:                    7400000,
+			UncompressedSize:                  20100000,
+			OnDemandResourcesCompressedSize:   512000,
+			OnDemandResourcesUncompressedSize: 1100000,
+		},
+	}, variants)
+
+	_, err = parseThinningReport("App Thinning Size Report for All Variants of Sample")
+	require.EqualError(t, err, "no variants found")
+}
+
+func Test_readThinningReport(t *testing.T) {
+	writeExportOptions := func(t *testing.T, thinning string) string {
+		options := map[string]interface{}{"method": "ad-hoc"}
+		if thinning != "" {
+			options["thinning"] = thinning
+		}
+		content, err := plist.Marshal(options, plist.XMLFormat)
+		require.NoError(t, err)
+		exportOptionsPath := filepath.Join(t.TempDir(), "export_options.plist")
+		require.NoError(t, os.WriteFile(exportOptionsPath, content, 0600))
+		return exportOptionsPath
+	}
+
+	exportDir := t.TempDir()
+	report, err := readThinningReport(writeExportOptions(t, ""), exportDir)
+	require.NoError(t, err)
+	require.Nil(t, report)
+
+	report, err = readThinningReport(writeExportOptions(t, thinningNone), exportDir)
+	require.NoError(t, err)
+	require.Nil(t, report)
+
+	_, err = readThinningReport(writeExportOptions(t, thinningAllVariants), exportDir)
+	require.EqualError(t, err, "the export options thin the app, but xcodebuild did not write App Thinning Size Report.txt")
+
+	require.NoError(t, os.WriteFile(filepath.Join(exportDir, thinningSizeReportFilename), []byte("App Thinning Size Report for All Variants of Sample"), 0600))
+	_, err = readThinningReport(writeExportOptions(t, thinningAllVariants), exportDir)
+	require.EqualError(t, err, "failed to parse App Thinning Size Report.txt: no variants found")
+
+	require.NoError(t, os.WriteFile(filepath.Join(exportDir, thinningSizeReportFilename), []byte(sampleThinningReport), 0600))
+	report, err = readThinningReport(writeExportOptions(t, thinningAllVariants), exportDir)
+	require.NoError(t, err)
+	require.Len(t, report.Variants, 2)
+}
+
+func Test_checkThinningSizeBudgets(t *testing.T) {
+	variants, err := parseThinningReport(sampleThinningReport)
+	require.NoError(t, err)
+	report := ThinningReport{Variants: variants}
+
+	budgets, err := parseThinningSizeBudgets("*|7 MB\niPhone15,3|6.5MB")
+	require.NoError(t, err)
+	require.Equal(t, []ThinningSizeBudget{{Device: "*", MaxSize: 7000000}, {Device: "iPhone15,3", MaxSize: 6500000}}, budgets)
+
+	err = checkThinningSizeBudgets("ad-hoc", report, budgets)
+	require.EqualError(t, err, `app size budget exceeded (ad-hoc):
+- Sample-0A1B2C3D.ipa: compressed app size 6.7 MB exceeds the iPhone15,3 budget of 6.5 MB
+- Sample-4E5F6A7B.ipa: compressed app size 7.4 MB exceeds the * budget of 7.0 MB`)
+
+	budgets, err = parseThinningSizeBudgets("iPad13,1|8 MB")
+	require.NoError(t, err)
+	require.NoError(t, checkThinningSizeBudgets("ad-hoc", report, budgets))
+
+	_, err = parseThinningSizeBudgets("iPad13,1 8 MB")
+	require.EqualError(t, err, "invalid size budget (iPad13,1 8 MB), should be in the form of: <device model or *>|<max size>")
+	_, err = parseThinningSizeBudgets("iPad13,1|8 TB")
+	require.EqualError(t, err, "invalid size budget (iPad13,1|8 TB): invalid size (8 TB), should be in bytes, KB, MB or GB")
+}
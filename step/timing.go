@@ -0,0 +1,131 @@
+package step
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	showBuildTimingSummaryFlag = "-showBuildTimingSummary"
+	buildTimingSummaryHeader   = "Build Timing Summary"
+
+	stageResolvePackages = "resolve packages"
+	stageCodeSigning     = "code signing preparation"
+	stageArchive         = "archive"
+	stageExport          = "export"
+	stageOutput          = "output"
+)
+
+// buildTimingLinePattern matches the lines of xcodebuild's build timing summary, for example:
+// CompileSwiftSources (4 tasks) | 25.123 seconds
+var buildTimingLinePattern = regexp.MustCompile(`^(.+?) \((\d+) tasks?\) \| ([\d.]+) seconds$`)
+
+// BuildPhaseTiming is a line of xcodebuild's build timing summary.
+type BuildPhaseTiming struct {
+	Phase   string  `json:"phase"`
+	Count   int     `json:"count"`
+	Seconds float64 `json:"seconds"`
+}
+
+// StageTiming is the duration of one of the Step's own stages.
+type StageTiming struct {
+	Stage   string  `json:"stage"`
+	Seconds float64 `json:"seconds"`
+}
+
+// TimingReport ...
+type TimingReport struct {
+	BuildPhases []BuildPhaseTiming `json:"build_phases"`
+	StepStages  []StageTiming      `json:"step_stages"`
+}
+
+func newStageTiming(stage string, start time.Time) StageTiming {
+	return StageTiming{
+		Stage:   stage,
+		Seconds: roundSeconds(time.Since(start).Seconds()),
+	}
+}
+
+func roundSeconds(seconds float64) float64 {
+	return float64(int64(seconds*1000+0.5)) / 1000
+}
+
+// parseBuildTimingSummary returns the build phase timings printed by xcodebuild after the
+// "Build Timing Summary" line, sorted by cost (the most expensive first).
+func parseBuildTimingSummary(xcodebuildLog string) []BuildPhaseTiming {
+	var timings []BuildPhaseTiming
+	isSummary := false
+
+	scanner := bufio.NewScanner(strings.NewReader(xcodebuildLog))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == buildTimingSummaryHeader {
+			// Only the last summary is kept, if xcodebuild printed multiple ones (for example clean and archive).
+			isSummary = true
+			timings = nil
+			continue
+		}
+		if !isSummary || line == "" {
+			continue
+		}
+
+		match := buildTimingLinePattern.FindStringSubmatch(line)
+		if match == nil {
+			isSummary = false
+			continue
+		}
+
+		count, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		seconds, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			continue
+		}
+
+		timings = append(timings, BuildPhaseTiming{
+			Phase:   match[1],
+			Count:   count,
+			Seconds: seconds,
+		})
+	}
+
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Seconds > timings[j].Seconds
+	})
+
+	return timings
+}
+
+// Markdown renders the report as Markdown tables.
+func (r TimingReport) Markdown() string {
+	var b strings.Builder
+
+	b.WriteString("# Build timing summary\n\n")
+	if len(r.BuildPhases) == 0 {
+		b.WriteString("No build timing summary found in the xcodebuild log.\n")
+	} else {
+		b.WriteString("| Phase | Count | Seconds |\n")
+		b.WriteString("| --- | ---: | ---: |\n")
+		for _, timing := range r.BuildPhases {
+			b.WriteString(fmt.Sprintf("| %s | %d | %.3f |\n", timing.Phase, timing.Count, timing.Seconds))
+		}
+	}
+
+	b.WriteString("\n# Step stages\n\n")
+	b.WriteString("| Stage | Seconds |\n")
+	b.WriteString("| --- | ---: |\n")
+	for _, timing := range r.StepStages {
+		b.WriteString(fmt.Sprintf("| %s | %.3f |\n", timing.Stage, timing.Seconds))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,52 @@
+package step
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseBuildTimingSummary(t *testing.T) {
+	log := `** ARCHIVE SUCCEEDED **
+
+Build Timing Summary
+
+Ld (2 tasks) | 1.234 seconds
+CompileSwiftSources (4 tasks) | 25.123 seconds
+PhaseScriptExecution (1 task) | 3.500 seconds
+CodeSign (3 tasks) | 0.456 seconds
+
+** ARCHIVE SUCCEEDED ** [31.012 sec]
+`
+	require.Equal(t, []BuildPhaseTiming{
+		{Phase: "CompileSwiftSources", Count: 4, Seconds: 25.123},
+		{Phase: "PhaseScriptExecution", Count: 1, Seconds: 3.5},
+		{Phase: "Ld", Count: 2, Seconds: 1.234},
+		{Phase: "CodeSign", Count: 3, Seconds: 0.456},
+	}, parseBuildTimingSummary(log))
+}
+
+func Test_parseBuildTimingSummary_NoSummary(t *testing.T) {
+	require.Empty(t, parseBuildTimingSummary("CompileSwiftSources (4 tasks) | 25.123 seconds\n** ARCHIVE SUCCEEDED **"))
+}
+
+func TestTimingReport_Markdown(t *testing.T) {
+	report := TimingReport{
+		BuildPhases: []BuildPhaseTiming{{Phase: "CompileSwiftSources", Count: 4, Seconds: 25.123}},
+		StepStages:  []StageTiming{{Stage: stageArchive, Seconds: 40.5}, {Stage: stageOutput, Seconds: 2}},
+	}
+
+	require.Equal(t, `# Build timing summary
+
+| Phase | Count | Seconds |
+| --- | ---: | ---: |
+| CompileSwiftSources | 4 | 25.123 |
+
+# Step stages
+
+| Stage | Seconds |
+| --- | ---: |
+| archive | 40.500 |
+| output | 2.000 |
+`, report.Markdown())
+}
@@ -0,0 +1,195 @@
+package step
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/devportalservice"
+)
+
+const (
+	exportDestinationUpload = "upload"
+
+	// destinationUpload is the export options destination uploading the export to App Store Connect instead of writing it into the export dir.
+	destinationUpload exportoptions.Destination = "upload"
+
+	uploadStatusSucceeded = "succeeded"
+	uploadStatusFailed    = "failed"
+
+	// App Store Connect lists the build only after it received the upload, which takes a while after xcodebuild finished
+	uploadedBuildLookupTimeout  = 2 * time.Minute
+	uploadedBuildLookupInterval = 15 * time.Second
+)
+
+var (
+	uploadSucceededPattern = regexp.MustCompile(`(?m)Upload succeeded\.?\s*$`)
+	uploadFailedPattern    = regexp.MustCompile(`(?m)(Upload failed|\*\* EXPORT FAILED \*\*)`)
+	uploadErrorPattern     = regexp.MustCompile(`(?m)^\s*error: (.+)$`)
+	deliveryUUIDPattern    = regexp.MustCompile(`(?i)delivery[ _]?uuid"?\s*[:=]\s*"?([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+)
+
+// parseAppStoreConnectAPIURL validates the App Store Connect API base URL, an empty URL keeps Apple's API.
+func parseAppStoreConnectAPIURL(apiURL string) (*url.URL, error) {
+	if apiURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("should be an http or https URL, got: %s", apiURL)
+	}
+	// The API endpoints are resolved relative to the base URL
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return u, nil
+}
+
+// addUploadDestination sets the upload destination on the App Store export options, other export options are returned unchanged.
+func addUploadDestination(exportOpts exportoptions.ExportOptions) (exportoptions.ExportOptions, bool) {
+	options, ok := exportOpts.(exportoptions.AppStoreOptionsModel)
+	if !ok {
+		return exportOpts, false
+	}
+	options.Destination = destinationUpload
+	return options, true
+}
+
+// UploadResult describes the upload of an export to App Store Connect.
+type UploadResult struct {
+	Status       string   `json:"status"`
+	DeliveryUUID string   `json:"delivery_uuid,omitempty"`
+	Errors       []string `json:"errors,omitempty"`
+
+	// The uploaded build on App Store Connect, empty if it is not available (yet)
+	BuildID         string `json:"build_id,omitempty"`
+	ProcessingState string `json:"processing_state,omitempty"`
+}
+
+// parseUploadResult parses the upload status, the delivery UUID and the upload errors from the xcodebuild -exportArchive log.
+func parseUploadResult(exportLog string, exportSucceeded bool) UploadResult {
+	result := UploadResult{Status: uploadStatusFailed}
+	if (exportSucceeded || uploadSucceededPattern.MatchString(exportLog)) && !uploadFailedPattern.MatchString(exportLog) {
+		result.Status = uploadStatusSucceeded
+	}
+
+	if match := deliveryUUIDPattern.FindStringSubmatch(exportLog); match != nil {
+		result.DeliveryUUID = strings.ToLower(match[1])
+	}
+
+	if result.Status == uploadStatusFailed {
+		for _, match := range uploadErrorPattern.FindAllStringSubmatch(exportLog, -1) {
+			result.Errors = append(result.Errors, strings.TrimSpace(match[1]))
+		}
+	}
+
+	return result
+}
+
+// newAppStoreConnectClient creates an App Store Connect API client authenticated with the API key.
+// The client uses the base URL if it is set, otherwise Apple's API.
+func newAppStoreConnectClient(apiKey devportalservice.APIKeyConnection, baseURL *url.URL, logger log.Logger) *appstoreconnect.Client {
+	// The upload lookup is not tracked, unlike the code signing API requests
+	tracker := appstoreconnect.NoOpAnalyticsTracker{}
+	client := appstoreconnect.NewClient(appstoreconnect.NewRetryableHTTPClient(logger, tracker), apiKey.KeyID, apiKey.IssuerID, []byte(apiKey.PrivateKey), apiKey.EnterpriseAccount, logger, tracker)
+	if baseURL != nil {
+		client.BaseURL = baseURL
+	}
+	return client
+}
+
+type appsResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			BundleID string `json:"bundleId"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+type buildsResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Version         string `json:"version"`
+			ProcessingState string `json:"processingState"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// waitForUploadedBuild looks up the app by its bundle ID, and polls the uploaded build until it is available
+// on App Store Connect, or the timeout elapses. Empty values are returned if the build is not available within the timeout.
+func waitForUploadedBuild(client *appstoreconnect.Client, bundleID, buildNumber string, timeout, interval time.Duration) (string, string, error) {
+	appID, err := fetchAppID(client, bundleID)
+	if err != nil {
+		return "", "", err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		buildID, processingState, err := fetchBuild(client, appID, buildNumber)
+		if err != nil || buildID != "" {
+			return buildID, processingState, err
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return "", "", nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// fetchAppID returns the App Store Connect ID of the app with the given bundle ID.
+func fetchAppID(client *appstoreconnect.Client, bundleID string) (string, error) {
+	req, err := client.NewRequest("GET", "apps?"+url.Values{
+		"filter[bundleId]": {bundleID},
+		"fields[apps]":     {"bundleId"},
+	}.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	var apps appsResponse
+	if _, err := client.Do(req, &apps); err != nil {
+		return "", fmt.Errorf("failed to fetch the app with bundle ID %s: %w", bundleID, err)
+	}
+
+	for _, app := range apps.Data {
+		if app.Attributes.BundleID == bundleID {
+			return app.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no app found with bundle ID %s", bundleID)
+}
+
+// fetchBuild looks up the build of the app by its build number, and returns its ID and processing state.
+// Empty values are returned if the build is not available on App Store Connect yet.
+func fetchBuild(client *appstoreconnect.Client, appID, buildNumber string) (string, string, error) {
+	req, err := client.NewRequest("GET", "builds?"+url.Values{
+		"filter[app]":     {appID},
+		"filter[version]": {buildNumber},
+		"fields[builds]":  {"version,processingState"},
+		"limit":           {"1"},
+	}.Encode(), nil)
+	if err != nil {
+		return "", "", err
+	}
+
+	var builds buildsResponse
+	if _, err := client.Do(req, &builds); err != nil {
+		return "", "", fmt.Errorf("failed to fetch build %s of the app: %w", buildNumber, err)
+	}
+	if len(builds.Data) == 0 {
+		return "", "", nil
+	}
+
+	return builds.Data[0].ID, builds.Data[0].Attributes.ProcessingState, nil
+}
@@ -0,0 +1,169 @@
+package step
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/v2/autocodesign/devportalclient/appstoreconnect"
+	"github.com/bitrise-io/go-xcode/v2/devportalservice"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseUploadResult(t *testing.T) {
+	result := parseUploadResult(`Progress 20%: Preparing build for App Store Connect…
+Progress 90%: Uploading to App Store Connect…
+2024-03-20 10:00:00.000 xcodebuild[1234:5678] [MT] IDEDistribution: Upload response: {"Delivery UUID" = "3B0C1D2E-4F5A-6B7C-8D9E-0F1A2B3C4D5E";}
+Progress 100%: Upload succeeded.
+Uploaded Sample
+** EXPORT SUCCEEDED **`, true)
+	require.Equal(t, UploadResult{Status: uploadStatusSucceeded, DeliveryUUID: "3b0c1d2e-4f5a-6b7c-8d9e-0f1a2b3c4d5e"}, result)
+
+	result = parseUploadResult(`Progress 90%: Uploading to App Store Connect…
+error: exportArchive: Upload failed
+error: The bundle version must be higher than the previously uploaded version.
+** EXPORT FAILED **`, false)
+	require.Equal(t, UploadResult{Status: uploadStatusFailed, Errors: []string{
+		"exportArchive: Upload failed",
+		"The bundle version must be higher than the previously uploaded version.",
+	}}, result)
+}
+
+func Test_addUploadDestination(t *testing.T) {
+	options, added := addUploadDestination(exportoptions.NewAppStoreOptions())
+	require.True(t, added)
+	require.Equal(t, destinationUpload, options.(exportoptions.AppStoreOptionsModel).Destination)
+
+	_, added = addUploadDestination(exportoptions.NewNonAppStoreOptions(exportoptions.MethodAdHoc))
+	require.False(t, added)
+}
+
+func Test_parseAppStoreConnectAPIURL(t *testing.T) {
+	u, err := parseAppStoreConnectAPIURL("http://localhost:8080/asc")
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8080/asc/", u.String())
+
+	u, err = parseAppStoreConnectAPIURL("")
+	require.NoError(t, err)
+	require.Nil(t, u)
+
+	_, err = parseAppStoreConnectAPIURL("localhost:8080")
+	require.EqualError(t, err, "should be an http or https URL, got: localhost:8080")
+}
+
+func Test_fetchAppID_fetchBuild(t *testing.T) {
+	var authorization, buildsQuery string
+	client := newTestAppStoreConnectClient(t, "/asc", func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+
+		switch r.URL.Path {
+		case "/asc/v1/apps":
+			_, _ = fmt.Fprint(w, `{"data": [
+				{"id": "1", "attributes": {"bundleId": "io.bitrise.app.beta"}},
+				{"id": "2", "attributes": {"bundleId": "io.bitrise.app"}}
+			]}`)
+		case "/asc/v1/builds":
+			buildsQuery = r.URL.RawQuery
+			if r.URL.Query().Get("filter[version]") != "42" {
+				_, _ = fmt.Fprint(w, `{"data": []}`)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"data": [{"id": "build-id", "attributes": {"version": "42", "processingState": "PROCESSING"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	appID, err := fetchAppID(client, "io.bitrise.app")
+	require.NoError(t, err)
+	require.Equal(t, "2", appID)
+	require.True(t, strings.HasPrefix(authorization, "Bearer "))
+
+	_, err = fetchAppID(client, "io.bitrise.other")
+	require.EqualError(t, err, "no app found with bundle ID io.bitrise.other")
+
+	buildID, processingState, err := fetchBuild(client, appID, "42")
+	require.NoError(t, err)
+	require.Equal(t, "build-id", buildID)
+	require.Equal(t, "PROCESSING", processingState)
+	require.Contains(t, buildsQuery, "filter%5Bapp%5D=2")
+
+	buildID, processingState, err = fetchBuild(client, appID, "43")
+	require.NoError(t, err)
+	require.Empty(t, buildID)
+	require.Empty(t, processingState)
+}
+
+func Test_waitForUploadedBuild(t *testing.T) {
+	// The build is not found yet for the first lookups
+	var appLookups, buildLookups int
+	client := newTestAppStoreConnectClient(t, "", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/apps":
+			appLookups++
+			_, _ = fmt.Fprint(w, `{"data": [{"id": "1", "attributes": {"bundleId": "io.bitrise.app"}}]}`)
+		case "/v1/builds":
+			buildLookups++
+			if buildLookups < 3 || r.URL.Query().Get("filter[version]") != "42" {
+				_, _ = fmt.Fprint(w, `{"data": []}`)
+				return
+			}
+			_, _ = fmt.Fprint(w, `{"data": [{"id": "build-id", "attributes": {"version": "42", "processingState": "PROCESSING"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	buildID, processingState, err := waitForUploadedBuild(client, "io.bitrise.app", "42", time.Second, time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "build-id", buildID)
+	require.Equal(t, "PROCESSING", processingState)
+	// The app is looked up once, only the builds are polled
+	require.Equal(t, 1, appLookups)
+	require.Equal(t, 3, buildLookups)
+
+	// The build is still not found when the timeout elapses
+	appLookups, buildLookups = 0, 0
+	buildID, processingState, err = waitForUploadedBuild(client, "io.bitrise.app", "43", 50*time.Millisecond, 10*time.Millisecond)
+	require.NoError(t, err)
+	require.Empty(t, buildID)
+	require.Empty(t, processingState)
+	require.Equal(t, 1, appLookups)
+	require.Greater(t, buildLookups, 1)
+
+	// The builds are not polled if the app is not found
+	appLookups, buildLookups = 0, 0
+	_, _, err = waitForUploadedBuild(client, "io.bitrise.other", "42", time.Second, time.Millisecond)
+	require.EqualError(t, err, "no app found with bundle ID io.bitrise.other")
+	require.Equal(t, 0, buildLookups)
+}
+
+// newTestAppStoreConnectClient creates an App Store Connect API client, which sends its requests to the test server at basePath.
+func newTestAppStoreConnectClient(t *testing.T, basePath string, handler http.HandlerFunc) *appstoreconnect.Client {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	apiKey := devportalservice.APIKeyConnection{
+		KeyID:      "KEY123",
+		IssuerID:   "issuer-id",
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})),
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	baseURL, err := parseAppStoreConnectAPIURL(server.URL + basePath)
+	require.NoError(t, err)
+	return newAppStoreConnectClient(apiKey, baseURL, log.NewLogger())
+}
@@ -3,14 +3,17 @@ package step
 import (
 	"bufio"
 	"fmt"
+	"os"
 	"regexp"
 	"slices"
 	"strings"
 
 	"github.com/bitrise-io/go-utils/colorstring"
+	v1pathutil "github.com/bitrise-io/go-utils/pathutil"
 	"github.com/bitrise-io/go-utils/stringutil"
 	"github.com/bitrise-io/go-utils/v2/log"
 	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
 )
 
 func generateAdditionalOptions(platform string, customOptions []string) []string {
@@ -54,13 +57,93 @@ func filterSPMAdditionalOptions(xcodebuildAdditionalOptions []string) []string {
 	return filteredShowbuildsettingsOptions
 }
 
+var exportMethodOptions = []string{
+	"app-store", "ad-hoc", "enterprise", "development", "developer-id",
+	// Names introduced in Xcode 15.3
+	"app-store-connect", "release-testing", "debugging",
+}
+
+// legacyExportMethods maps the distribution method names introduced in Xcode 15.3 to their legacy names.
+var legacyExportMethods = map[exportoptions.Method]exportoptions.Method{
+	exportoptions.MethodAppStoreConnect: exportoptions.MethodAppStore,
+	exportoptions.MethodReleaseTesting:  exportoptions.MethodAdHoc,
+	exportoptions.MethodDebugging:       exportoptions.MethodDevelopment,
+}
+
+// parseExportMethods parses the distribution method input: a single method, or multiple methods separated by `|` or newline characters.
+func parseExportMethods(exportMethodList string) ([]string, error) {
+	var exportMethods []string
+	for _, method := range strings.FieldsFunc(exportMethodList, func(r rune) bool { return r == '|' || r == '\n' }) {
+		method = strings.TrimSpace(method)
+		if method == "" {
+			continue
+		}
+		if !slices.Contains(exportMethodOptions, method) {
+			return nil, fmt.Errorf("invalid distribution method (%s), available options: %s", method, strings.Join(exportMethodOptions, ", "))
+		}
+		if slices.ContainsFunc(exportMethods, func(m string) bool { return legacyExportMethod(m) == legacyExportMethod(method) }) {
+			return nil, fmt.Errorf("distribution method (%s) is listed more than once", method)
+		}
+		exportMethods = append(exportMethods, method)
+	}
+
+	if len(exportMethods) == 0 {
+		return nil, fmt.Errorf("no distribution method specified")
+	}
+
+	return exportMethods, nil
+}
+
+// legacyExportMethod returns the pre-Xcode 15.3 name of the distribution method,
+// which is the name known by automatic code signing and the export options generator.
+func legacyExportMethod(method string) string {
+	if legacyMethod, ok := legacyExportMethods[exportoptions.Method(method)]; ok {
+		return string(legacyMethod)
+	}
+	return method
+}
+
+// exportMethodForXcode returns the name of the distribution method known by the given Xcode version:
+// the new names are used since Xcode 15.3, the legacy names before.
+func exportMethodForXcode(method exportoptions.Method, xcodeVersion xcodeversion.Version) exportoptions.Method {
+	if xcodeVersion.IsGreaterThanOrEqualTo(15, 3) {
+		return exportoptions.UpgradeToXcode15_3MethodName(method)
+	}
+	return exportoptions.Method(legacyExportMethod(string(method)))
+}
+
+// checkExportMethodSupported returns an error if the distribution method is passed to xcodebuild as it is
+// (for example in custom export options), but the given Xcode version doesn't know it.
+func checkExportMethodSupported(method string, xcodeVersion xcodeversion.Version) error {
+	if legacyMethod := legacyExportMethod(method); legacyMethod != method && !xcodeVersion.IsGreaterThanOrEqualTo(15, 3) {
+		return fmt.Errorf("distribution method %s requires Xcode 15.3 or later, but Xcode %d.%d is used, use %s instead", method, xcodeVersion.Major, xcodeVersion.Minor, legacyMethod)
+	}
+	return nil
+}
+
+// splitInputLines returns the trimmed, non-empty lines of a multi-line input.
+func splitInputLines(input string) []string {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// exportMethodEnvKey returns the per distribution method variant of an output key, for example BITRISE_IPA_PATH_APP_STORE.
+func exportMethodEnvKey(envKey, exportMethod string) string {
+	return envKey + "_" + strings.ToUpper(strings.ReplaceAll(exportMethod, "-", "_"))
+}
+
 func determineExportMethod(desiredExportMethod string, archiveExportMethod exportoptions.Method, logger log.Logger) (exportoptions.Method, error) {
 	if desiredExportMethod == "auto-detect" {
 		logger.Printf("auto-detect export method specified: using the archive profile's export method: %s", archiveExportMethod)
 		return archiveExportMethod, nil
 	}
 
-	exportMethod, err := exportoptions.ParseMethod(desiredExportMethod)
+	exportMethod, err := exportoptions.ParseMethod(legacyExportMethod(desiredExportMethod))
 	if err != nil {
 		return "", fmt.Errorf("failed to parse export method: %s", err)
 	}
@@ -69,6 +152,17 @@ func determineExportMethod(desiredExportMethod string, archiveExportMethod expor
 	return exportMethod, nil
 }
 
+func cleanup(pth string) error {
+	if exist, err := v1pathutil.IsPathExists(pth); err != nil {
+		return fmt.Errorf("failed to check if path (%s) exist, error: %s", pth, err)
+	} else if exist {
+		if err := os.RemoveAll(pth); err != nil {
+			return fmt.Errorf("failed to remove path (%s), error: %s", pth, err)
+		}
+	}
+	return nil
+}
+
 func printLastLinesOfXcodebuildLog(logger log.Logger, xcodebuildLog string, isXcodebuildSuccess bool) {
 	const lastLinesMsg = "\nLast lines of the Xcode log:"
 	if isXcodebuildSuccess {
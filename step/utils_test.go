@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-xcode/exportoptions"
+	"github.com/bitrise-io/go-xcode/v2/xcodeversion"
 	"github.com/stretchr/testify/require"
 )
 
@@ -99,3 +101,107 @@ func Test_filterSPMAdditionalOptions(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseExportMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "single method",
+			input: "app-store",
+			want:  []string{"app-store"},
+		},
+		{
+			name:  "pipe separated list",
+			input: "app-store|ad-hoc",
+			want:  []string{"app-store", "ad-hoc"},
+		},
+		{
+			name:  "newline separated list with spaces",
+			input: "development\n ad-hoc \n",
+			want:  []string{"development", "ad-hoc"},
+		},
+		{
+			name:    "unknown method",
+			input:   "app-store|store",
+			wantErr: true,
+		},
+		{
+			name:    "duplicated method",
+			input:   "ad-hoc|ad-hoc",
+			wantErr: true,
+		},
+		{
+			name:  "Xcode 15.3 names",
+			input: "app-store-connect|release-testing|debugging",
+			want:  []string{"app-store-connect", "release-testing", "debugging"},
+		},
+		{
+			name:    "duplicated method with its Xcode 15.3 name",
+			input:   "ad-hoc|release-testing",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			input:   " | ",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExportMethods(tt.input)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_exportMethodEnvKey(t *testing.T) {
+	require.Equal(t, "BITRISE_IPA_PATH_APP_STORE", exportMethodEnvKey("BITRISE_IPA_PATH", "app-store"))
+	require.Equal(t, "BITRISE_IPA_PATH_DEVELOPMENT", exportMethodEnvKey("BITRISE_IPA_PATH", "development"))
+}
+
+func Test_exportMethodForXcode(t *testing.T) {
+	xcode15_2 := xcodeversion.Version{Major: 15, Minor: 2}
+	xcode15_3 := xcodeversion.Version{Major: 15, Minor: 3}
+
+	tests := []struct {
+		method  exportoptions.Method
+		version xcodeversion.Version
+		want    exportoptions.Method
+	}{
+		{method: exportoptions.MethodAdHoc, version: xcode15_2, want: exportoptions.MethodAdHoc},
+		{method: exportoptions.MethodAdHoc, version: xcode15_3, want: exportoptions.MethodReleaseTesting},
+		{method: exportoptions.MethodReleaseTesting, version: xcode15_2, want: exportoptions.MethodAdHoc},
+		{method: exportoptions.MethodAppStoreConnect, version: xcode15_2, want: exportoptions.MethodAppStore},
+		{method: exportoptions.MethodDebugging, version: xcode15_3, want: exportoptions.MethodDebugging},
+		{method: exportoptions.MethodDevelopment, version: xcode15_3, want: exportoptions.MethodDebugging},
+		{method: exportoptions.MethodEnterprise, version: xcode15_3, want: exportoptions.MethodEnterprise},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, exportMethodForXcode(tt.method, tt.version), "%s with Xcode %d.%d", tt.method, tt.version.Major, tt.version.Minor)
+	}
+
+	require.Equal(t, "app-store", legacyExportMethod("app-store-connect"))
+	require.Equal(t, "enterprise", legacyExportMethod("enterprise"))
+}
+
+func Test_checkExportMethodSupported(t *testing.T) {
+	require.NoError(t, checkExportMethodSupported("release-testing", xcodeversion.Version{Major: 16, Minor: 0}))
+	require.NoError(t, checkExportMethodSupported("ad-hoc", xcodeversion.Version{Major: 14, Minor: 3}))
+	require.EqualError(t, checkExportMethodSupported("release-testing", xcodeversion.Version{Major: 15, Minor: 2}),
+		"distribution method release-testing requires Xcode 15.3 or later, but Xcode 15.2 is used, use ad-hoc instead")
+}
+
+func Test_determineExportMethod(t *testing.T) {
+	method, err := determineExportMethod("release-testing", exportoptions.MethodDevelopment, log.NewLogger())
+	require.NoError(t, err)
+	require.Equal(t, exportoptions.MethodAdHoc, method)
+}
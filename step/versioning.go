@@ -0,0 +1,204 @@
+package step
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/bitrise-io/go-xcode/v2/xcarchive"
+)
+
+// BuildNumberStrategy defines where the build number (CURRENT_PROJECT_VERSION) of the archive comes from.
+type BuildNumberStrategy string
+
+// BuildNumberStrategies ...
+const (
+	BuildNumberStrategyOff            BuildNumberStrategy = "off"
+	BuildNumberStrategyCIBuildNumber  BuildNumberStrategy = "ci-build-number"
+	BuildNumberStrategyGitCommitCount BuildNumberStrategy = "git-commit-count"
+	BuildNumberStrategyTimestamp      BuildNumberStrategy = "timestamp"
+	BuildNumberStrategyExplicit       BuildNumberStrategy = "explicit"
+)
+
+const (
+	buildNumberBuildSetting      = "CURRENT_PROJECT_VERSION"
+	marketingVersionBuildSetting = "MARKETING_VERSION"
+	bundleVersionKey             = "CFBundleVersion"
+	bundleShortVersionKey        = "CFBundleShortVersionString"
+
+	// timestampBuildNumberLayout results in a valid CFBundleVersion (period-separated integers), for example 20240131.1542
+	timestampBuildNumberLayout = "20060102.1504"
+)
+
+var (
+	bundleVersionPattern = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+	integerPattern       = regexp.MustCompile(`^\d+$`)
+)
+
+// AppVersion is the build number and marketing version set on every archived target, empty values are not managed by the Step.
+type AppVersion struct {
+	BuildNumber      string
+	MarketingVersion string
+}
+
+// IsEmpty ...
+func (v AppVersion) IsEmpty() bool {
+	return v.BuildNumber == "" && v.MarketingVersion == ""
+}
+
+// resolveBuildNumber returns the build number of the given strategy, with the offset added to it.
+// The offset can only be used with integer build numbers.
+func resolveBuildNumber(strategy BuildNumberStrategy, explicitBuildNumber string, offset int, ciBuildNumber string, gitCommitCount func() (string, error), now time.Time) (string, error) {
+	var buildNumber string
+	switch strategy {
+	case BuildNumberStrategyOff, "":
+		return "", nil
+	case BuildNumberStrategyCIBuildNumber:
+		if ciBuildNumber == "" {
+			return "", fmt.Errorf("CI build number (BITRISE_BUILD_NUMBER) is not set")
+		}
+		buildNumber = ciBuildNumber
+	case BuildNumberStrategyGitCommitCount:
+		commitCount, err := gitCommitCount()
+		if err != nil {
+			return "", fmt.Errorf("failed to count git commits: %w", err)
+		}
+		buildNumber = commitCount
+	case BuildNumberStrategyTimestamp:
+		buildNumber = now.UTC().Format(timestampBuildNumberLayout)
+	case BuildNumberStrategyExplicit:
+		if explicitBuildNumber == "" {
+			return "", fmt.Errorf("build number is required for the %s strategy", strategy)
+		}
+		buildNumber = explicitBuildNumber
+	default:
+		return "", fmt.Errorf("unknown build number strategy: %s", strategy)
+	}
+
+	if offset != 0 {
+		if !integerPattern.MatchString(buildNumber) {
+			return "", fmt.Errorf("offset can only be added to an integer build number, got: %s", buildNumber)
+		}
+		number, err := strconv.Atoi(buildNumber)
+		if err != nil {
+			return "", fmt.Errorf("invalid build number (%s): %w", buildNumber, err)
+		}
+		if number+offset < 0 {
+			return "", fmt.Errorf("build number (%d) with offset (%d) is negative", number, offset)
+		}
+		buildNumber = strconv.Itoa(number + offset)
+	}
+
+	if !bundleVersionPattern.MatchString(buildNumber) {
+		return "", fmt.Errorf("invalid build number (%s): should be one to three period-separated integers", buildNumber)
+	}
+
+	return buildNumber, nil
+}
+
+// applyToXcconfig returns the xcconfig content extended with the version build settings.
+// If the xcconfig content is a path to an xcconfig file, the file is included.
+func (v AppVersion) applyToXcconfig(xcconfigContent string) (string, error) {
+	if v.IsEmpty() {
+		return xcconfigContent, nil
+	}
+
+	var lines []string
+	if strings.HasSuffix(xcconfigContent, ".xcconfig") {
+		xcconfigPath, err := filepath.Abs(xcconfigContent)
+		if err != nil {
+			return "", fmt.Errorf("failed to get absolute xcconfig path: %w", err)
+		}
+		lines = append(lines, fmt.Sprintf("#include \"%s\"", xcconfigPath))
+	} else if xcconfigContent != "" {
+		lines = append(lines, xcconfigContent)
+	}
+
+	if v.BuildNumber != "" {
+		lines = append(lines, fmt.Sprintf("%s = %s", buildNumberBuildSetting, v.BuildNumber))
+	}
+	if v.MarketingVersion != "" {
+		lines = append(lines, fmt.Sprintf("%s = %s", marketingVersionBuildSetting, v.MarketingVersion))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+type bundleInfoPlist struct {
+	BundleID  string
+	InfoPlist plistutil.PlistData
+}
+
+// archivedInfoPlists returns the Info.plist of the application and every embedded bundle (extensions, watch app, app clip),
+// the application first.
+func archivedInfoPlists(archive *xcarchive.IosArchive, macosArchive *xcarchive.MacosArchive) []bundleInfoPlist {
+	var bundles []bundleInfoPlist
+	add := func(infoPlist plistutil.PlistData) {
+		bundleID, _ := infoPlist.GetString("CFBundleIdentifier")
+		bundles = append(bundles, bundleInfoPlist{BundleID: bundleID, InfoPlist: infoPlist})
+	}
+
+	if macosArchive != nil {
+		add(macosArchive.Application.InfoPlist)
+		for _, extension := range macosArchive.Application.Extensions {
+			add(extension.InfoPlist)
+		}
+		return bundles
+	}
+
+	if archive == nil {
+		return nil
+	}
+
+	add(archive.Application.InfoPlist)
+	for _, extension := range archive.Application.Extensions {
+		add(extension.InfoPlist)
+	}
+	if watchApplication := archive.Application.WatchApplication; watchApplication != nil {
+		add(watchApplication.InfoPlist)
+		for _, extension := range watchApplication.Extensions {
+			add(extension.InfoPlist)
+		}
+	}
+	if clipApplication := archive.Application.ClipApplication; clipApplication != nil {
+		add(clipApplication.InfoPlist)
+	}
+
+	return bundles
+}
+
+// checkArchivedVersions checks that every archived bundle has the expected build number and marketing version,
+// and that the embedded bundles have the same versions as the application. Every mismatch is returned in a single error.
+func checkArchivedVersions(bundles []bundleInfoPlist, expected AppVersion) error {
+	if len(bundles) == 0 {
+		return nil
+	}
+
+	app := bundles[0]
+	var mismatches []string
+	for _, key := range []string{bundleVersionKey, bundleShortVersionKey} {
+		expectedValue := expected.BuildNumber
+		if key == bundleShortVersionKey {
+			expectedValue = expected.MarketingVersion
+		}
+		appValue, _ := app.InfoPlist.GetString(key)
+
+		for i, bundle := range bundles {
+			value, _ := bundle.InfoPlist.GetString(key)
+			if expectedValue != "" && value != expectedValue {
+				mismatches = append(mismatches, fmt.Sprintf("- %s: %s is %s, expected %s", bundle.BundleID, key, value, expectedValue))
+			} else if expectedValue == "" && i > 0 && value != appValue {
+				mismatches = append(mismatches, fmt.Sprintf("- %s: %s is %s, but the application's is %s", bundle.BundleID, key, value, appValue))
+			}
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("archived bundle versions don't match (make sure the Info.plist files use $(%s) and $(%s)):\n%s", buildNumberBuildSetting, marketingVersionBuildSetting, strings.Join(mismatches, "\n"))
+	}
+	return nil
+}
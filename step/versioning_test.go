@@ -0,0 +1,88 @@
+package step
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-xcode/v2/plistutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveBuildNumber(t *testing.T) {
+	now := time.Date(2024, 1, 31, 15, 42, 0, 0, time.UTC)
+	commitCount := func() (string, error) { return "128", nil }
+
+	tests := []struct {
+		name     string
+		strategy BuildNumberStrategy
+		explicit string
+		offset   int
+		ci       string
+		want     string
+		wantErr  string
+	}{
+		{name: "off", strategy: BuildNumberStrategyOff, ci: "12", want: ""},
+		{name: "CI build number with offset", strategy: BuildNumberStrategyCIBuildNumber, ci: "12", offset: 1000, want: "1012"},
+		{name: "missing CI build number", strategy: BuildNumberStrategyCIBuildNumber, wantErr: "CI build number (BITRISE_BUILD_NUMBER) is not set"},
+		{name: "git commit count", strategy: BuildNumberStrategyGitCommitCount, want: "128"},
+		{name: "timestamp", strategy: BuildNumberStrategyTimestamp, want: "20240131.1542"},
+		{name: "timestamp with offset", strategy: BuildNumberStrategyTimestamp, offset: 1, wantErr: "offset can only be added to an integer build number, got: 20240131.1542"},
+		{name: "explicit", strategy: BuildNumberStrategyExplicit, explicit: "1.0.3", want: "1.0.3"},
+		{name: "explicit with negative offset", strategy: BuildNumberStrategyExplicit, explicit: "10", offset: -3, want: "7"},
+		{name: "missing explicit build number", strategy: BuildNumberStrategyExplicit, wantErr: "build number is required for the explicit strategy"},
+		{name: "invalid explicit build number", strategy: BuildNumberStrategyExplicit, explicit: "1.0-beta", wantErr: "invalid build number (1.0-beta): should be one to three period-separated integers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveBuildNumber(tt.strategy, tt.explicit, tt.offset, tt.ci, commitCount, now)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_resolveBuildNumber_GitError(t *testing.T) {
+	_, err := resolveBuildNumber(BuildNumberStrategyGitCommitCount, "", 0, "", func() (string, error) { return "", errors.New("not a git repository") }, time.Now())
+	require.EqualError(t, err, "failed to count git commits: not a git repository")
+}
+
+func TestAppVersion_applyToXcconfig(t *testing.T) {
+	version := AppVersion{BuildNumber: "42", MarketingVersion: "1.2.0"}
+
+	content, err := version.applyToXcconfig("COMPILER_INDEX_STORE_ENABLE = NO")
+	require.NoError(t, err)
+	require.Equal(t, "COMPILER_INDEX_STORE_ENABLE = NO\nCURRENT_PROJECT_VERSION = 42\nMARKETING_VERSION = 1.2.0", content)
+
+	content, err = version.applyToXcconfig("/project/Release.xcconfig")
+	require.NoError(t, err)
+	require.Equal(t, "#include \"/project/Release.xcconfig\"\nCURRENT_PROJECT_VERSION = 42\nMARKETING_VERSION = 1.2.0", content)
+
+	content, err = AppVersion{}.applyToXcconfig("/project/Release.xcconfig")
+	require.NoError(t, err)
+	require.Equal(t, "/project/Release.xcconfig", content)
+}
+
+func Test_checkArchivedVersions(t *testing.T) {
+	bundle := func(bundleID, buildNumber, marketingVersion string) bundleInfoPlist {
+		return bundleInfoPlist{BundleID: bundleID, InfoPlist: plistutil.PlistData{
+			"CFBundleIdentifier":         bundleID,
+			"CFBundleVersion":            buildNumber,
+			"CFBundleShortVersionString": marketingVersion,
+		}}
+	}
+
+	matching := []bundleInfoPlist{bundle("io.bitrise.app", "42", "1.2.0"), bundle("io.bitrise.app.widget", "42", "1.2.0")}
+	require.NoError(t, checkArchivedVersions(matching, AppVersion{BuildNumber: "42", MarketingVersion: "1.2.0"}))
+	require.NoError(t, checkArchivedVersions(matching, AppVersion{BuildNumber: "42"}))
+
+	mismatching := []bundleInfoPlist{bundle("io.bitrise.app", "42", "1.2.0"), bundle("io.bitrise.app.widget", "1", "1.1.0")}
+	err := checkArchivedVersions(mismatching, AppVersion{BuildNumber: "42"})
+	require.EqualError(t, err, `archived bundle versions don't match (make sure the Info.plist files use $(CURRENT_PROJECT_VERSION) and $(MARKETING_VERSION)):
+- io.bitrise.app.widget: CFBundleVersion is 1, expected 42
+- io.bitrise.app.widget: CFBundleShortVersionString is 1.1.0, but the application's is 1.2.0`)
+}
@@ -0,0 +1,134 @@
+package watchdog
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const killGracePeriod = 10 * time.Second
+
+type process struct {
+	pid  int
+	ppid int
+	args string
+}
+
+// killProcessTree terminates the process tree of the watched command: the command (found by its arguments
+// among the processes started by the step) and the processes started by it (compilers, codesign, ...).
+// Other processes of the step, like the log formatter reading the command's output, are not signaled.
+// The processes get SIGTERM first, and SIGKILL if they are still alive after the grace period.
+func killProcessTree(args []string) error {
+	processes, err := listProcesses()
+	if err != nil {
+		return err
+	}
+
+	rootPIDs := watchedPIDs(processes, os.Getpid(), args)
+	if len(rootPIDs) == 0 {
+		return fmt.Errorf("the watched process is not running")
+	}
+
+	var pids []int
+	for _, rootPID := range rootPIDs {
+		pids = append(pids, rootPID)
+		pids = append(pids, descendantPIDs(processes, rootPID)...)
+	}
+
+	for _, pid := range pids {
+		_ = syscall.Kill(pid, syscall.SIGTERM)
+	}
+
+	deadline := time.Now().Add(killGracePeriod)
+	for time.Now().Before(deadline) {
+		if !anyAlive(pids) {
+			return nil
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	for _, pid := range pids {
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	}
+	return nil
+}
+
+func anyAlive(pids []int) bool {
+	for _, pid := range pids {
+		// Signal 0 only checks if the process exists
+		if err := syscall.Kill(pid, 0); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// listProcesses returns every process with its parent and arguments, based on `ps`.
+func listProcesses() ([]process, error) {
+	out, err := exec.Command("ps", "-A", "-ww", "-o", "pid=", "-o", "ppid=", "-o", "args=").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	return parseProcesses(string(out)), nil
+}
+
+func parseProcesses(psOutput string) []process {
+	var processes []process
+	for _, line := range strings.Split(psOutput, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		processes = append(processes, process{pid: pid, ppid: ppid, args: strings.Join(fields[2:], " ")})
+	}
+	return processes
+}
+
+// watchedPIDs returns the pids of the children of the step, whose command line ends with the watched xcodebuild command.
+// The command line may start with a wrapper, as the inner command factory may wrap xcodebuild with another command.
+func watchedPIDs(processes []process, stepPID int, args []string) []int {
+	commandLine := strings.Join(strings.Fields(strings.Join(append([]string{xcodebuildBinary}, args...), " ")), " ")
+
+	var pids []int
+	for _, p := range processes {
+		if p.ppid != stepPID {
+			continue
+		}
+		if p.args == commandLine || strings.HasSuffix(p.args, " "+commandLine) {
+			pids = append(pids, p.pid)
+		}
+	}
+	return pids
+}
+
+// descendantPIDs returns the pids of the process tree under the given process (excluding it).
+func descendantPIDs(processes []process, rootPID int) []int {
+	children := map[int][]int{}
+	for _, p := range processes {
+		children[p.ppid] = append(children[p.ppid], p.pid)
+	}
+
+	var descendants []int
+	queue := []int{rootPID}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		for _, child := range children[pid] {
+			descendants = append(descendants, child)
+			queue = append(queue, child)
+		}
+	}
+	return descendants
+}
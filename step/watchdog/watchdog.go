@@ -0,0 +1,245 @@
+package watchdog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/log"
+)
+
+// xcodebuildBinary is the command name the go-xcode xcodecommand runners use
+// when invoking xcodebuild. Only these commands are watched, log formatters
+// (xcbeautify, xcpretty) stop by themselves once xcodebuild is terminated.
+const xcodebuildBinary = "xcodebuild"
+
+const defaultCheckInterval = time.Second
+
+// Opts configures the watchdog, a zero timeout disables the given check.
+type Opts struct {
+	// SilenceTimeout is the maximum time the command may run without writing to its stdout or stderr.
+	SilenceTimeout time.Duration
+	// TotalTimeout is the maximum run time of the command.
+	TotalTimeout time.Duration
+}
+
+// Error is returned by the watched command if the watchdog terminated it.
+type Error struct {
+	Command        string
+	Reason         string
+	Timeout        time.Duration
+	LastOutputLine string
+}
+
+// Error ...
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("command terminated by the watchdog, %s for %s: %s", e.Reason, e.Timeout, e.Command)
+	if e.LastOutputLine != "" {
+		msg += fmt.Sprintf(" (last output line: %s)", e.LastOutputLine)
+	}
+	return msg
+}
+
+// NewCommandFactory returns a command.Factory that forwards all calls to inner,
+// and watches the xcodebuild commands: if a command produces no output for opts.SilenceTimeout,
+// or runs longer than opts.TotalTimeout, the process tree of the command is terminated and the command returns an *Error.
+// Wrap the factory given to the xcodecommand runners, after any other wrapping factory.
+func NewCommandFactory(inner command.Factory, opts Opts, logger log.Logger) command.Factory {
+	return &watchdogFactory{
+		inner:         inner,
+		opts:          opts,
+		logger:        logger,
+		checkInterval: defaultCheckInterval,
+		kill:          killProcessTree,
+	}
+}
+
+type watchdogFactory struct {
+	inner  command.Factory
+	opts   Opts
+	logger log.Logger
+
+	checkInterval time.Duration
+	kill          func(args []string) error
+}
+
+func (f *watchdogFactory) Create(name string, args []string, opts *command.Opts) command.Command {
+	if name != xcodebuildBinary || (f.opts.SilenceTimeout == 0 && f.opts.TotalTimeout == 0) {
+		return f.inner.Create(name, args, opts)
+	}
+
+	activity := &activityRecorder{}
+	watchedOpts := command.Opts{}
+	if opts != nil {
+		watchedOpts = *opts
+	}
+	watchedOpts.Stdout = activity.wrap(watchedOpts.Stdout)
+	watchedOpts.Stderr = activity.wrap(watchedOpts.Stderr)
+
+	return &watchedCommand{
+		Command:  f.inner.Create(name, args, &watchedOpts),
+		args:     args,
+		factory:  f,
+		activity: activity,
+	}
+}
+
+type watchedCommand struct {
+	command.Command
+	args     []string
+	factory  *watchdogFactory
+	activity *activityRecorder
+
+	stop  chan struct{}
+	done  chan struct{}
+	fired *Error
+}
+
+// Run ...
+func (c *watchedCommand) Run() error {
+	c.startWatching()
+	err := c.Command.Run()
+	return c.stopWatching(err)
+}
+
+// RunAndReturnExitCode ...
+func (c *watchedCommand) RunAndReturnExitCode() (int, error) {
+	c.startWatching()
+	exitCode, err := c.Command.RunAndReturnExitCode()
+	return exitCode, c.stopWatching(err)
+}
+
+// Start ...
+func (c *watchedCommand) Start() error {
+	c.startWatching()
+	err := c.Command.Start()
+	if err != nil {
+		return c.stopWatching(err)
+	}
+	return nil
+}
+
+// Wait ...
+func (c *watchedCommand) Wait() error {
+	err := c.Command.Wait()
+	return c.stopWatching(err)
+}
+
+func (c *watchedCommand) startWatching() {
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	c.activity.touch()
+
+	go func() {
+		defer close(c.done)
+
+		start := time.Now()
+		ticker := time.NewTicker(c.factory.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case now := <-ticker.C:
+				var reason string
+				var timeout time.Duration
+				if c.factory.opts.TotalTimeout > 0 && now.Sub(start) >= c.factory.opts.TotalTimeout {
+					reason, timeout = "total timeout exceeded", c.factory.opts.TotalTimeout
+				} else if c.factory.opts.SilenceTimeout > 0 && now.Sub(c.activity.lastActivity()) >= c.factory.opts.SilenceTimeout {
+					reason, timeout = "no output", c.factory.opts.SilenceTimeout
+				}
+				if reason == "" {
+					continue
+				}
+
+				c.fired = &Error{
+					Command:        c.PrintableCommandArgs(),
+					Reason:         reason,
+					Timeout:        timeout,
+					LastOutputLine: c.activity.lastLine(),
+				}
+				c.factory.logger.Errorf("Watchdog: %s for %s, terminating the process tree", reason, timeout)
+				if err := c.factory.kill(c.args); err != nil {
+					c.factory.logger.Warnf("Watchdog: failed to terminate the process tree: %s", err)
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (c *watchedCommand) stopWatching(err error) error {
+	if c.stop == nil {
+		return err
+	}
+	close(c.stop)
+	<-c.done
+	c.stop = nil
+
+	if c.fired != nil {
+		return c.fired
+	}
+	return err
+}
+
+// activityRecorder records the time of the last write and the last non-empty output line.
+type activityRecorder struct {
+	mu       sync.Mutex
+	last     time.Time
+	lastText string
+}
+
+func (r *activityRecorder) wrap(w io.Writer) activityWriter {
+	return activityWriter{recorder: r, inner: w}
+}
+
+func (r *activityRecorder) touch() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = time.Now()
+}
+
+func (r *activityRecorder) record(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = time.Now()
+
+	lines := bytes.Split(p, []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(string(lines[i])); line != "" {
+			r.lastText = line
+			return
+		}
+	}
+}
+
+func (r *activityRecorder) lastActivity() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+func (r *activityRecorder) lastLine() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastText
+}
+
+type activityWriter struct {
+	recorder *activityRecorder
+	inner    io.Writer
+}
+
+// Write ...
+func (w activityWriter) Write(p []byte) (int, error) {
+	w.recorder.record(p)
+	if w.inner == nil {
+		return len(p), nil
+	}
+	return w.inner.Write(p)
+}
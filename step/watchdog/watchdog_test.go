@@ -0,0 +1,134 @@
+package watchdog
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shellFactory runs the given script with sh, regardless of the command name.
+// The command name and arguments are passed to the script as positional parameters, like a wrapper command would do.
+type shellFactory struct {
+	inner  command.Factory
+	script string
+}
+
+func (f shellFactory) Create(name string, args []string, opts *command.Opts) command.Command {
+	return f.inner.Create("sh", append([]string{"-c", f.script, name}, args...), opts)
+}
+
+func newTestFactory(script string, opts Opts) *watchdogFactory {
+	f := NewCommandFactory(shellFactory{inner: command.NewFactory(env.NewRepository()), script: script}, opts, log.NewLogger()).(*watchdogFactory)
+	f.checkInterval = 20 * time.Millisecond
+	return f
+}
+
+func TestWatchdog_SilenceTimeout(t *testing.T) {
+	factory := newTestFactory("echo started; sleep 30", Opts{SilenceTimeout: 300 * time.Millisecond})
+
+	var out bytes.Buffer
+	cmd := factory.Create("xcodebuild", []string{"archive"}, &command.Opts{Stdout: &out, Stderr: &out})
+
+	start := time.Now()
+	_, err := cmd.RunAndReturnExitCode()
+	require.Less(t, time.Since(start), 10*time.Second)
+
+	var watchdogErr *Error
+	require.True(t, errors.As(err, &watchdogErr))
+	assert.Equal(t, "no output", watchdogErr.Reason)
+	assert.Equal(t, 300*time.Millisecond, watchdogErr.Timeout)
+	assert.Equal(t, "started", watchdogErr.LastOutputLine)
+	assert.Equal(t, "started\n", out.String())
+}
+
+func TestWatchdog_TotalTimeout(t *testing.T) {
+	factory := newTestFactory("while true; do echo tick; sleep 0.05; done", Opts{SilenceTimeout: time.Minute, TotalTimeout: 300 * time.Millisecond})
+
+	cmd := factory.Create("xcodebuild", []string{"archive"}, &command.Opts{})
+	require.NoError(t, cmd.Start())
+	err := cmd.Wait()
+
+	var watchdogErr *Error
+	require.True(t, errors.As(err, &watchdogErr))
+	assert.Equal(t, "total timeout exceeded", watchdogErr.Reason)
+	assert.Equal(t, "tick", watchdogErr.LastOutputLine)
+}
+
+func TestWatchdog_KeepsOtherProcessesOfTheStep(t *testing.T) {
+	sibling := exec.Command("sleep", "30")
+	require.NoError(t, sibling.Start())
+	siblingExited := make(chan error, 1)
+	go func() { siblingExited <- sibling.Wait() }()
+	defer func() { _ = sibling.Process.Kill() }()
+
+	factory := newTestFactory("sleep 30; echo never", Opts{SilenceTimeout: 300 * time.Millisecond})
+	_, err := factory.Create("xcodebuild", []string{"archive"}, &command.Opts{}).RunAndReturnExitCode()
+
+	var watchdogErr *Error
+	require.True(t, errors.As(err, &watchdogErr))
+	select {
+	case err := <-siblingExited:
+		t.Fatalf("the watchdog terminated a process outside of the watched command's tree: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatchdog_CommandFinishesInTime(t *testing.T) {
+	factory := newTestFactory("echo a; sleep 0.1; echo b", Opts{SilenceTimeout: 5 * time.Second, TotalTimeout: 10 * time.Second})
+
+	var out bytes.Buffer
+	cmd := factory.Create("xcodebuild", []string{"archive"}, &command.Opts{Stdout: &out})
+	require.NoError(t, cmd.Run())
+	assert.Equal(t, "a\nb\n", out.String())
+}
+
+func TestWatchdog_PassesThroughOtherCommands(t *testing.T) {
+	factory := newTestFactory("true", Opts{SilenceTimeout: time.Second})
+
+	_, isWatched := factory.Create("xcbeautify", nil, nil).(*watchedCommand)
+	assert.False(t, isWatched)
+
+	_, isWatched = factory.Create("xcodebuild", nil, nil).(*watchedCommand)
+	assert.True(t, isWatched)
+}
+
+func TestWatchdog_Disabled(t *testing.T) {
+	factory := newTestFactory("true", Opts{})
+
+	_, isWatched := factory.Create("xcodebuild", nil, nil).(*watchedCommand)
+	assert.False(t, isWatched)
+}
+
+func Test_descendantPIDs(t *testing.T) {
+	processes := parseProcesses(`    1     0 /sbin/launchd
+  100     1 steps-xcode-archive
+  200   100 xcodebuild archive -scheme App
+  201   100 xcbeautify
+  300   200 swift-frontend -c
+  400     1 bash
+`)
+	assert.Equal(t, []int{200, 201, 300}, descendantPIDs(processes, 100))
+	assert.Equal(t, []int{300}, descendantPIDs(processes, 200))
+	assert.Empty(t, descendantPIDs(processes, 400))
+}
+
+func Test_watchedPIDs(t *testing.T) {
+	processes := parseProcesses(`  100     1 steps-xcode-archive
+  200   100 xcodebuild archive -scheme My App
+  201   100 xcbeautify --renderer terminal
+  202   100 xcodebuild -resolvePackageDependencies -scheme My App
+  203   100 /usr/local/bin/bitrise-build-cache react-native run -- xcodebuild -exportArchive -archivePath App.xcarchive
+  300   200 xcodebuild archive -scheme My App
+`)
+	assert.Equal(t, []int{200}, watchedPIDs(processes, 100, []string{"archive", "-scheme", "My App"}))
+	assert.Equal(t, []int{203}, watchedPIDs(processes, 100, []string{"-exportArchive", "-archivePath", "App.xcarchive"}))
+	assert.Empty(t, watchedPIDs(processes, 100, []string{"-showBuildSettings"}))
+}
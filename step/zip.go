@@ -0,0 +1,291 @@
+package step
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Zip compression levels, the levels in between are the compress/flate levels.
+const (
+	// ZipLevelStore stores the entries without compression, for already compressed content.
+	ZipLevelStore   = flate.NoCompression
+	ZipLevelDefault = flate.DefaultCompression
+)
+
+// zipMemoryLimit is the size above which a compressed entry is spooled to a temp file instead of memory,
+// until it is written into the zip.
+const zipMemoryLimit = 8 << 20
+
+// zipModDate is the modification date of every entry, so zipping the same content produces the same zip.
+// It is 1980-01-01, the earliest date the MS-DOS date format of the zip headers can represent: (year - 1980) << 9 | month << 5 | day.
+const zipModDate = 1<<5 | 1
+
+type zipEntry struct {
+	path   string
+	header *zip.FileHeader
+
+	// Set by the compression of deflated entries, done is closed when it finishes
+	done       chan struct{}
+	compressed *bytes.Buffer
+	spool      *os.File
+	store      bool
+	err        error
+}
+
+// zipDir zips the source dir into the destination zip, the entries are relative to the parent of the source dir (like `zip -ry`).
+// Symlinks are stored as links and the permissions are preserved. The entries are compressed in parallel by the given number of workers,
+// but they are written in the order of the file tree with a fixed modification time, so the zip is deterministic.
+func zipDir(sourceDir, destinationZipPth string, level, workers int) (err error) {
+	entries, err := collectZipEntries(sourceDir, level)
+	if err != nil {
+		return fmt.Errorf("failed to list the files of %s: %w", sourceDir, err)
+	}
+
+	spoolDir, err := os.MkdirTemp("", "zip-spool")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if removeErr := os.RemoveAll(spoolDir); removeErr != nil && err == nil {
+			err = removeErr
+		}
+	}()
+
+	file, err := os.Create(destinationZipPth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	// The slots bound the number of entries being compressed or waiting to be written,
+	// a slot is released once its entry is written into the zip.
+	slots := make(chan struct{}, max(workers, 1))
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	defer func() {
+		close(quit)
+		wg.Wait()
+
+		// Spool files of the entries not written because of an error
+		for _, entry := range entries {
+			if entry.spool != nil {
+				_ = entry.spool.Close()
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, entry := range entries {
+			if entry.done == nil {
+				continue
+			}
+
+			select {
+			case slots <- struct{}{}:
+			case <-quit:
+				return
+			}
+
+			wg.Add(1)
+			go func(entry *zipEntry) {
+				defer wg.Done()
+				defer close(entry.done)
+				entry.err = entry.compress(level, spoolDir)
+			}(entry)
+		}
+	}()
+
+	writer := zip.NewWriter(file)
+	for _, entry := range entries {
+		if err := writeZipEntry(writer, entry); err != nil {
+			return fmt.Errorf("failed to zip %s: %w", entry.path, err)
+		}
+		if entry.done != nil {
+			<-slots
+		}
+	}
+
+	return writer.Close()
+}
+
+// collectZipEntries lists the zip entries of the source dir in lexical order, without following symlinks.
+func collectZipEntries(sourceDir string, level int) ([]*zipEntry, error) {
+	parentDir := filepath.Dir(sourceDir)
+
+	var entries []*zipEntry
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode()
+		if !mode.IsRegular() && !mode.IsDir() && mode&fs.ModeSymlink == 0 {
+			// Sockets, devices and named pipes have no content to zip
+			return nil
+		}
+
+		relPath, err := filepath.Rel(parentDir, path)
+		if err != nil {
+			return err
+		}
+
+		// The MS-DOS modification time is set instead of Modified, because CreateRaw does not convert Modified
+		header := &zip.FileHeader{
+			Name:         filepath.ToSlash(relPath),
+			Method:       zip.Store,
+			ModifiedDate: zipModDate,
+		}
+		header.SetMode(mode)
+
+		entry := &zipEntry{path: path, header: header}
+		switch {
+		case mode.IsDir():
+			header.Name += "/"
+		case mode.IsRegular() && level != ZipLevelStore && info.Size() > 0:
+			header.Method = zip.Deflate
+			header.UncompressedSize64 = uint64(info.Size())
+			entry.done = make(chan struct{})
+		}
+		entries = append(entries, entry)
+
+		return nil
+	})
+	return entries, err
+}
+
+// compress deflates the entry's file into memory, or into a spool file if it is large,
+// and falls back to storing the file if the compression does not make it smaller.
+func (e *zipEntry) compress(level int, spoolDir string) error {
+	file, err := os.Open(e.path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	var dst io.Writer
+	if e.header.UncompressedSize64 > zipMemoryLimit {
+		if e.spool, err = os.CreateTemp(spoolDir, "entry"); err != nil {
+			return err
+		}
+		dst = e.spool
+	} else {
+		e.compressed = &bytes.Buffer{}
+		dst = e.compressed
+	}
+
+	counter := &countingWriter{w: dst}
+	deflater, err := flate.NewWriter(counter, level)
+	if err != nil {
+		return err
+	}
+
+	crc := crc32.NewIEEE()
+	size, err := io.Copy(deflater, io.TeeReader(file, crc))
+	if err != nil {
+		return err
+	}
+	if err := deflater.Close(); err != nil {
+		return err
+	}
+
+	e.header.CRC32 = crc.Sum32()
+	e.header.UncompressedSize64 = uint64(size)
+	e.header.CompressedSize64 = uint64(counter.n)
+	if counter.n >= size {
+		e.store = true
+		e.header.Method = zip.Store
+		e.compressed = nil
+	}
+
+	return nil
+}
+
+func writeZipEntry(writer *zip.Writer, entry *zipEntry) error {
+	header := entry.header
+	mode := header.Mode()
+
+	if entry.done != nil {
+		<-entry.done
+		if entry.spool != nil {
+			defer func() {
+				_ = entry.spool.Close()
+				_ = os.Remove(entry.spool.Name())
+			}()
+		}
+		if entry.err != nil {
+			return entry.err
+		}
+
+		if !entry.store {
+			w, err := writer.CreateRaw(header)
+			if err != nil {
+				return err
+			}
+
+			if entry.spool != nil {
+				if _, err := entry.spool.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				_, err = io.Copy(w, entry.spool)
+				return err
+			}
+			_, err = w.Write(entry.compressed.Bytes())
+			return err
+		}
+	}
+
+	w, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case mode.IsDir():
+		return nil
+	case mode&fs.ModeSymlink != 0:
+		target, err := os.Readlink(entry.path)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, target)
+		return err
+	default:
+		file, err := os.Open(entry.path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+
+		_, err = io.Copy(w, file)
+		return err
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,173 @@
+package step
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bitrise-io/go-utils/v2/command"
+	"github.com/bitrise-io/go-utils/v2/env"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_zipDir(t *testing.T) {
+	sourceDir := filepath.Join(t.TempDir(), "Sample.xcarchive")
+	writeZipFixture(t, sourceDir, map[string]string{
+		"Info.plist": "<plist/>",
+		"Products/Applications/Sample.app/Sample":   string(bytes.Repeat([]byte("compressible "), 1000)),
+		"dSYMs/Sample.app.dSYM/Contents/Info.plist": "<plist/>",
+		"Products/Applications/Sample.app/empty":    "",
+	})
+	require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, "SwiftSupport"), 0755))
+	require.NoError(t, os.Chmod(filepath.Join(sourceDir, "Products/Applications/Sample.app/Sample"), 0755))
+	require.NoError(t, os.Symlink("Sample.app", filepath.Join(sourceDir, "Products/Applications/Current")))
+
+	for _, level := range []int{ZipLevelDefault, ZipLevelStore} {
+		t.Run(fmt.Sprintf("level %d", level), func(t *testing.T) {
+			zipPth := filepath.Join(t.TempDir(), "Sample.xcarchive.zip")
+			require.NoError(t, zipDir(sourceDir, zipPth, level, 2))
+
+			reader, err := zip.OpenReader(zipPth)
+			require.NoError(t, err)
+			defer func() { require.NoError(t, reader.Close()) }()
+
+			var names []string
+			files := map[string]*zip.File{}
+			for _, file := range reader.File {
+				names = append(names, file.Name)
+				files[file.Name] = file
+				require.Equal(t, time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC), file.Modified)
+			}
+			require.Equal(t, []string{
+				"Sample.xcarchive/",
+				"Sample.xcarchive/Info.plist",
+				"Sample.xcarchive/Products/",
+				"Sample.xcarchive/Products/Applications/",
+				"Sample.xcarchive/Products/Applications/Current",
+				"Sample.xcarchive/Products/Applications/Sample.app/",
+				"Sample.xcarchive/Products/Applications/Sample.app/Sample",
+				"Sample.xcarchive/Products/Applications/Sample.app/empty",
+				"Sample.xcarchive/SwiftSupport/",
+				"Sample.xcarchive/dSYMs/",
+				"Sample.xcarchive/dSYMs/Sample.app.dSYM/",
+				"Sample.xcarchive/dSYMs/Sample.app.dSYM/Contents/",
+				"Sample.xcarchive/dSYMs/Sample.app.dSYM/Contents/Info.plist",
+			}, names)
+
+			link := files["Sample.xcarchive/Products/Applications/Current"]
+			require.Equal(t, os.ModeSymlink, link.Mode()&os.ModeSymlink)
+			require.Equal(t, "Sample.app", readZipFile(t, link))
+
+			binary := files["Sample.xcarchive/Products/Applications/Sample.app/Sample"]
+			require.Equal(t, os.FileMode(0755), binary.Mode().Perm())
+			require.Equal(t, string(bytes.Repeat([]byte("compressible "), 1000)), readZipFile(t, binary))
+			if level == ZipLevelStore {
+				require.Equal(t, zip.Store, binary.Method)
+			} else {
+				require.Equal(t, zip.Deflate, binary.Method)
+			}
+
+			require.True(t, files["Sample.xcarchive/SwiftSupport/"].Mode().IsDir())
+			require.Empty(t, readZipFile(t, files["Sample.xcarchive/Products/Applications/Sample.app/empty"]))
+		})
+	}
+}
+
+func Test_zipDir_Deterministic(t *testing.T) {
+	sourceDir := filepath.Join(t.TempDir(), "Sample.xcarchive")
+	files := map[string]string{}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		content := make([]byte, random.Intn(64<<10))
+		random.Read(content)
+		if i%2 == 0 {
+			content = bytes.Repeat(content[:len(content)/16], 16)
+		}
+		files[fmt.Sprintf("dir%d/file%d", i%5, i)] = string(content)
+	}
+	writeZipFixture(t, sourceDir, files)
+
+	firstZipPth := filepath.Join(t.TempDir(), "first.zip")
+	require.NoError(t, zipDir(sourceDir, firstZipPth, ZipLevelDefault, 8))
+
+	// The files are written later, with different modification times
+	require.NoError(t, os.RemoveAll(sourceDir))
+	writeZipFixture(t, sourceDir, files)
+
+	secondZipPth := filepath.Join(t.TempDir(), "second.zip")
+	require.NoError(t, zipDir(sourceDir, secondZipPth, ZipLevelDefault, 1))
+
+	first, err := os.ReadFile(firstZipPth)
+	require.NoError(t, err)
+	second, err := os.ReadFile(secondZipPth)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+}
+
+// BenchmarkZipDir compares the native zip with the `/usr/bin/zip -rTy` it replaced, on a dir of compressible and random content.
+func BenchmarkZipDir(b *testing.B) {
+	sourceDir := filepath.Join(b.TempDir(), "Sample.xcarchive")
+	files := map[string]string{}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 64; i++ {
+		content := make([]byte, 1<<20)
+		random.Read(content)
+		if i%2 == 0 {
+			content = bytes.Repeat(content[:4<<10], 256)
+		}
+		files[fmt.Sprintf("dSYMs/file%d", i)] = string(content)
+	}
+	writeZipFixture(b, sourceDir, files)
+	zipPth := filepath.Join(b.TempDir(), "Sample.xcarchive.zip")
+
+	b.Run("native", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, zipDir(sourceDir, zipPth, ZipLevelDefault, runtime.NumCPU()))
+		}
+	})
+
+	b.Run("native store", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, zipDir(sourceDir, zipPth, ZipLevelStore, runtime.NumCPU()))
+		}
+	})
+
+	b.Run("zip command", func(b *testing.B) {
+		if _, err := os.Stat("/usr/bin/zip"); err != nil {
+			b.Skip("/usr/bin/zip is not available")
+		}
+
+		cmdFactory := command.NewFactory(env.NewRepository())
+		for i := 0; i < b.N; i++ {
+			require.NoError(b, os.RemoveAll(zipPth))
+			cmd := cmdFactory.Create("/usr/bin/zip", []string{"-rTy", zipPth, filepath.Base(sourceDir)}, &command.Opts{Dir: filepath.Dir(sourceDir)})
+			out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+			require.NoError(b, err, out)
+		}
+	})
+}
+
+func writeZipFixture(t testing.TB, dir string, files map[string]string) {
+	for name, content := range files {
+		pth := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+		require.NoError(t, os.WriteFile(pth, []byte(content), 0644))
+	}
+}
+
+func readZipFile(t *testing.T, file *zip.File) string {
+	reader, err := file.Open()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, reader.Close()) }()
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}